@@ -0,0 +1,223 @@
+// Package aria2 sends torrents found with the Strike API to an aria2
+// daemon through its JSON-RPC interface.
+package aria2
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Custom errors
+var (
+	ErrNoSource = errors.New("torrent has neither a magnet nor a hash")
+)
+
+// Client represents an aria2 JSON-RPC endpoint
+type Client struct {
+	// URL of the RPC endpoint, e.g. http://localhost:6800/jsonrpc
+	URL string
+	// Secret is the --rpc-secret of the daemon
+	Secret string
+	// Options are sent with every added torrent, e.g. {"dir": "/data"}
+	Options map[string]string
+
+	HTTPClient *http.Client
+
+	id uint64
+}
+
+// Status represents the progress of a download as given by aria2.tellStatus
+type Status struct {
+	GID             string   `json:"gid"`
+	Status          string   `json:"status"`
+	TotalLength     string   `json:"totalLength"`
+	CompletedLength string   `json:"completedLength"`
+	DownloadSpeed   string   `json:"downloadSpeed"`
+	UploadSpeed     string   `json:"uploadSpeed"`
+	NumSeeders      string   `json:"numSeeders"`
+	Connections     string   `json:"connections"`
+	ErrorCode       string   `json:"errorCode"`
+	ErrorMessage    string   `json:"errorMessage"`
+	FollowedBy      []string `json:"followedBy"`
+}
+
+// Error represents a JSON-RPC error returned by aria2
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("aria2: %s (%d)", e.Message, e.Code)
+}
+
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      string        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// New returns a Client for the given RPC URL and secret
+func New(url, secret string) *Client {
+	return &Client{URL: url, Secret: secret}
+}
+
+// Send will add a Torrent to aria2, it implements strikeapi.Sink
+func (c *Client) Send(t *strikeapi.Torrent) error {
+	_, err := c.Add(t)
+	return err
+}
+
+// Add will add a Torrent to aria2, using its magnet when available and its
+// .torrent file otherwise, and return the GID of the download
+func (c *Client) Add(t *strikeapi.Torrent) (string, error) {
+	if t.MagnetURI != "" {
+		return c.AddURI(t.MagnetURI)
+	}
+	if t.Hash == "" {
+		return "", ErrNoSource
+	}
+
+	data, err := t.GetTorrentFile()
+	if err != nil {
+		return "", err
+	}
+	return c.AddTorrent(data)
+}
+
+// AddURI will add a download from a magnet URI with aria2.addUri
+func (c *Client) AddURI(uri string) (string, error) {
+	var gid string
+	err := c.call("aria2.addUri", &gid, []string{uri}, c.options())
+	return gid, err
+}
+
+// AddTorrent will add a download from the content of a .torrent file with
+// aria2.addTorrent
+func (c *Client) AddTorrent(data []byte) (string, error) {
+	var gid string
+	err := c.call("aria2.addTorrent", &gid, base64.StdEncoding.EncodeToString(data), []string{}, c.options())
+	return gid, err
+}
+
+// TellStatus will get the status of a download from its GID
+func (c *Client) TellStatus(gid string) (*Status, error) {
+	status := &Status{}
+	if err := c.call("aria2.tellStatus", status, gid); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Poll will call TellStatus every interval until the download is over or
+// the context is done, fn is called with every status received
+// A magnet first downloads its metadata, Poll follows the real download
+// aria2 starts afterwards
+func (c *Client) Poll(ctx context.Context, gid string, interval time.Duration, fn func(*Status)) (*Status, error) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		status, err := c.TellStatus(gid)
+		if err != nil {
+			return nil, err
+		}
+		if fn != nil {
+			fn(status)
+		}
+
+		switch status.Status {
+		case "complete":
+			if len(status.FollowedBy) == 0 {
+				return status, nil
+			}
+			gid = status.FollowedBy[0]
+		case "error", "removed":
+			return status, nil
+		}
+		timer.Reset(interval)
+	}
+}
+
+// Progress returns the completed fraction of the download, between 0 and 1
+func (s *Status) Progress() float64 {
+	total, _ := strconv.ParseFloat(s.TotalLength, 64)
+	completed, _ := strconv.ParseFloat(s.CompletedLength, 64)
+	if total == 0 {
+		return 0
+	}
+	return completed / total
+}
+
+func (c *Client) options() map[string]string {
+	if c.Options == nil {
+		return map[string]string{}
+	}
+	return c.Options
+}
+
+// call will make an RPC call, prepending the secret token to the params
+func (c *Client) call(method string, result interface{}, params ...interface{}) error {
+	if c.Secret != "" {
+		params = append([]interface{}{"token:" + c.Secret}, params...)
+	}
+	body, err := json.Marshal(request{
+		JSONRPC: "2.0",
+		ID:      strconv.FormatUint(atomic.AddUint64(&c.id, 1), 10),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient().Post(c.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Println("Counldn't make the POST ", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	// aria2 answers errors with a 400 and a JSON-RPC error object
+	response := &response{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("aria2: unexpected status %s", resp.Status)
+		}
+		log.Println("Couln't unmarshall result : ", err)
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,140 @@
+package aria2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+const testMagnet = "magnet:?xt=urn:btih:156B69B8643BD11849A5D8F2122E13FBB61BD041&dn=Slackware+14.1+x86_64+DVD+ISO"
+
+// fakeRPC returns a JSON-RPC stub answering with the given results by
+// method, the params of every call are recorded
+func fakeRPC(t *testing.T, results map[string][]string, calls *[][]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Couldn't decode the request")
+		}
+		if req.Params[0] != "token:secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"id":%q,"jsonrpc":"2.0","error":{"code":1,"message":"Unauthorized"}}`, req.ID)
+			return
+		}
+		*calls = append(*calls, append([]interface{}{req.Method}, req.Params[1:]...))
+
+		answers := results[req.Method]
+		if len(answers) == 0 {
+			t.Errorf("Unexpected call to %s", req.Method)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		results[req.Method] = answers[1:]
+		fmt.Fprintf(w, `{"id":%q,"jsonrpc":"2.0","result":%s}`, req.ID, answers[0])
+	}))
+}
+
+func TestSendMagnet(t *testing.T) {
+	var calls [][]interface{}
+	ts := fakeRPC(t, map[string][]string{"aria2.addUri": {`"2089b05ecca3d828"`, `"2089b05ecca3d829"`}}, &calls)
+	defer ts.Close()
+
+	c := New(ts.URL, "secret")
+	c.Options = map[string]string{"dir": "/data"}
+
+	var sink strikeapi.Sink = c
+	if err := sink.Send(&strikeapi.Torrent{MagnetURI: testMagnet}); err != nil {
+		t.Fatalf("Error sending the torrent : %s", err)
+	}
+	gid, err := c.Add(&strikeapi.Torrent{MagnetURI: testMagnet})
+	if err != nil {
+		t.Fatalf("Error adding the torrent : %s", err)
+	}
+	if gid != "2089b05ecca3d829" {
+		t.Errorf("Bad GID %q", gid)
+	}
+
+	call := []interface{}{"aria2.addUri", []interface{}{testMagnet}, map[string]interface{}{"dir": "/data"}}
+	expected := [][]interface{}{call, call}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Bad calls : %+v", calls)
+	}
+}
+
+func TestAddTorrent(t *testing.T) {
+	var calls [][]interface{}
+	ts := fakeRPC(t, map[string][]string{"aria2.addTorrent": {`"d270c8a2d1f2ad47"`}}, &calls)
+	defer ts.Close()
+
+	gid, err := New(ts.URL, "secret").AddTorrent([]byte("d4:infoe"))
+	if err != nil {
+		t.Fatalf("Error adding the torrent : %s", err)
+	}
+	if gid != "d270c8a2d1f2ad47" || calls[0][1] != "ZDQ6aW5mb2U=" {
+		t.Errorf("Bad call : %+v", calls)
+	}
+}
+
+func TestBadSecret(t *testing.T) {
+	var calls [][]interface{}
+	ts := fakeRPC(t, nil, &calls)
+	defer ts.Close()
+
+	_, err := New(ts.URL, "wrong").AddURI(testMagnet)
+	if e, ok := err.(*Error); !ok || e.Message != "Unauthorized" {
+		t.Errorf("Should get an Unauthorized error, got %v", err)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	var calls [][]interface{}
+	ts := fakeRPC(t, map[string][]string{"aria2.tellStatus": {
+		`{"gid":"a","status":"complete","totalLength":"0","completedLength":"0","followedBy":["b"]}`,
+		`{"gid":"b","status":"active","totalLength":"2000","completedLength":"500"}`,
+		`{"gid":"b","status":"complete","totalLength":"2000","completedLength":"2000"}`,
+	}}, &calls)
+	defer ts.Close()
+
+	var progress []float64
+	status, err := New(ts.URL, "secret").Poll(context.Background(), "a", time.Millisecond, func(s *Status) {
+		progress = append(progress, s.Progress())
+	})
+	if err != nil {
+		t.Fatalf("Error polling : %s", err)
+	}
+	if status.GID != "b" || status.Status != "complete" {
+		t.Errorf("Bad final status : %+v", status)
+	}
+	if !reflect.DeepEqual(progress, []float64{0, 0.25, 1}) {
+		t.Errorf("Bad progress : %v", progress)
+	}
+	if calls[1][1] != "b" {
+		t.Errorf("Should follow the download started by the magnet")
+	}
+}
+
+func TestPollCancel(t *testing.T) {
+	var calls [][]interface{}
+	ts := fakeRPC(t, map[string][]string{"aria2.tellStatus": {
+		`{"gid":"a","status":"paused","totalLength":"2000","completedLength":"500"}`,
+	}}, &calls)
+	defer ts.Close()
+
+	// A paused download never ends
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	status, err := New(ts.URL, "secret").Poll(ctx, "a", time.Hour, nil)
+	if err != context.DeadlineExceeded || status != nil {
+		t.Errorf("Should stop with the context, got %+v %v", status, err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("Bad calls : %+v", calls)
+	}
+}
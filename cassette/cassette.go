@@ -0,0 +1,257 @@
+// Package cassette records the HTTP interactions with an API into a cassette
+// file, and replays them to run the tests offline against real answers:
+//
+//	t, err := cassette.New("testdata/search.json", cassette.ModeFromEnv())
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	strikeapi.HTTPClient = &http.Client{Transport: t}
+//
+// The requests are matched on their method, their path and their query with
+// sorted parameters, the host is ignored. The responses only keep their
+// Content-Type header. The same request recorded several
+// times is replayed in the same order, the last answer is repeated after.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// RecordEnv is the environment variable switching ModeFromEnv to recording
+const RecordEnv = "CASSETTE_RECORD"
+
+// Version is the version of the cassette files
+const Version = 1
+
+// ErrNotRecorded is returned in replay mode for a request missing from the
+// cassette
+var ErrNotRecorded = errors.New("request not recorded")
+
+// Mode tells whether a Transport replays or records
+type Mode int
+
+// Modes
+const (
+	// ModeReplay serves the interactions of the cassette without the network
+	ModeReplay Mode = iota
+	// ModeRecord sends the requests and records the interactions, replacing
+	// the cassette
+	ModeRecord
+)
+
+// ModeFromEnv will return ModeRecord when RecordEnv is set, ModeReplay
+// otherwise
+func ModeFromEnv() Mode {
+	if os.Getenv(RecordEnv) != "" {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// Interaction represents a request and its response
+type Interaction struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Query is the normalized query of the request
+	Query       string `json:"query"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	// Body is the body of the response when it's text, BinaryBody holds the
+	// others
+	Body       string `json:"body,omitempty"`
+	BinaryBody []byte `json:"binary_body,omitempty"`
+}
+
+// cassette is the content of a file
+type cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Transport represents a recording or replaying http.RoundTripper
+type Transport struct {
+	// Transport sends the requests in ModeRecord, http.DefaultTransport when
+	// nil
+	Transport http.RoundTripper
+	// IgnoreParams are removed from the queries before they are matched and
+	// recorded, like an API key
+	IgnoreParams []string
+
+	mode Mode
+	path string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	// replayed counts the answers of each request already replayed
+	replayed map[string]int
+}
+
+// New will create a Transport using the cassette at path, it's loaded in
+// ModeReplay and created when the first interaction is recorded in
+// ModeRecord
+func New(path string, mode Mode) (*Transport, error) {
+	t := &Transport{mode: mode, path: path, replayed: map[string]int{}}
+	if mode == ModeRecord {
+		return t, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := cassette{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s : %s", path, err)
+	}
+	if c.Version != Version {
+		return nil, fmt.Errorf("%s : unsupported cassette version %d", path, c.Version)
+	}
+	t.interactions = c.Interactions
+	return t, nil
+}
+
+// Interactions will return the interactions of the cassette
+func (t *Transport) Interactions() []Interaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Interaction{}, t.interactions...)
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	query := t.normalize(req.URL.Query())
+	if t.mode == ModeRecord {
+		return t.record(req, query)
+	}
+	return t.replay(req, query)
+}
+
+// replay will answer the next interaction matching the request
+func (t *Transport) replay(req *http.Request, query string) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := req.Method + " " + req.URL.Path + "?" + query
+	matches := []*Interaction{}
+	for i := range t.interactions {
+		in := &t.interactions[i]
+		if in.Method == req.Method && in.Path == req.URL.Path && in.Query == query {
+			matches = append(matches, in)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%s : %w", key, ErrNotRecorded)
+	}
+	n := t.replayed[key]
+	t.replayed[key]++
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	return matches[n].response(req), nil
+}
+
+// record will send the request and record its response
+func (t *Transport) record(req *http.Request, query string) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	in := Interaction{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Query:       query,
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if utf8.Valid(body) {
+		in.Body = string(body)
+	} else {
+		in.BinaryBody = body
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interactions = append(t.interactions, in)
+	if err := t.save(); err != nil {
+		return nil, err
+	}
+	// The caller gets every header of the response, only the Content-Type is
+	// recorded
+	recorded := in.response(req)
+	recorded.Header = resp.Header.Clone()
+	return recorded, nil
+}
+
+// save will write the cassette, the interactions are kept in their order
+func (t *Transport) save() error {
+	data, err := json.MarshalIndent(cassette{Version: Version, Interactions: t.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(t.path, append(data, '\n'), 0644)
+}
+
+// normalize will remove the ignored parameters of a query and sort the
+// others with their values
+func (t *Transport) normalize(query url.Values) string {
+	for _, param := range t.IgnoreParams {
+		query.Del(param)
+	}
+	for _, values := range query {
+		sort.Strings(values)
+	}
+	// Encode sorts the keys
+	return query.Encode()
+}
+
+// response will build the response of an interaction
+func (in *Interaction) response(req *http.Request) *http.Response {
+	body := []byte(in.Body)
+	if in.BinaryBody != nil {
+		body = in.BinaryBody
+	}
+	header := http.Header{}
+	if in.ContentType != "" {
+		header.Set("Content-Type", in.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// String will describe an interaction
+func (in *Interaction) String() string {
+	return fmt.Sprintf("%s %s?%s : %d", in.Method, in.Path, in.Query, in.Status)
+}
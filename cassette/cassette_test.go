@@ -0,0 +1,189 @@
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/strikeapitest"
+)
+
+// useTransport will send the requests of strikeapi through a transport
+func useTransport(t http.RoundTripper) func() {
+	client := strikeapi.HTTPClient
+	strikeapi.HTTPClient = &http.Client{Transport: t}
+	return func() { strikeapi.HTTPClient = client }
+}
+
+// calls make the requests recorded and replayed
+func calls() ([]interface{}, error) {
+	results := []interface{}{}
+	torrents, err := strikeapi.SearchWithCategory("linux", strikeapi.Applications)
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, torrents)
+	if torrents, err = strikeapi.GetTopTorrents(""); err != nil {
+		return nil, err
+	}
+	results = append(results, torrents)
+	description, err := strikeapi.GetDescription("B425907E5755031BDA4A8D1B6DCCACA97DA14C04")
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, description)
+	data, err := strikeapi.GetTorrentFile("B425907E5755031BDA4A8D1B6DCCACA97DA14C04")
+	if err != nil {
+		return nil, err
+	}
+	return append(results, data), nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassettes", "strike.json")
+
+	s := strikeapitest.NewServer(strikeapi.Torrent{
+		Title:    "Arch Linux 2015.01.01 (x86/x64)",
+		Hash:     "B425907E5755031BDA4A8D1B6DCCACA97DA14C04",
+		Category: strikeapi.Applications,
+		Seeds:    645,
+	})
+	s.SetDescription("B425907E5755031BDA4A8D1B6DCCACA97DA14C04", "Arch Linux")
+	s.SetTorrentFile("B425907E5755031BDA4A8D1B6DCCACA97DA14C04", []byte{'d', 0xff, 0xfe, 'e'})
+	restore := s.Use()
+	defer restore()
+
+	recorder, err := New(path, ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer useTransport(recorder)()
+	recorded, err := calls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.Interactions()) != 5 {
+		t.Errorf("Should record 5 interactions, got %v", recorder.Interactions())
+	}
+
+	// The replay works without the server
+	s.Close()
+	player, err := New(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer useTransport(player)()
+	replayed, err := calls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recorded, replayed) {
+		t.Errorf("Bad replay %v, expected %v", replayed, recorded)
+	}
+
+	if _, err := strikeapi.Search("windows"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Should get an ErrNotRecorded, got %v", err)
+	}
+}
+
+func TestRecordHeaders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Ratelimit-Remaining", "41")
+		fmt.Fprintln(w, `{"statuscode":200,"message":12}`)
+	}))
+	defer ts.Close()
+
+	recorder, err := New(filepath.Join(dir, "headers.json"), ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("GET", ts.URL+"/api/v2/torrents/count/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := recorder.RoundTrip(req)
+	if err != nil || resp.Header.Get("X-Ratelimit-Remaining") != "41" || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("Should get the headers while recording, got %+v %v", resp, err)
+	}
+	resp.Body.Close()
+
+	player, err := New(filepath.Join(dir, "headers.json"), ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = player.RoundTrip(req); err != nil || resp.Header.Get("X-Ratelimit-Remaining") != "" {
+		t.Errorf("Should only replay the Content-Type, got %+v %v", resp, err)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	player, err := New(filepath.Join("testdata", "strike.json"), ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player.IgnoreParams = []string{"apikey"}
+	defer useTransport(player)()
+	strikeapi.SchemaValidation = strikeapi.ValidateStrict
+	defer func() { strikeapi.SchemaValidation = strikeapi.ValidateOff }()
+	// The cassette holds answers shaped like the ones of the real API, under
+	// its endpoint
+	endpoint := strikeapi.APIEndpoint
+	strikeapi.APIEndpoint = "https://getstrike.net/api/v2"
+	defer func() { strikeapi.APIEndpoint = endpoint }()
+
+	// The same request is replayed in order, then the last answer repeats
+	for _, seeds := range []int{645, 650, 650} {
+		torrents, err := strikeapi.Search("arch linux")
+		if err != nil || len(torrents) != 1 || torrents[0].Seeds != seeds {
+			t.Errorf("Should get %d seeds, got %+v %v", seeds, torrents, err)
+		}
+	}
+
+	// The queries are matched whatever the order and the ignored parameters
+	req, err := http.NewRequest("GET", "http://example.com/api/v2/torrents/search/?subcategory=Linux&apikey=secret&phrase=arch&category=Applications", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := player.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("Bad response %+v %v", resp, err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != `{"results":0,"statuscode":404,"responsetime":0.001,"torrents":[]}`+"\n" {
+		t.Errorf("Bad body %s", body)
+	}
+
+	if _, err := New(filepath.Join("testdata", "missing.json"), ModeReplay); err == nil {
+		t.Error("Should fail to replay a missing cassette")
+	}
+}
+
+func TestModeFromEnv(t *testing.T) {
+	os.Setenv(RecordEnv, "1")
+	if ModeFromEnv() != ModeRecord {
+		t.Error("Should record")
+	}
+	os.Unsetenv(RecordEnv)
+	if ModeFromEnv() != ModeReplay {
+		t.Error("Should replay")
+	}
+}
@@ -0,0 +1,37 @@
+// Command strike-torznab serves the Strike API as a Torznab indexer for
+// Sonarr, Radarr and similar tools.
+//
+// Usage:
+//
+//	strike-torznab -listen :9117 -apikey secret [-safe]
+//
+// The indexer URL to configure is then http://host:9117/ with the API path
+// /api.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/torznab"
+)
+
+func main() {
+	listen := flag.String("listen", ":9117", "address to listen on")
+	apiKey := flag.String("apikey", "", "API key required from the clients")
+	endpoint := flag.String("endpoint", strikeapi.APIEndpoint, "Strike API endpoint")
+	title := flag.String("title", "Strike", "name of the indexer")
+	safe := flag.Bool("safe", false, "hide the adult torrents and reject the searches of the XXX categories")
+	flag.Parse()
+
+	strikeapi.APIEndpoint = *endpoint
+	strikeapi.SafeMode = *safe
+
+	mux := http.NewServeMux()
+	mux.Handle("/api", &torznab.Handler{APIKey: *apiKey, Title: *title})
+
+	log.Printf("Listening on %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, mux))
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/dedupe"
+	"github.com/PouuleT/go-strikeapi/format"
+	"github.com/PouuleT/go-strikeapi/history"
+	"github.com/PouuleT/go-strikeapi/store"
+	"github.com/PouuleT/go-strikeapi/tui"
+)
+
+func runSearch(opts *options, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage("search")
+	}
+
+	q := strikeapi.SearchQuery{
+		Phrase:      strings.Join(args, " "),
+		Category:    opts.category,
+		SubCategory: opts.subCategory,
+	}
+	torrents, err := q.Search()
+	if err != nil {
+		return err
+	}
+	return writeTorrents(opts, torrents, stdout)
+}
+
+func runInfo(opts *options, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage("info")
+	}
+
+	torrents, err := strikeapi.GetTorrentsInfos(args)
+	if err != nil {
+		return err
+	}
+	return writeTorrents(opts, torrents, stdout)
+}
+
+func runTop(opts *options, args []string, stdout io.Writer) error {
+	if len(args) > 1 {
+		return errUsage("top")
+	}
+
+	category := opts.category
+	if len(args) == 1 {
+		category = args[0]
+	}
+	torrents, err := strikeapi.GetTopTorrents(category)
+	if err != nil {
+		return err
+	}
+	return writeTorrents(opts, torrents, stdout)
+}
+
+func runCount(opts *options, args []string, stdout io.Writer) error {
+	if len(args) != 0 {
+		return errUsage("count")
+	}
+
+	count, err := strikeapi.CountTorrents()
+	if err != nil {
+		return err
+	}
+	return writeValue(opts, "count", count, stdout)
+}
+
+func runDescribe(opts *options, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errUsage("describe")
+	}
+
+	desc, err := strikeapi.GetDescription(args[0])
+	if err != nil {
+		return err
+	}
+	return writeValue(opts, "description", desc, stdout)
+}
+
+func runLink(opts *options, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errUsage("link")
+	}
+
+	link, err := strikeapi.GetDownloadLink(args[0])
+	if err != nil {
+		return err
+	}
+	return writeValue(opts, "link", link, stdout)
+}
+
+func runTUI(opts *options, args []string, stdout io.Writer) error {
+	config := tui.Config{
+		Search: func(phrase string) ([]strikeapi.Torrent, error) {
+			torrents, err := strikeapi.SearchWithCategoryAndSubCategory(phrase, opts.category, opts.subCategory)
+			if opts.dedupe {
+				torrents = dedupe.Unique(torrents)
+			}
+			opts.trackers.applyTrackers(torrents)
+			return torrents, err
+		},
+		Copy: tui.OSC52Copy(stdout),
+	}
+	if opts.sink != "" {
+		sink, err := newSink(opts.sink)
+		if err != nil {
+			return &usageError{err.Error()}
+		}
+		config.Sink = sink
+		if opts.dedupe {
+			config.Sink = dedupe.NewSink(sink)
+		}
+	}
+
+	m := tui.New(config)
+	if len(args) > 0 {
+		m.Query = strings.Join(args, " ")
+		if cmd := m.Update(tui.KeyMsg("enter")); cmd != nil {
+			m.Update(cmd())
+		}
+	}
+	return tui.Run(m, os.Stdin, stdout)
+}
+
+func runLocal(opts *options, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage("local")
+	}
+	if opts.store == "" {
+		return &usageError{"local needs a store, set -store or store in the config"}
+	}
+
+	torrents, err := strikeapi.SearchLocal(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	return writeTorrents(opts, torrents, stdout)
+}
+
+func runHistory(opts *options, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage("history")
+	}
+	if opts.localStore == nil {
+		return &usageError{"history needs a store, set -store or store in the config"}
+	}
+
+	trends := []*history.Trend{}
+	for _, hash := range args {
+		samples, err := opts.localStore.Samples(hash)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		trends = append(trends, history.Analyze(strings.ToUpper(hash), samples))
+	}
+	if len(trends) == 0 {
+		return errNoResults
+	}
+
+	switch opts.output {
+	case format.JSON:
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(trends)
+	case format.NDJSON:
+		enc := json.NewEncoder(stdout)
+		for _, trend := range trends {
+			if err := enc.Encode(trend); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HASH\tSAMPLES\tSEEDS\tLEECHES\tPEAK\tGROWTH/DAY\tHALF-LIFE\tSTATUS")
+	for _, t := range trends {
+		halfLife := "-"
+		if t.HalfLife > 0 {
+			halfLife = t.HalfLife.Round(time.Minute).String()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%+.1f\t%s\t%s\n", t.Hash, t.Samples, t.Seeds, t.Leeches, t.Peak, t.GrowthRate, halfLife, t.Status)
+	}
+	return tw.Flush()
+}
+
+// writeTorrents will write a list of torrents in the requested format
+func writeTorrents(opts *options, torrents []strikeapi.Torrent, stdout io.Writer) error {
+	if len(torrents) == 0 {
+		return errNoResults
+	}
+
+	f, err := format.New(opts.output, opts.columnList())
+	if err != nil {
+		return &usageError{err.Error()}
+	}
+	if opts.dedupe {
+		torrents = dedupe.Unique(torrents)
+	}
+	opts.trackers.applyTrackers(torrents)
+	return f.Format(stdout, torrents)
+}
+
+// writeValue will write a single value in the requested format
+func writeValue(opts *options, name string, value interface{}, stdout io.Writer) error {
+	switch opts.output {
+	case format.JSON, format.NDJSON:
+		return json.NewEncoder(stdout).Encode(map[string]interface{}{name: value})
+	}
+	_, err := fmt.Fprintln(stdout, value)
+	return err
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/format"
+)
+
+// completeCommand is the hidden command called by the completion scripts
+// with the words typed so far, the last one being completed
+const completeCommand = "__complete"
+
+// completionScripts are the completion scripts of the shells, to load with
+//
+//	source <(strike completion bash)
+//	source <(strike completion zsh)
+//	strike completion fish | source
+var completionScripts = map[string]string{
+	"bash": `_strike() {
+	local candidate
+	COMPREPLY=()
+	while IFS= read -r candidate; do
+		[ -n "$candidate" ] && COMPREPLY+=("$(printf '%q' "$candidate")")
+	done < <(strike __complete "${COMP_WORDS[@]:1:COMP_CWORD}")
+}
+complete -o default -F _strike strike
+`,
+	"zsh": `_strike() {
+	local -a candidates
+	candidates=(${(f)"$(strike __complete "${(@)words[2,CURRENT]}")"})
+	compadd -a candidates
+}
+compdef _strike strike
+`,
+	"fish": `function __strike_complete
+	set -l tokens (commandline -opc)
+	set -e tokens[1]
+	strike __complete $tokens (commandline -ct)""
+end
+complete -c strike -f -a '(__strike_complete)'
+`,
+}
+
+// shells are the shells with a completion script
+var shells = []string{"bash", "fish", "zsh"}
+
+func runCompletion(opts *options, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errUsage("completion")
+	}
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		return &usageError{fmt.Sprintf("unknown shell %q, use bash, zsh or fish", args[0])}
+	}
+	_, err := io.WriteString(stdout, script)
+	return err
+}
+
+// valueFlags are the flags taking a value
+var valueFlags = map[string]bool{
+	"category":    true,
+	"subcategory": true,
+	"endpoint":    true,
+	"timeout":     true,
+	"output":      true,
+	"columns":     true,
+	"sink":        true,
+	"store":       true,
+}
+
+// complete will return the candidates for the last word
+func complete(words []string, cfg *config) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	for i := range words {
+		words[i] = unquote(words[i])
+	}
+	current, previous := words[len(words)-1], words[:len(words)-1]
+
+	// -flag=value
+	if name, value, ok := splitFlag(current); ok && valueFlags[name] {
+		prefix := current[:len(current)-len(value)]
+		return withPrefix(prefix, filter(flagValues(name, value, previous, cfg), value))
+	}
+	// -flag = value, bash splits the words on "="
+	if n := len(previous); n >= 2 && previous[n-1] == "=" {
+		if name, _, ok := splitFlag(previous[n-2]); ok && valueFlags[name] {
+			return filter(flagValues(name, current, previous[:n-2], cfg), current)
+		}
+	}
+	// -flag value
+	if len(previous) > 0 {
+		if name, _, ok := splitFlag(previous[len(previous)-1]); ok && valueFlags[name] && !strings.Contains(previous[len(previous)-1], "=") {
+			return filter(flagValues(name, current, previous, cfg), current)
+		}
+	}
+	if strings.HasPrefix(current, "-") {
+		names := []string{}
+		newFlagSet(&options{}, cfg, ioutil.Discard).VisitAll(func(f *flag.Flag) {
+			names = append(names, "-"+f.Name)
+		})
+		return filter(names, current)
+	}
+
+	// Positional arguments
+	positional := []string{}
+	for i := 0; i < len(previous); i++ {
+		if name, _, ok := splitFlag(previous[i]); ok {
+			if valueFlags[name] && !strings.Contains(previous[i], "=") {
+				i++
+			}
+			continue
+		}
+		positional = append(positional, previous[i])
+	}
+
+	switch {
+	case len(positional) == 0:
+		return filter(commandNames, current)
+	case len(positional) == 1 && positional[0] == "top":
+		return filter(strikeapi.Categories, current)
+	case len(positional) == 1 && positional[0] == "completion":
+		return filter(shells, current)
+	}
+	return nil
+}
+
+// flagValues will return the possible values of a flag
+func flagValues(name, value string, words []string, cfg *config) []string {
+	switch name {
+	case "category":
+		return strikeapi.Categories
+	case "subcategory":
+		if category := flagValue("category", words); category != "" {
+			return strikeapi.SubCategories[category]
+		}
+		return allSubCategories()
+	case "output":
+		return []string{format.Table, format.JSON, format.NDJSON, format.CSV, "template="}
+	case "columns":
+		// Complete the last column of the list
+		prefix := ""
+		if i := strings.LastIndex(value, ","); i >= 0 {
+			prefix = value[:i+1]
+		}
+		return withPrefix(prefix, format.ColumnNames())
+	case "sink":
+		names := []string{}
+		for _, s := range cfg.Sinks {
+			names = append(names, s.Name)
+		}
+		return names
+	}
+	return nil
+}
+
+// flagValue will return the last value given to a flag in the words
+func flagValue(name string, words []string) string {
+	value := ""
+	for i, word := range words {
+		n, v, ok := splitFlag(word)
+		if !ok || n != name {
+			continue
+		}
+		if strings.Contains(word, "=") {
+			value = v
+		} else if i+1 < len(words) {
+			value = words[i+1]
+		}
+	}
+	return value
+}
+
+// allSubCategories will return every subcategory, sorted and without
+// duplicates
+func allSubCategories() []string {
+	seen := map[string]bool{}
+	all := []string{}
+	for _, subCategories := range strikeapi.SubCategories {
+		for _, s := range subCategories {
+			if !seen[s] {
+				seen[s] = true
+				all = append(all, s)
+			}
+		}
+	}
+	sort.Strings(all)
+	return all
+}
+
+// splitFlag will split "-name=value" or "--name", ok is false for words
+// which aren't flags
+func splitFlag(word string) (name, value string, ok bool) {
+	if !strings.HasPrefix(word, "-") || word == "-" || word == "--" {
+		return "", "", false
+	}
+	name = strings.TrimLeft(word, "-")
+	if i := strings.Index(name, "="); i >= 0 {
+		name, value = name[:i], name[i+1:]
+	}
+	return name, value, true
+}
+
+// unquote will remove the shell quoting of a word, e.g. "Highres\ Movies"
+func unquote(word string) string {
+	var b strings.Builder
+	var quote rune
+	escaped := false
+	for _, r := range word {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// filter will keep the candidates starting with the prefix, ignoring the case
+func filter(candidates []string, prefix string) []string {
+	matches := []string{}
+	for _, c := range candidates {
+		if strings.HasPrefix(strings.ToLower(c), strings.ToLower(prefix)) {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
+
+func withPrefix(prefix string, candidates []string) []string {
+	prefixed := make([]string, len(candidates))
+	for i, c := range candidates {
+		prefixed[i] = prefix + c
+	}
+	return prefixed
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	cfg := &config{Sinks: []sinkConfig{{Name: "home", URL: "transmission://nas:9091"}, {Name: "seedbox", URL: "aria2://box"}}}
+
+	tests := []struct {
+		words    []string
+		expected []string
+	}{
+		{nil, commandNames},
+		{[]string{"s"}, []string{"search"}},
+		{[]string{"-endpoint", "http://x", "co"}, []string{"count", "completion"}},
+		{[]string{"top", "m"}, []string{"Movies", "Music"}},
+		{[]string{"top", "Movies", ""}, []string{}},
+		{[]string{"completion", "f"}, []string{"fish"}},
+		{[]string{"search", "-cat"}, []string{"-category"}},
+		{[]string{"search", "-category", "a"}, []string{"Anime", "Applications"}},
+		{[]string{"search", "-category", "Movies", "-subcategory", "h"}, []string{"Highres Movies"}},
+		{[]string{"search", "-category", `"Anime"`, "-subcategory", `"English`}, []string{"English-translated"}},
+		{[]string{"search", "--category=TV", "-subcategory="}, []string{"-subcategory=Documentary", "-subcategory=Other TV"}},
+		{[]string{"search", "-category", "=", "Boo"}, []string{"Books"}},
+		{[]string{"search", "-subcategory", "Highres\\ M"}, []string{"Highres Movies"}},
+		{[]string{"-output", "nd"}, []string{"ndjson"}},
+		{[]string{"-columns", "title,se"}, []string{"title,seeds"}},
+		{[]string{"tui", "-sink", ""}, []string{"home", "seedbox"}},
+		{[]string{"-timeout", ""}, []string{}},
+	}
+
+	for _, test := range tests {
+		got := complete(test.words, cfg)
+		if got == nil {
+			got = []string{}
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q : expected %q, got %q", test.words, test.expected, got)
+		}
+	}
+}
+
+func TestCompletionCommand(t *testing.T) {
+	for _, shell := range shells {
+		code, stdout, _ := runStrike("completion", shell)
+		if code != ExitOK || !strings.Contains(stdout, "strike __complete") {
+			t.Errorf("Bad %s script %d :\n%s", shell, code, stdout)
+		}
+	}
+
+	if code, _, _ := runStrike("completion", "powershell"); code != ExitUsage {
+		t.Errorf("Should exit with %d, got %d", ExitUsage, code)
+	}
+
+	code, stdout, _ := runStrike("__complete", "search", "-category", "Movies", "-subcategory", "u")
+	if code != ExitOK || stdout != "UltraHD\n" {
+		t.Errorf("Bad candidates %d : %q", code, stdout)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `# strike config
+endpoint = "http://localhost:1234/" # overridden by -endpoint
+output = 'csv'
+columns = ["hash", "magnet"]
+timeout = "5s"
+
+[trackers]
+policy = "replace"
+list = ["udp://tracker.example.com:80"]
+`
+
+func TestParseTOML(t *testing.T) {
+	doc, err := parseTOML(`
+title = "a # not a comment" # a comment
+escaped = "a \" # b" # a comment
+path = 'C:\' # a comment
+count = 1_000
+ratio = 0.5
+enabled = true
+list = ["a, b", 'c', 2, "d \", e"]
+
+[table]
+key = "value"
+
+[[items]]
+name = "first"
+
+[[items]]
+name = "second"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"title":   "a # not a comment",
+		"escaped": `a " # b`,
+		"path":    `C:\`,
+		"count":   int64(1000),
+		"ratio":   0.5,
+		"enabled": true,
+		"list":    []interface{}{"a, b", "c", int64(2), `d ", e`},
+		"table":   map[string]interface{}{"key": "value"},
+		"items": []map[string]interface{}{
+			{"name": "first"},
+			{"name": "second"},
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("Bad document : %#v", doc)
+	}
+
+	for _, bad := range []string{
+		"key",
+		`key = "unterminated`,
+		"key = nope",
+		"[table]\n[table]",
+		"key = 1\n[[key]]",
+		"[table",
+		"list = [\n\t\"a\",\n]",
+	} {
+		if _, err := parseTOML(bad); err == nil {
+			t.Errorf("%q should fail", bad)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(`
+endpoint = "https://example.com/api/"
+output = "json"
+columns = "title,seeds"
+timeout = 10
+dedupe = true
+safe_mode = true
+safe_keywords = ["nsfw", "adult only"]
+
+[trackers]
+policy = "append"
+list = ["udp://tracker.example.com:80"]
+
+[rate_limit]
+requests = 4
+per = "2s"
+
+[uploaders]
+allow = ["trusted"]
+deny = "spammer,faker"
+min_trust = 0.5
+
+[[sinks]]
+name = "home"
+url = "transmission://nas:9091"
+
+[[sinks]]
+name = "seedbox"
+url = "aria2://:secret@box:6800"
+default = true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &config{
+		Endpoint:     "https://example.com/api/",
+		Output:       "json",
+		Columns:      "title,seeds",
+		Timeout:      10 * time.Second,
+		Dedupe:       true,
+		SafeMode:     true,
+		SafeKeywords: []string{"nsfw", "adult only"},
+		Trackers:     trackerPolicy{Policy: TrackersAppend, List: []string{"udp://tracker.example.com:80"}},
+		RateLimit:    rateLimit{Requests: 4, Per: 2 * time.Second},
+		Uploaders:    uploaderConfig{Allow: []string{"trusted"}, Deny: []string{"spammer", "faker"}, MinTrust: 0.5},
+		Sinks: []sinkConfig{
+			{Name: "home", URL: "transmission://nas:9091"},
+			{Name: "seedbox", URL: "aria2://:secret@box:6800", Default: true},
+		},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Bad config : %+v", cfg)
+	}
+	if cfg.defaultSink() != "seedbox" || cfg.sinkURL("home") != "transmission://nas:9091" || cfg.sinkURL("qbittorrent://x") != "qbittorrent://x" {
+		t.Errorf("Bad sink resolution")
+	}
+	if transport, ok := cfg.transport().(*rateLimitedTransport); !ok || transport.Interval != 500*time.Millisecond {
+		t.Errorf("Bad transport : %+v", cfg.transport())
+	}
+
+	for _, bad := range []string{
+		`timeout = "soon"`,
+		`output = 1`,
+		"[trackers]\npolicy = \"drop\"",
+		"[[sinks]]\nname = \"home\"",
+		"sinks = \"home\"",
+		"trackers = 1",
+		"[uploaders]\nmin_trust = 2",
+		"[uploaders]\nmin_trust = \"high\"",
+	} {
+		if _, err := parseConfig(bad); err == nil {
+			t.Errorf("%q should fail", bad)
+		}
+	}
+}
+
+func TestTrackerPolicy(t *testing.T) {
+	magnet := "magnet:?xt=urn:btih:ABC&dn=Slackware&tr=udp%3A%2F%2Fold.example.com%3A80"
+	list := []string{"udp://new.example.com:80", "udp://old.example.com:80"}
+
+	tests := map[string]string{
+		TrackersKeep:    magnet,
+		TrackersStrip:   "magnet:?xt=urn:btih:ABC&dn=Slackware",
+		TrackersAppend:  magnet + "&tr=udp%3A%2F%2Fnew.example.com%3A80",
+		TrackersReplace: "magnet:?xt=urn:btih:ABC&dn=Slackware&tr=udp%3A%2F%2Fnew.example.com%3A80&tr=udp%3A%2F%2Fold.example.com%3A80",
+	}
+	for policy, expected := range tests {
+		if got := (trackerPolicy{Policy: policy, List: list}).apply(magnet); got != expected {
+			t.Errorf("%s : expected %s, got %s", policy, expected, got)
+		}
+	}
+}
+
+func TestRateLimitedTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: &rateLimitedTransport{Transport: http.DefaultTransport, Interval: 50 * time.Millisecond}}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Requests weren't spaced : %s", elapsed)
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	ts := fakeStrike(map[string]string{"/torrents/search/": searchResponse})
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "strike")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+	if err := ioutil.WriteFile(path, []byte(testConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(ConfigEnv, path)
+	defer os.Unsetenv(ConfigEnv)
+
+	// The config gives the defaults, the flags override them
+	code, stdout, stderr := runStrike("-endpoint", ts.URL, "search", "Slackware")
+	expected := "hash,magnet\n156B69B8643BD11849A5D8F2122E13FBB61BD041,magnet:?xt=urn:btih:156B69B8643BD11849A5D8F2122E13FBB61BD041&tr=udp%3A%2F%2Ftracker.example.com%3A80\n"
+	if code != ExitOK || stdout != expected {
+		t.Errorf("Bad output %d %s :\n%s", code, stderr, stdout)
+	}
+
+	// The denied uploaders are hidden
+	if err := ioutil.WriteFile(path, []byte(testConfig+"[uploaders]\ndeny = [\"nusantara\"]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	code, stdout, stderr = runStrike("-endpoint", ts.URL, "search", "Slackware")
+	if code != ExitNoResults || stdout != "" {
+		t.Errorf("Should hide the denied uploader, got %d %s :\n%s", code, stderr, stdout)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(testConfig+"[trackers]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr = runStrike("-endpoint", ts.URL, "search", "Slackware")
+	if code != ExitUsage || !strings.Contains(stderr, path) {
+		t.Errorf("A bad config should be a usage error, got %d %s", code, stderr)
+	}
+}
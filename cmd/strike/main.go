@@ -0,0 +1,256 @@
+// Command strike is a command-line client for the Strike API.
+//
+// Usage:
+//
+//	strike [flags] search <phrase...>
+//	strike [flags] info <hash...>
+//	strike [flags] top [category]
+//	strike [flags] count
+//	strike [flags] describe <hash>
+//	strike [flags] link <hash>
+//	strike [flags] tui [phrase...]
+//	strike [flags] local <phrase...>
+//	strike [flags] history <hash...>
+//	strike completion bash|zsh|fish
+//
+// Defaults are read from ~/.config/strike/config.toml, or the file named by
+// $STRIKE_CONFIG, see config for its keys.
+//
+// The exit code is 0 on success, 1 when nothing was found, 2 on usage
+// errors, 3 on API errors and 4 on network errors.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/format"
+	"github.com/PouuleT/go-strikeapi/store"
+)
+
+// Exit codes
+const (
+	ExitOK        = 0
+	ExitNoResults = 1
+	ExitUsage     = 2
+	ExitAPI       = 3
+	ExitNetwork   = 4
+)
+
+// errNoResults is returned by the commands which found nothing
+var errNoResults = errors.New("no results")
+
+// options are the flags shared by the commands
+type options struct {
+	category    string
+	subCategory string
+	endpoint    string
+	timeout     time.Duration
+	output      string
+	columns     string
+	sink        string
+	store       string
+	dedupe      bool
+	safe        bool
+	trackers    trackerPolicy
+	// localStore is the store opened from the store flag
+	localStore *store.Store
+}
+
+// columnList will split the comma separated columns
+func (o *options) columnList() []string {
+	if o.columns == "" {
+		return nil
+	}
+	return strings.Split(o.columns, ",")
+}
+
+// command runs a subcommand with its arguments
+type command func(opts *options, args []string, stdout io.Writer) error
+
+var commands = map[string]command{
+	"search":     runSearch,
+	"info":       runInfo,
+	"top":        runTop,
+	"count":      runCount,
+	"describe":   runDescribe,
+	"link":       runLink,
+	"tui":        runTUI,
+	"local":      runLocal,
+	"history":    runHistory,
+	"completion": runCompletion,
+}
+
+// commandNames are the subcommands in the order of the usage
+var commandNames = []string{"search", "info", "top", "count", "describe", "link", "tui", "local", "history", "completion"}
+
+// usages are the usages of the subcommands
+var usages = map[string]string{
+	"search":     "search <phrase...>",
+	"info":       "info <hash...>",
+	"top":        "top [category]",
+	"count":      "count",
+	"describe":   "describe <hash>",
+	"link":       "link <hash>",
+	"tui":        "tui [phrase...]",
+	"local":      "local <phrase...>",
+	"history":    "history <hash...>",
+	"completion": "completion bash|zsh|fish",
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run will parse the arguments, run the command and return the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	cfg, err := loadConfig(configPath())
+	if err != nil {
+		fmt.Fprintf(stderr, "strike: %s\n", err)
+		return ExitUsage
+	}
+
+	opts := &options{trackers: cfg.Trackers}
+	flags := newFlagSet(opts, cfg, stderr)
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	name := flags.Arg(0)
+	if name == completeCommand {
+		for _, candidate := range complete(flags.Args()[1:], cfg) {
+			fmt.Fprintln(stdout, candidate)
+		}
+		return ExitOK
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "strike: unknown command %q\n", name)
+		flags.Usage()
+		return ExitUsage
+	}
+
+	// Flags are also accepted after the command name
+	if err := flags.Parse(flags.Args()[1:]); err != nil {
+		return ExitUsage
+	}
+
+	strikeapi.APIEndpoint = opts.endpoint
+	strikeapi.HTTPClient = &http.Client{Timeout: opts.timeout, Transport: cfg.transport()}
+	opts.sink = cfg.sinkURL(opts.sink)
+	strikeapi.SafeMode, strikeapi.SafeKeywords = opts.safe, cfg.SafeKeywords
+	defer func() { strikeapi.SafeMode, strikeapi.SafeKeywords = false, nil }()
+
+	if opts.store != "" {
+		s, err := store.Open(opts.store)
+		if err != nil {
+			fmt.Fprintf(stderr, "strike: %s\n", err)
+			return ExitUsage
+		}
+		defer s.Close()
+		opts.localStore = s
+		strikeapi.LocalStore = s
+		defer func() { strikeapi.LocalStore = nil }()
+	}
+	if policy := cfg.uploaderPolicy(opts.localStore); policy != nil {
+		strikeapi.SearchFilter = policy
+		defer func() { strikeapi.SearchFilter = nil }()
+	}
+
+	err = cmd(opts, flags.Args(), stdout)
+	if err != nil && err != errNoResults {
+		fmt.Fprintf(stderr, "strike: %s\n", err)
+	}
+	return exitCode(err)
+}
+
+// newFlagSet will create the flags, their defaults come from the config
+func newFlagSet(opts *options, cfg *config, stderr io.Writer) *flag.FlagSet {
+	endpoint := orDefault(cfg.Endpoint, strikeapi.APIEndpoint)
+	output := orDefault(cfg.Output, format.Table)
+	columns := orDefault(cfg.Columns, strings.Join(format.DefaultColumns, ","))
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	flags := flag.NewFlagSet("strike", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&opts.category, "category", "", "category to search in")
+	flags.StringVar(&opts.subCategory, "subcategory", "", "subcategory to search in")
+	flags.StringVar(&opts.endpoint, "endpoint", endpoint, "Strike API endpoint")
+	flags.DurationVar(&opts.timeout, "timeout", timeout, "timeout of the requests")
+	flags.StringVar(&opts.output, "output", output, "output format: table, json, ndjson, csv or template=<go template>")
+	flags.StringVar(&opts.sink, "sink", cfg.defaultSink(), "download client receiving the torrents, e.g. transmission://host:9091, or the name of a sink of the config")
+	flags.StringVar(&opts.store, "store", cfg.Store, "file of the local store recording the torrents fetched, searched by the local and history commands")
+	flags.BoolVar(&opts.safe, "safe", cfg.SafeMode, "hide the adult torrents and reject the searches of the XXX categories")
+	flags.BoolVar(&opts.dedupe, "dedupe", cfg.Dedupe, "show only the torrent with the most seeds of each release uploaded several times")
+	flags.StringVar(&opts.columns, "columns", columns, "columns of the table and csv outputs: "+strings.Join(format.ColumnNames(), ","))
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: strike [flags] <command> [arguments]")
+		fmt.Fprintln(stderr, "\nCommands:")
+		for _, name := range commandNames {
+			fmt.Fprintf(stderr, "  %s\n", usages[name])
+		}
+		fmt.Fprintln(stderr, "\nFlags:")
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// usageError represents a bad use of a command
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// errUsage will return the usage error of a command
+func errUsage(name string) error {
+	return &usageError{"usage: strike " + usages[name]}
+}
+
+// exitCode will return the exit code matching an error
+func exitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	if err == errNoResults {
+		return ExitNoResults
+	}
+	// Searching an adult category in safe mode is a bad use
+	if err == strikeapi.ErrUnsafeCategory {
+		return ExitUsage
+	}
+
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		return ExitUsage
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ExitNetwork
+	}
+	return ExitAPI
+}
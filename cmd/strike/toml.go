@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML will parse the subset of TOML used by the config file: tables,
+// arrays of tables, and keys holding strings, integers, floats, booleans or
+// inline arrays of those. The arrays must be written on one line.
+// Tables are returned as map[string]interface{} and arrays of tables as
+// []map[string]interface{}
+func parseTOML(data string) (map[string]interface{}, error) {
+	root := map[string]interface{}{}
+	current := root
+
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		lineErr := func(format string, args ...interface{}) error {
+			return fmt.Errorf("line %d : %s", n+1, fmt.Sprintf(format, args...))
+		}
+
+		switch {
+		case strings.HasPrefix(line, "[["):
+			if !strings.HasSuffix(line, "]]") {
+				return nil, lineErr("bad array of tables %q", line)
+			}
+			name := strings.TrimSpace(line[2 : len(line)-2])
+			tables, _ := root[name].([]map[string]interface{})
+			if _, exists := root[name]; exists && tables == nil {
+				return nil, lineErr("%q is not an array of tables", name)
+			}
+			current = map[string]interface{}{}
+			root[name] = append(tables, current)
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, lineErr("bad table %q", line)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if _, exists := root[name]; exists {
+				return nil, lineErr("table %q defined twice", name)
+			}
+			current = map[string]interface{}{}
+			root[name] = current
+		default:
+			i := strings.Index(line, "=")
+			if i < 0 {
+				return nil, lineErr("expected key = value, got %q", line)
+			}
+			key := strings.Trim(strings.TrimSpace(line[:i]), `"`)
+			value, err := parseTOMLValue(strings.TrimSpace(line[i+1:]))
+			if err != nil {
+				return nil, lineErr("%s", err)
+			}
+			current[key] = value
+		}
+	}
+	return root, nil
+}
+
+// stripComment will remove a comment which isn't inside a string
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			// The basic strings have escapes, not the literal ones
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseTOMLValue(s string) (interface{}, error) {
+	switch {
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	case strings.HasPrefix(s, `"`):
+		if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unterminated array %s, the arrays must be written on one line", s)
+		}
+		values := []interface{}{}
+		for _, item := range splitTOMLArray(s[1 : len(s)-1]) {
+			value, err := parseTOMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+
+	if i, err := strconv.ParseInt(strings.Replace(s, "_", "", -1), 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(strings.Replace(s, "_", "", -1), 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("bad value %s", s)
+}
+
+// splitTOMLArray will split the items of an inline array on the commas
+// outside of strings
+func splitTOMLArray(s string) []string {
+	items := []string{}
+	var quote rune
+	escaped := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	items = append(items, s[start:])
+
+	trimmed := []string{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			trimmed = append(trimmed, item)
+		}
+	}
+	return trimmed
+}
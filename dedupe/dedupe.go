@@ -0,0 +1,288 @@
+// Package dedupe clusters the torrents of the same release uploaded under
+// several hashes, and picks a canonical member of each cluster:
+//
+//	for _, c := range dedupe.Clusters(torrents) {
+//		fmt.Println(c.Canonical.Title, len(c.Members))
+//	}
+//	sink.Send(&dedupe.Unique(torrents)[0])
+//
+// Two torrents are duplicates when they have the same hash, or close sizes
+// and similar file lists, or close sizes and the same parsed release with
+// compatible attributes when a file list is missing.
+package dedupe
+
+import (
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Default settings of a Deduper
+const (
+	DefaultSizeTolerance  = 0.02
+	DefaultFileSimilarity = 0.8
+)
+
+// Cluster represents the duplicates of a release
+type Cluster struct {
+	Canonical strikeapi.Torrent
+	// Members are the torrents of the cluster, in their order, Canonical
+	// included
+	Members []strikeapi.Torrent
+}
+
+// Deduper clusters torrents
+type Deduper struct {
+	// SizeTolerance is the relative difference of size allowed, 0.02 is ±2%,
+	// DefaultSizeTolerance when 0
+	SizeTolerance float64
+	// FileSimilarity is the minimum similarity of the file lists, from 0 to
+	// 1, DefaultFileSimilarity when 0
+	FileSimilarity float64
+	// Better tells whether a torrent is a better canonical member than
+	// another, the one with the most seeds by default
+	Better func(a, b *strikeapi.Torrent) bool
+}
+
+// New will create a Deduper with the default settings
+func New() *Deduper {
+	return &Deduper{
+		SizeTolerance:  DefaultSizeTolerance,
+		FileSimilarity: DefaultFileSimilarity,
+		Better:         MostSeeds,
+	}
+}
+
+// MostSeeds is the default Better, the leeches break the ties
+func MostSeeds(a, b *strikeapi.Torrent) bool {
+	if a.Seeds != b.Seeds {
+		return a.Seeds > b.Seeds
+	}
+	return a.Leeches > b.Leeches
+}
+
+// Clusters will cluster the torrents with the default settings
+func Clusters(torrents []strikeapi.Torrent) []Cluster {
+	return New().Clusters(torrents)
+}
+
+// Unique will return the canonical member of each cluster, with the default
+// settings
+func Unique(torrents []strikeapi.Torrent) []strikeapi.Torrent {
+	return New().Unique(torrents)
+}
+
+// Unique will return the canonical member of each cluster, in the order of
+// the clusters
+func (d *Deduper) Unique(torrents []strikeapi.Torrent) []strikeapi.Torrent {
+	unique := []strikeapi.Torrent{}
+	for _, c := range d.Clusters(torrents) {
+		unique = append(unique, c.Canonical)
+	}
+	return unique
+}
+
+// Clusters will cluster the torrents, the clusters are in the order of their
+// first member
+func (d *Deduper) Clusters(torrents []strikeapi.Torrent) []Cluster {
+	infos := make([]info, len(torrents))
+	for i := range torrents {
+		infos[i] = newInfo(&torrents[i])
+	}
+
+	// Union-find of the duplicates
+	parent := make([]int, len(torrents))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range torrents {
+		for j := i + 1; j < len(torrents); j++ {
+			if find(i) != find(j) && d.duplicates(&infos[i], &infos[j]) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	better := d.Better
+	if better == nil {
+		better = MostSeeds
+	}
+	clusters := []Cluster{}
+	index := map[int]int{}
+	for i := range torrents {
+		root := find(i)
+		c, ok := index[root]
+		if !ok {
+			c = len(clusters)
+			index[root] = c
+			clusters = append(clusters, Cluster{Canonical: torrents[i]})
+		}
+		cluster := &clusters[c]
+		cluster.Members = append(cluster.Members, torrents[i])
+		if better(&torrents[i], &cluster.Canonical) {
+			cluster.Canonical = torrents[i]
+		}
+	}
+	return clusters
+}
+
+// info is what a torrent is compared on
+type info struct {
+	torrent *strikeapi.Torrent
+	release strikeapi.Release
+	// identity is the release without the attributes of the copy
+	identity string
+	files    map[string]bool
+}
+
+func newInfo(t *strikeapi.Torrent) info {
+	r := t.Release()
+	i := info{torrent: t, release: r}
+	i.identity = strings.Join([]string{
+		normalize(r.Title),
+		itoa(r.Year), itoa(r.Season), itoa(r.SeasonEnd), itoa(r.Episode), itoa(r.EpisodeEnd),
+		r.Version, r.Arch,
+	}, "|")
+	if t.FilesInfo != nil && len(t.FilesInfo.FileInfo) > 0 {
+		i.files = map[string]bool{}
+		for _, f := range t.FilesInfo.FileInfo {
+			i.files[file(f)] = true
+		}
+	}
+	return i
+}
+
+// Duplicates will tell whether two torrents are the same release
+func (d *Deduper) Duplicates(a, b *strikeapi.Torrent) bool {
+	infoA, infoB := newInfo(a), newInfo(b)
+	return d.duplicates(&infoA, &infoB)
+}
+
+func (d *Deduper) duplicates(a, b *info) bool {
+	if strings.EqualFold(a.torrent.Hash, b.torrent.Hash) && a.torrent.Hash != "" {
+		return true
+	}
+	if !d.closeSizes(a.torrent.Size, b.torrent.Size) {
+		return false
+	}
+
+	minSimilarity := d.FileSimilarity
+	if minSimilarity == 0 {
+		minSimilarity = DefaultFileSimilarity
+	}
+	similarity, known := similarity(a.files, b.files)
+	if known {
+		// Different file lists aren't the same release
+		return similarity >= minSimilarity
+	}
+	return a.identity == b.identity && compatible(&a.release, &b.release)
+}
+
+// closeSizes will tell whether two sizes are within the tolerance
+func (d *Deduper) closeSizes(a, b float64) bool {
+	if a == 0 || b == 0 {
+		return true
+	}
+	tolerance := d.SizeTolerance
+	if tolerance == 0 {
+		tolerance = DefaultSizeTolerance
+	}
+	return math.Abs(a-b) <= tolerance*math.Max(a, b)
+}
+
+// compatible will tell whether the attributes of the copies match, the
+// unknown ones match anything
+func compatible(a, b *strikeapi.Release) bool {
+	same := func(x, y string) bool {
+		return x == "" || y == "" || strings.EqualFold(x, y)
+	}
+	return same(a.Resolution, b.Resolution) &&
+		same(a.Source, b.Source) &&
+		same(a.Codec, b.Codec) &&
+		same(a.Group, b.Group) &&
+		a.Proper == b.Proper && a.Repack == b.Repack
+}
+
+// similarity will return the Jaccard index of two file lists, known is
+// false when a list is missing
+func similarity(a, b map[string]bool) (float64, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	common := 0
+	for f := range a {
+		if b[f] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common), true
+}
+
+// file will identify a file by its lowercased base name and its size
+func file(f strikeapi.FileInfo) string {
+	name := path.Base(strings.Replace(f.FileName, "\\", "/", -1))
+	return strings.ToLower(name) + "|" + strconv.FormatFloat(f.FileSize, 'f', -1, 64)
+}
+
+func itoa(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// normalize will lower the words of a title and drop the punctuation
+func normalize(title string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// Sink sends the torrents to another Sink unless a duplicate was already
+// sent, it returns strikeapi.ErrDuplicate then
+type Sink struct {
+	Sink    strikeapi.Sink
+	Deduper *Deduper
+
+	mu   sync.Mutex
+	sent []info
+}
+
+// NewSink will create a Sink with the default settings
+func NewSink(sink strikeapi.Sink) *Sink {
+	return &Sink{Sink: sink, Deduper: New()}
+}
+
+// Send implements strikeapi.Sink
+func (s *Sink) Send(t *strikeapi.Torrent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := newInfo(t)
+	for j := range s.sent {
+		if s.Deduper.duplicates(&i, &s.sent[j]) {
+			return strikeapi.ErrDuplicate
+		}
+	}
+	err := s.Sink.Send(t)
+	if err != nil && err != strikeapi.ErrDuplicate {
+		return err
+	}
+	// The info keeps a copy, the caller may reuse the torrent
+	copied := *t
+	i.torrent = &copied
+	s.sent = append(s.sent, i)
+	return err
+}
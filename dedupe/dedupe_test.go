@@ -0,0 +1,152 @@
+package dedupe
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+const gib = 1 << 30
+
+func files(names ...string) *strikeapi.FilesInfo {
+	info := &strikeapi.FilesInfo{}
+	for _, name := range names {
+		info.FileInfo = append(info.FileInfo, strikeapi.FileInfo{FileName: name, FileSize: 1000})
+	}
+	return info
+}
+
+var testTorrents = []strikeapi.Torrent{
+	// The same episode with slightly different titles
+	{Title: "Show.Name.S01E02.720p.HDTV.x264-GRP", Hash: "A1", Size: 1.00 * gib, Seeds: 10},
+	{Title: "Show Name S01E02 720p HDTV x264-GRP [eztv]", Hash: "A2", Size: 1.01 * gib, Seeds: 50},
+	{Title: "show.name.s01e02.hdtv.x264-grp", Hash: "A3", Size: 1.00 * gib, Seeds: 20},
+	// Another copy of the episode
+	{Title: "Show.Name.S01E02.1080p.WEB-DL.x264-GRP", Hash: "B1", Size: 1.00 * gib, Seeds: 30},
+	// Too big to be the same
+	{Title: "Show.Name.S01E02.720p.HDTV.x264-GRP", Hash: "C1", Size: 1.50 * gib, Seeds: 5},
+	// The same hash
+	{Title: "Show.Name.S01E02.720p.HDTV.x264-GRP", Hash: "a1", Size: 2 * gib, Seeds: 1},
+	// Renamed uploads with the same files
+	{Title: "Slackware 14.1 x86_64 DVD ISO", Hash: "D1", Size: 2 * gib, Seeds: 192, FilesInfo: files("slackware64-14.1-iso/slackware64-14.1-install-dvd.iso", "README.TXT")},
+	{Title: "Linux ISO collection", Hash: "D2", Size: 2 * gib, Seeds: 300, FilesInfo: files("slackware64-14.1-install-dvd.iso", "readme.txt")},
+	// The same title with other files
+	{Title: "Slackware 14.1 x86_64 DVD ISO", Hash: "E1", Size: 2 * gib, Seeds: 12, FilesInfo: files("slackware64-14.1-install-dvd.iso", "other.txt")},
+	// The same title without files is compared on the release, it joins the
+	// clusters of D1 and E1
+	{Title: "Slackware.14.1.x86_64.DVD", Hash: "D3", Size: 2 * gib, Seeds: 3},
+}
+
+func TestClusters(t *testing.T) {
+	clusters := Clusters(testTorrents)
+
+	result := []string{}
+	for _, c := range clusters {
+		hashes := []string{}
+		for _, m := range c.Members {
+			hashes = append(hashes, m.Hash)
+		}
+		result = append(result, c.Canonical.Hash+":"+strings.Join(hashes, ","))
+	}
+	expected := "A2:A1,A2,A3,a1 B1:B1 C1:C1 D2:D1,D2,E1,D3"
+	if strings.Join(result, " ") != expected {
+		t.Errorf("Bad clusters %v", result)
+	}
+}
+
+func TestZeroDeduper(t *testing.T) {
+	// The zero Deduper has the default settings
+	d := &Deduper{}
+	hashes := []string{}
+	for _, torrent := range d.Unique(testTorrents[:3]) {
+		hashes = append(hashes, torrent.Hash)
+	}
+	if strings.Join(hashes, ",") != "A2" {
+		t.Errorf("Bad unique torrents %v", hashes)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	d := New()
+	d.Better = func(a, b *strikeapi.Torrent) bool {
+		return a.Size < b.Size
+	}
+	d.SizeTolerance = 0.001
+
+	hashes := []string{}
+	for _, torrent := range d.Unique(testTorrents[:3]) {
+		hashes = append(hashes, torrent.Hash)
+	}
+	if strings.Join(hashes, ",") != "A1,A2" {
+		t.Errorf("Bad unique torrents %v", hashes)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	d := New()
+	tests := []struct {
+		a, b     strikeapi.Torrent
+		expected bool
+	}{
+		{testTorrents[0], testTorrents[1], true},
+		{testTorrents[0], testTorrents[3], false},
+		{testTorrents[0], testTorrents[4], false},
+		{testTorrents[6], testTorrents[7], true},
+		{testTorrents[6], testTorrents[8], false},
+		{strikeapi.Torrent{Title: "Movie.2015.1080p.BluRay"}, strikeapi.Torrent{Title: "Movie (2015) 1080p"}, true},
+		{strikeapi.Torrent{Title: "Movie.2015.1080p"}, strikeapi.Torrent{Title: "Movie.2015.PROPER.1080p"}, false},
+		{strikeapi.Torrent{Title: "Movie.2015.1080p"}, strikeapi.Torrent{Title: "Movie.2016.1080p"}, false},
+	}
+	for _, test := range tests {
+		if d.Duplicates(&test.a, &test.b) != test.expected {
+			t.Errorf("%q and %q should be duplicates : %v", test.a.Title, test.b.Title, test.expected)
+		}
+	}
+}
+
+// sink records the torrents sent
+type sink struct {
+	sent []string
+	err  error
+}
+
+func (s *sink) Send(t *strikeapi.Torrent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, t.Hash)
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	inner := &sink{}
+	s := NewSink(inner)
+
+	results := []string{}
+	for i := range testTorrents {
+		err := s.Send(&testTorrents[i])
+		switch err {
+		case nil:
+			results = append(results, testTorrents[i].Hash)
+		case strikeapi.ErrDuplicate:
+		default:
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(inner.sent, ",") != "A1,B1,C1,D1,E1" || strings.Join(results, ",") != "A1,B1,C1,D1,E1" {
+		t.Errorf("Should send one torrent of each release, got %v", inner.sent)
+	}
+
+	// The errors aren't recorded
+	inner.err = errors.New("down")
+	torrent := strikeapi.Torrent{Title: "Movie.2015.1080p", Hash: "F1"}
+	if err := s.Send(&torrent); err != inner.err {
+		t.Errorf("Should fail, got %v", err)
+	}
+	inner.err = nil
+	if err := s.Send(&torrent); err != nil {
+		t.Errorf("Should send after the error, got %v", err)
+	}
+}
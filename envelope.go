@@ -0,0 +1,154 @@
+package strikeapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// MaxBodySize is the size of the largest answer decoded, in bytes
+var MaxBodySize int64 = 10 << 20
+
+// ErrBodyTooLarge is returned for the answers larger than MaxBodySize
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// message is the message of an envelope, the API gives a string, a number or
+// an object depending on the endpoint
+type message struct {
+	raw json.RawMessage
+}
+
+// UnmarshalJSON is a custom unmarshal function keeping the raw message
+func (m *message) UnmarshalJSON(data []byte) error {
+	m.raw = append(m.raw[:0], data...)
+	return nil
+}
+
+// String will return a string message unquoted, the others as JSON
+func (m message) String() string {
+	if len(m.raw) == 0 || string(m.raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(m.raw, &s); err == nil {
+		return s
+	}
+	return string(m.raw)
+}
+
+// Int will return a number message, or a string message holding a number
+func (m message) Int() (int, error) {
+	var n int
+	if err := json.Unmarshal(m.raw, &n); err == nil {
+		return n, nil
+	}
+	n, err := strconv.Atoi(m.String())
+	if err != nil {
+		return 0, fmt.Errorf("message %s isn't a number", m.raw)
+	}
+	return n, nil
+}
+
+// envelope represents every answer of the API
+type envelope struct {
+	Status       int       `json:"statuscode"`
+	Message      message   `json:"message"`
+	ResultSize   int       `json:"results"`
+	ResponseTime float64   `json:"responsetime"`
+	Torrents     []Torrent `json:"torrents"`
+}
+
+// apiError will return the APIError of an envelope whose statuscode isn't
+// 200
+func (e *envelope) apiError() error {
+	if e.Status == 200 {
+		return nil
+	}
+	return &APIError{Status: e.Status, Message: e.Message.String()}
+}
+
+// fromEnvelope is implemented by the responses built from an envelope
+type fromEnvelope[T any] interface {
+	*T
+	fill(e *envelope) error
+}
+
+// fill will set a Response, a 404 means no torrent was found
+func (r *Response) fill(e *envelope) error {
+	if e.Status != 404 {
+		if err := e.apiError(); err != nil {
+			return err
+		}
+	}
+	*r = Response{ResultSize: e.ResultSize, Status: e.Status, ResponseTime: e.ResponseTime, Torrents: e.Torrents}
+	return nil
+}
+
+// fill will set a ResponseStatus
+func (r *ResponseStatus) fill(e *envelope) error {
+	if err := e.apiError(); err != nil {
+		return err
+	}
+	*r = ResponseStatus{Status: e.Status, Message: e.Message.String()}
+	return nil
+}
+
+// fill will set a ResponseStatusInt
+func (r *ResponseStatusInt) fill(e *envelope) error {
+	if err := e.apiError(); err != nil {
+		return err
+	}
+	n, err := e.Message.Int()
+	if err != nil {
+		return err
+	}
+	*r = ResponseStatusInt{Status: e.Status, Message: n}
+	return nil
+}
+
+// decodeEnvelope will stream the answer of the API into a response, the
+// error envelopes of every endpoint give an APIError. The body is only kept
+// to check it against the schema when SchemaValidation is set.
+func decodeEnvelope[T any, P fromEnvelope[T]](resp *http.Response, schema string) (*T, error) {
+	limited := &io.LimitedReader{R: resp.Body, N: MaxBodySize + 1}
+	var body io.Reader = limited
+	kept := &bytes.Buffer{}
+	if SchemaValidation != ValidateOff {
+		body = io.TeeReader(limited, kept)
+	}
+
+	e := &envelope{}
+	err := json.NewDecoder(body).Decode(e)
+	if limited.N <= 0 {
+		log.Println("Couldn't read response body", ErrBodyTooLarge)
+		return nil, ErrBodyTooLarge
+	}
+	if err != nil {
+		log.Println("Couln't unmarshall result : ", err)
+		return nil, err
+	}
+	// Reading the end of the body lets the connection be reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 512))
+
+	response := new(T)
+	err = P(response).fill(e)
+	// The error envelopes are reported before a schema drift
+	if _, ok := err.(*APIError); ok {
+		return nil, err
+	}
+	if SchemaValidation != ValidateOff {
+		if err := checkSchema(schema, kept.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
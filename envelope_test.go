@@ -0,0 +1,165 @@
+package strikeapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// fakeResponse will build the response of the API with a body
+func fakeResponse(body string) *http.Response {
+	return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(body))}
+}
+
+func TestDecodeEnvelopeMessage(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{`{"statuscode":200,"message":"link"}`, "link"},
+		{`{"statuscode":200,"message":12}`, "12"},
+		{`{"statuscode":200,"message":{"url":"link"}}`, `{"url":"link"}`},
+		{`{"statuscode":200}`, ""},
+	}
+	for _, test := range tests {
+		response, err := decodeEnvelope[ResponseStatus](fakeResponse(test.body), SchemaStatus)
+		if err != nil || response.Message != test.expected {
+			t.Errorf("%s : expected %q, got %+v %v", test.body, test.expected, response, err)
+		}
+	}
+
+	for body, expected := range map[string]int{`{"statuscode":200,"message":12}`: 12, `{"statuscode":200,"message":"12"}`: 12} {
+		response, err := decodeEnvelope[ResponseStatusInt](fakeResponse(body), SchemaCount)
+		if err != nil || response.Message != expected {
+			t.Errorf("%s : expected %d, got %+v %v", body, expected, response, err)
+		}
+	}
+	if _, err := decodeEnvelope[ResponseStatusInt](fakeResponse(`{"statuscode":200,"message":"many"}`), SchemaCount); err == nil {
+		t.Error("Should fail with a count which isn't a number")
+	}
+}
+
+func TestDecodeEnvelopeError(t *testing.T) {
+	body := `{"statuscode":503,"message":"Service unavailable"}`
+	expected := APIError{Status: 503, Message: "Service unavailable"}
+
+	// The error envelopes are detected on every endpoint
+	_, err := decodeEnvelope[Response](fakeResponse(body), SchemaTorrents)
+	if e, ok := err.(*APIError); !ok || *e != expected {
+		t.Errorf("Should get an APIError from the torrents, got %v", err)
+	}
+	_, err = decodeEnvelope[ResponseStatus](fakeResponse(body), SchemaStatus)
+	if e, ok := err.(*APIError); !ok || *e != expected {
+		t.Errorf("Should get an APIError from a status, got %v", err)
+	}
+	_, err = decodeEnvelope[ResponseStatusInt](fakeResponse(body), SchemaCount)
+	if e, ok := err.(*APIError); !ok || *e != expected {
+		t.Errorf("Should get an APIError from the count, got %v", err)
+	}
+
+	// A 404 means no torrent was found
+	response, err := decodeEnvelope[Response](fakeResponse(`{"statuscode":404,"message":"No torrents found"}`), SchemaTorrents)
+	if err != nil || response.Status != 404 || len(response.Torrents) != 0 {
+		t.Errorf("Should get no torrent, got %+v %v", response, err)
+	}
+	if _, err := decodeEnvelope[ResponseStatus](fakeResponse(`{"statuscode":404}`), SchemaStatus); err == nil {
+		t.Error("Should get an APIError from a 404 status")
+	}
+
+	// The error envelopes aren't hidden by a schema drift
+	SchemaValidation = ValidateStrict
+	defer func() { SchemaValidation = ValidateOff }()
+	_, err = decodeEnvelope[ResponseStatusInt](fakeResponse(`{"statuscode":503,"message":"Service unavailable","retry":true}`), SchemaCount)
+	if e, ok := err.(*APIError); !ok || *e != expected {
+		t.Errorf("Should get an APIError in strict mode, got %v", err)
+	}
+	if _, err := decodeEnvelope[Response](fakeResponse(`{"statuscode":404,"torrents":null}`), SchemaTorrents); err != nil {
+		t.Errorf("Should get no torrent in strict mode, got %v", err)
+	}
+
+	if _, err := decodeEnvelope[Response](fakeResponse(`{"statuscode":200,"torrents":[`), SchemaTorrents); err == nil {
+		t.Error("Should fail with malformed JSON")
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	defer func(size int64) { MaxBodySize = size }(MaxBodySize)
+	body := `{"statuscode":200,"message":"` + strings.Repeat("a", 100) + `"}`
+
+	MaxBodySize = int64(len(body))
+	if _, err := decodeEnvelope[ResponseStatus](fakeResponse(body), SchemaStatus); err != nil {
+		t.Errorf("Should decode a body of the max size, got %v", err)
+	}
+	MaxBodySize = 50
+	if _, err := decodeEnvelope[ResponseStatus](fakeResponse(body), SchemaStatus); err != ErrBodyTooLarge {
+		t.Errorf("Should get an ErrBodyTooLarge, got %v", err)
+	}
+}
+
+// legacyResponse is the parsing of the answers before decodeEnvelope, to
+// compare them
+func legacyResponse(resp *http.Response) (*Response, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchema(SchemaTorrents, body); err != nil {
+		return nil, err
+	}
+	response := &Response{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Status != 200 && response.Status != 404 {
+		return nil, &APIError{Status: response.Status}
+	}
+	return response, nil
+}
+
+// benchmarkBody will return the answer of a search with 100 torrents
+func benchmarkBody(b *testing.B) []byte {
+	response := Response{Status: 200, ResultSize: 100}
+	for i := 0; i < 100; i++ {
+		response.Torrents = append(response.Torrents, Torrent{
+			Title:     fmt.Sprintf("Arch Linux 2015.%02d.01 (x86/x64)", i),
+			Hash:      fmt.Sprintf("B425907E5755031BDA4A8D1B6DCCACA97DA14C%02d", i),
+			Category:  Applications,
+			Seeds:     645,
+			Size:      615514112,
+			FilesInfo: &FilesInfo{FileInfo: []FileInfo{{FileName: "archlinux-dual.iso", FileSize: 615514112}}},
+		})
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func BenchmarkDecodeEnvelope(b *testing.B) {
+	data := benchmarkBody(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resp := &http.Response{Body: ioutil.NopCloser(bytes.NewReader(data))}
+		if _, err := decodeEnvelope[Response](resp, SchemaTorrents); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLegacyResponse(b *testing.B) {
+	data := benchmarkBody(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resp := &http.Response{Body: ioutil.NopCloser(bytes.NewReader(data))}
+		if _, err := legacyResponse(resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Package feed generates RSS 2.0 and Atom feeds from Strike API searches
+// and top torrents.
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Formats of the generated feeds
+const (
+	FormatRSS  = "rss"
+	FormatAtom = "atom"
+)
+
+// TorrentNamespace is the namespace of the torrent elements in RSS items
+const TorrentNamespace = "http://xmlns.ezrss.it/0.1/"
+
+// Feed represents a feed of torrents
+type Feed struct {
+	Title       string
+	Link        string
+	Description string
+	// Updated is the most recent upload date of the torrents
+	Updated  time.Time
+	Torrents []strikeapi.Torrent
+	// DownloadLinks are the .torrent links by hash, used for the enclosures,
+	// see ResolveDownloadLinks
+	DownloadLinks map[string]string
+}
+
+// New will create a Feed of torrents
+func New(title string, torrents []strikeapi.Torrent) *Feed {
+	f := &Feed{
+		Title:         title,
+		Link:          "https://getstrike.net/",
+		Description:   title,
+		Torrents:      torrents,
+		DownloadLinks: map[string]string{},
+	}
+	for _, t := range torrents {
+		if uploadTime, err := t.UploadTime(); err == nil && uploadTime.After(f.Updated) {
+			f.Updated = uploadTime
+		}
+	}
+	return f
+}
+
+// FromSearch will create a Feed from the results of a SearchQuery
+func FromSearch(q strikeapi.SearchQuery) (*Feed, error) {
+	torrents, err := q.Search()
+	if err != nil {
+		return nil, err
+	}
+
+	f := New(fmt.Sprintf("Strike search : %s", q.Phrase), torrents)
+	values := url.Values{}
+	values.Add("q", q.Phrase)
+	f.Link = "https://getstrike.net/torrents/search/?" + values.Encode()
+	return f, nil
+}
+
+// FromTop will create a Feed from the top torrents of a category
+func FromTop(category string) (*Feed, error) {
+	torrents, err := strikeapi.GetTopTorrents(category)
+	if err != nil {
+		return nil, err
+	}
+
+	if category == "" {
+		category = "all"
+	}
+	return New(fmt.Sprintf("Strike top torrents : %s", category), torrents), nil
+}
+
+// ResolveDownloadLinks will get the download link of every torrent of the
+// Feed for the .torrent enclosures, torrents without link only get their
+// magnet. The API has no batch request, it costs one request per torrent.
+func (f *Feed) ResolveDownloadLinks() {
+	for _, t := range f.Torrents {
+		if _, ok := f.DownloadLinks[t.Hash]; ok {
+			continue
+		}
+		link, err := t.GetDownloadLink()
+		if err != nil {
+			log.Println("Couldn't get the download link : ", err)
+			continue
+		}
+		f.DownloadLinks[t.Hash] = link
+	}
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Torrent string     `xml:"xmlns:torrent,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title     string        `xml:"title"`
+	Link      string        `xml:"link"`
+	GUID      rssGUID       `xml:"guid"`
+	PubDate   string        `xml:"pubDate,omitempty"`
+	Category  []string      `xml:"category"`
+	Enclosure *rssEnclosure `xml:"enclosure"`
+	Torrent   rssTorrent    `xml:"torrent:torrent"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssTorrent struct {
+	ContentLength int64  `xml:"torrent:contentLength"`
+	InfoHash      string `xml:"torrent:infoHash"`
+	MagnetURI     string `xml:"torrent:magnetURI"`
+	Seeds         int    `xml:"torrent:seeds"`
+	Peers         int    `xml:"torrent:peers"`
+}
+
+// RSS will render the Feed as an RSS 2.0 document
+func (f *Feed) RSS() ([]byte, error) {
+	doc := &rss{
+		Version: "2.0",
+		Torrent: TorrentNamespace,
+		Channel: rssChannel{Title: f.Title, Link: f.Link, Description: f.Description},
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.Format(time.RFC1123Z)
+	}
+
+	for _, t := range f.Torrents {
+		size := int64(t.Size)
+		item := rssItem{
+			Title:    t.Title,
+			Link:     t.Page,
+			GUID:     rssGUID{Value: t.Hash},
+			Category: categories(&t),
+			Torrent: rssTorrent{
+				ContentLength: size,
+				InfoHash:      t.Hash,
+				MagnetURI:     t.MagnetURI,
+				Seeds:         t.Seeds,
+				Peers:         t.Seeds + t.Leeches,
+			},
+		}
+		if item.Link == "" {
+			item.Link = t.MagnetURI
+		}
+		if uploadTime, err := t.UploadTime(); err == nil {
+			item.PubDate = uploadTime.Format(time.RFC1123Z)
+		}
+		// RSS has one enclosure, the magnet without .torrent link
+		if link, ok := f.DownloadLinks[t.Hash]; ok {
+			item.Enclosure = &rssEnclosure{URL: link, Length: size, Type: "application/x-bittorrent"}
+		} else if t.MagnetURI != "" {
+			item.Enclosure = &rssEnclosure{URL: t.MagnetURI, Length: size, Type: "x-scheme-handler/magnet"}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	return render(doc)
+}
+
+type atom struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID       string         `xml:"id"`
+	Title    string         `xml:"title"`
+	Updated  string         `xml:"updated"`
+	Author   *atomAuthor    `xml:"author"`
+	Category []atomCategory `xml:"category"`
+	Links    []atomLink     `xml:"link"`
+	Summary  string         `xml:"summary"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// Atom will render the Feed as an Atom document
+func (f *Feed) Atom() ([]byte, error) {
+	doc := &atom{
+		ID:      f.Link,
+		Title:   f.Title,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: f.Link},
+	}
+
+	for _, t := range f.Torrents {
+		size := int64(t.Size)
+		entry := atomEntry{
+			ID:      "urn:btih:" + t.Hash,
+			Title:   t.Title,
+			Updated: f.Updated.UTC().Format(time.RFC3339),
+			Summary: fmt.Sprintf("%d seeds, %d leeches, %d bytes", t.Seeds, t.Leeches, size),
+		}
+		if uploadTime, err := t.UploadTime(); err == nil {
+			entry.Updated = uploadTime.UTC().Format(time.RFC3339)
+		}
+		if t.UploaderUsername != "" {
+			entry.Author = &atomAuthor{Name: t.UploaderUsername}
+		}
+		for _, c := range categories(&t) {
+			entry.Category = append(entry.Category, atomCategory{Term: c})
+		}
+		if t.Page != "" {
+			entry.Links = append(entry.Links, atomLink{Rel: "alternate", Href: t.Page})
+		}
+		if link, ok := f.DownloadLinks[t.Hash]; ok {
+			entry.Links = append(entry.Links, atomLink{Rel: "enclosure", Href: link, Type: "application/x-bittorrent", Length: size})
+		}
+		if t.MagnetURI != "" {
+			entry.Links = append(entry.Links, atomLink{Rel: "enclosure", Href: t.MagnetURI, Type: "x-scheme-handler/magnet", Length: size})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return render(doc)
+}
+
+func categories(t *strikeapi.Torrent) []string {
+	c := []string{}
+	if t.Category != "" {
+		c = append(c, t.Category)
+	}
+	if t.SubCategory != "" {
+		c = append(c, t.SubCategory)
+	}
+	return c
+}
+
+func render(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
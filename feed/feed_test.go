@@ -0,0 +1,135 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+const searchResponse = `{"results":2,"statuscode":200,"responsetime":0.4725,"torrents":[{"torrent_hash":"156B69B8643BD11849A5D8F2122E13FBB61BD041","torrent_title":"Slackware 14.1 x86_64 DVD ISO","torrent_category":"Applications","sub_category":"","seeds":192,"leeches":9,"file_count":4,"size":2437393940.48,"download_count":40,"upload_date":"Feb 24, 2014","uploader_username":"Nusantara","page":"https://getstrike.net/torrents/156B69B8643BD11849A5D8F2122E13FBB61BD041","magnet_uri":"magnet:?xt=urn:btih:156B69B8643BD11849A5D8F2122E13FBB61BD041"},{"torrent_hash":"6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088","torrent_title":"Marvel NOW","torrent_category":"Books","sub_category":"Comics","seeds":790,"leeches":458,"file_count":22,"size":905141288.96,"download_count":5,"upload_date":"Mar 25, 2015","uploader_username":"Nemesis44","magnet_uri":"magnet:?xt=urn:btih:6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088"}]}`
+
+// fakeStrike starts a fake Strike API answering searches, top torrents and
+// download links, the number of calls is recorded
+func fakeStrike(calls *int) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		switch r.URL.Path {
+		case "/torrents/download/":
+			fmt.Fprintf(w, `{"statuscode":200,"message":"https://getstrike.net/torrents/api/download/%s.torrent"}`, r.URL.Query().Get("hash"))
+		default:
+			fmt.Fprintln(w, searchResponse)
+		}
+	}))
+	strikeapi.APIEndpoint = ts.URL
+	return ts
+}
+
+func TestRSS(t *testing.T) {
+	var calls int
+	ts := fakeStrike(&calls)
+	defer ts.Close()
+
+	f, err := FromSearch(strikeapi.SearchQuery{Phrase: "slackware"})
+	if err != nil {
+		t.Fatalf("Error generating the feed : %s", err)
+	}
+	// The download links are only resolved on demand, one request each
+	if calls != 1 {
+		t.Errorf("Should only search, got %d calls", calls)
+	}
+	f.ResolveDownloadLinks()
+	if calls != 3 {
+		t.Errorf("Should resolve each download link, got %d calls", calls)
+	}
+	body, err := f.RSS()
+	if err != nil {
+		t.Fatalf("Error rendering the feed : %s", err)
+	}
+
+	var doc struct {
+		LastBuildDate string `xml:"channel>lastBuildDate"`
+		Items         []struct {
+			Title     string `xml:"title"`
+			GUID      string `xml:"guid"`
+			PubDate   string `xml:"pubDate"`
+			Enclosure struct {
+				URL    string `xml:"url,attr"`
+				Length int64  `xml:"length,attr"`
+			} `xml:"enclosure"`
+			Magnet string `xml:"http://xmlns.ezrss.it/0.1/ torrent>magnetURI"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("Couldn't parse the RSS : %s", err)
+	}
+
+	if doc.LastBuildDate != "Wed, 25 Mar 2015 00:00:00 +0000" {
+		t.Errorf("Bad last build date %q", doc.LastBuildDate)
+	}
+	if len(doc.Items) != 2 {
+		t.Fatalf("Should get 2 items, got %d", len(doc.Items))
+	}
+	item := doc.Items[0]
+	if item.GUID != "156B69B8643BD11849A5D8F2122E13FBB61BD041" || item.PubDate != "Mon, 24 Feb 2014 00:00:00 +0000" {
+		t.Errorf("Bad item : %+v", item)
+	}
+	if item.Enclosure.URL != "https://getstrike.net/torrents/api/download/156B69B8643BD11849A5D8F2122E13FBB61BD041.torrent" || item.Enclosure.Length != 2437393940 {
+		t.Errorf("Bad enclosure : %+v", item.Enclosure)
+	}
+	if item.Magnet != "magnet:?xt=urn:btih:156B69B8643BD11849A5D8F2122E13FBB61BD041" {
+		t.Errorf("Bad magnet %q", item.Magnet)
+	}
+}
+
+func TestAtom(t *testing.T) {
+	var calls int
+	ts := fakeStrike(&calls)
+	defer ts.Close()
+
+	f, err := FromTop(strikeapi.Books)
+	if err != nil {
+		t.Fatalf("Error generating the feed : %s", err)
+	}
+	f.ResolveDownloadLinks()
+	body, err := f.Atom()
+	if err != nil {
+		t.Fatalf("Error rendering the feed : %s", err)
+	}
+
+	var doc struct {
+		Title   string `xml:"title"`
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Updated string `xml:"updated"`
+			Links   []struct {
+				Rel  string `xml:"rel,attr"`
+				Href string `xml:"href,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"link"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("Couldn't parse the Atom : %s", err)
+	}
+
+	if doc.Title != "Strike top torrents : Books" || doc.Updated != "2015-03-25T00:00:00Z" {
+		t.Errorf("Bad feed : %s %s", doc.Title, doc.Updated)
+	}
+	entry := doc.Entries[1]
+	if entry.ID != "urn:btih:6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088" || entry.Updated != "2015-03-25T00:00:00Z" {
+		t.Errorf("Bad entry : %+v", entry)
+	}
+	types := []string{}
+	for _, l := range entry.Links {
+		types = append(types, l.Rel+" "+l.Type)
+	}
+	if !reflect.DeepEqual(types, []string{"enclosure application/x-bittorrent", "enclosure x-scheme-handler/magnet"}) {
+		t.Errorf("Bad links : %+v", entry.Links)
+	}
+}
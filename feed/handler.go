@@ -0,0 +1,149 @@
+package feed
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Handler serves a Feed as RSS, or as Atom with ?format=atom, with ETag and
+// Last-Modified headers so clients can make conditional requests. The
+// Last-Modified is the time the Feed was fetched with a new content, the
+// results may have old upload dates.
+type Handler struct {
+	// Source generates the Feed
+	Source func() (*Feed, error)
+	// Format is the default format, FormatRSS when empty
+	Format string
+	// MaxAge is how long a generated Feed is reused before calling Source
+	// again
+	MaxAge time.Duration
+	// Enclosures resolves the download links of the generated Feeds for the
+	// .torrent enclosures, see Feed.ResolveDownloadLinks
+	Enclosures bool
+
+	mu        sync.Mutex
+	cached    *Feed
+	fetchedAt time.Time
+	// modified is the time the content of the Feed last changed, checksum
+	// is the checksum of this content
+	modified time.Time
+	checksum [sha1.Size]byte
+	// now gives the time, time.Now when nil
+	now func() time.Time
+}
+
+// NewSearchHandler returns a Handler serving the results of a SearchQuery
+func NewSearchHandler(q strikeapi.SearchQuery, maxAge time.Duration) *Handler {
+	return &Handler{
+		Source: func() (*Feed, error) { return FromSearch(q) },
+		MaxAge: maxAge,
+	}
+}
+
+// NewTopHandler returns a Handler serving the top torrents of a category
+func NewTopHandler(category string, maxAge time.Duration) *Handler {
+	return &Handler{
+		Source: func() (*Feed, error) { return FromTop(category) },
+		MaxAge: maxAge,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, modified, err := h.feed()
+	if err != nil {
+		log.Println("Couldn't generate the feed : ", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = h.Format
+	}
+
+	var body []byte
+	var contentType string
+	switch format {
+	case FormatAtom:
+		body, err = f.Atom()
+		contentType = "application/atom+xml; charset=utf-8"
+	case FormatRSS, "":
+		body, err = f.RSS()
+		contentType = "application/rss+xml; charset=utf-8"
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %q", format), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("Couldn't render the feed : ", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(body))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	if h.MaxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(h.MaxAge.Seconds())))
+	}
+
+	if notModified(r, etag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+// feed will return the cached Feed or generate a new one, with the time its
+// content last changed
+func (h *Handler) feed() (*Feed, time.Time, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now
+	if h.now != nil {
+		now = h.now
+	}
+	if h.cached != nil && now().Sub(h.fetchedAt) < h.MaxAge {
+		return h.cached, h.modified, nil
+	}
+
+	f, err := h.Source()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if h.Enclosures {
+		f.ResolveDownloadLinks()
+	}
+	body, err := f.RSS()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	h.cached, h.fetchedAt = f, now()
+	if checksum := sha1.Sum(body); h.modified.IsZero() || checksum != h.checksum {
+		h.modified, h.checksum = h.fetchedAt, checksum
+	}
+	return f, h.modified, nil
+}
+
+// notModified will check the conditional headers of a request, If-None-Match
+// takes precedence over If-Modified-Since
+func notModified(r *http.Request, etag string, updated time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return match == etag || match == "*"
+	}
+	if since := r.Header.Get("If-Modified-Since"); since != "" && !updated.IsZero() {
+		t, err := http.ParseTime(since)
+		return err == nil && !updated.Truncate(time.Second).After(t)
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package feed
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+func serve(h http.Handler, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandlerCaching(t *testing.T) {
+	var calls int
+	ts := fakeStrike(&calls)
+	defer ts.Close()
+
+	fetched := time.Date(2015, time.April, 1, 12, 0, 0, 0, time.UTC)
+	h := NewSearchHandler(strikeapi.SearchQuery{Phrase: "slackware"}, time.Minute)
+	h.now = func() time.Time { return fetched }
+
+	w := serve(h, "/feed", nil)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/rss+xml") {
+		t.Fatalf("Bad response : %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Errorf("ETag missing")
+	}
+	// The time of the fetch, not of the latest upload
+	if w.Header().Get("Last-Modified") != "Wed, 01 Apr 2015 12:00:00 GMT" {
+		t.Errorf("Bad Last-Modified %q", w.Header().Get("Last-Modified"))
+	}
+
+	// The feed is reused during MaxAge
+	apiCalls := calls
+	w = serve(h, "/feed", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Should get a 304, got %d", w.Code)
+	}
+	if calls != apiCalls {
+		t.Errorf("Shouldn't call the API again")
+	}
+
+	w = serve(h, "/feed", map[string]string{"If-Modified-Since": "Wed, 01 Apr 2015 12:00:00 GMT"})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Should get a 304, got %d", w.Code)
+	}
+
+	w = serve(h, "/feed", map[string]string{"If-Modified-Since": "Tue, 24 Mar 2015 00:00:00 GMT"})
+	if w.Code != http.StatusOK {
+		t.Errorf("Should get a 200, got %d", w.Code)
+	}
+
+	// The same results fetched again keep their Last-Modified
+	fetched = fetched.Add(time.Hour)
+	w = serve(h, "/feed", map[string]string{"If-Modified-Since": "Wed, 01 Apr 2015 12:00:00 GMT"})
+	if w.Code != http.StatusNotModified || calls == apiCalls {
+		t.Errorf("Should fetch the same results, got %d", w.Code)
+	}
+}
+
+func TestHandlerNewResults(t *testing.T) {
+	results := []string{
+		`{"results":1,"statuscode":200,"torrents":[{"torrent_hash":"A1","torrent_title":"Old upload","seeds":1,"leeches":1,"size":1,"upload_date":"Mar 25, 2015"}]}`,
+		`{"results":2,"statuscode":200,"torrents":[{"torrent_hash":"A1","torrent_title":"Old upload","seeds":1,"leeches":1,"size":1,"upload_date":"Mar 25, 2015"},` +
+			`{"torrent_hash":"A2","torrent_title":"Older upload","seeds":1,"leeches":1,"size":1,"upload_date":"Jan 1, 2014"}]}`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, results[0])
+		if len(results) > 1 {
+			results = results[1:]
+		}
+	}))
+	defer ts.Close()
+	strikeapi.APIEndpoint = ts.URL
+
+	fetched := time.Date(2015, time.April, 1, 12, 0, 0, 0, time.UTC)
+	h := NewSearchHandler(strikeapi.SearchQuery{Phrase: "upload"}, time.Minute)
+	h.now = func() time.Time { return fetched }
+	since := serve(h, "/feed", nil).Header().Get("Last-Modified")
+
+	// A new result with an old upload date still modifies the feed
+	fetched = fetched.Add(time.Hour)
+	w := serve(h, "/feed", map[string]string{"If-Modified-Since": since})
+	if w.Code != http.StatusOK || w.Header().Get("Last-Modified") != "Wed, 01 Apr 2015 13:00:00 GMT" {
+		t.Errorf("Should get the new results, got %d %s", w.Code, w.Header().Get("Last-Modified"))
+	}
+}
+
+func TestHandlerEnclosures(t *testing.T) {
+	var calls int
+	ts := fakeStrike(&calls)
+	defer ts.Close()
+
+	h := NewTopHandler("", time.Minute)
+	// The magnets are the enclosures without download link
+	w := serve(h, "/feed", nil)
+	if strings.Contains(w.Body.String(), "application/x-bittorrent") || !strings.Contains(w.Body.String(), `type="x-scheme-handler/magnet"`) || calls != 1 {
+		t.Errorf("Shouldn't resolve the download links, got %d calls\n%s", calls, w.Body.String())
+	}
+
+	h = NewTopHandler("", time.Minute)
+	h.Enclosures = true
+	if w := serve(h, "/feed", nil); !strings.Contains(w.Body.String(), "application/x-bittorrent") || calls != 4 {
+		t.Errorf("Should resolve the download links, got %d calls", calls)
+	}
+}
+
+func TestHandlerAtom(t *testing.T) {
+	var calls int
+	ts := fakeStrike(&calls)
+	defer ts.Close()
+
+	h := NewTopHandler("", time.Minute)
+
+	rssETag := serve(h, "/feed", nil).Header().Get("ETag")
+	w := serve(h, "/feed?format=atom", map[string]string{"If-None-Match": rssETag})
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/atom+xml") {
+		t.Errorf("Bad response : %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	if w = serve(h, "/feed?format=json", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Should get a 400, got %d", w.Code)
+	}
+}
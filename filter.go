@@ -0,0 +1,19 @@
+package strikeapi
+
+// Filter represents a policy hiding or reordering the results of the
+// searches, see the reputation package
+type Filter interface {
+	Filter(torrents []Torrent) []Torrent
+}
+
+// SearchFilter filters the results of the searches and of the top torrents
+// when set, the LocalStore still records every torrent fetched
+var SearchFilter Filter
+
+// filterTorrents will apply the SearchFilter
+func filterTorrents(torrents []Torrent) []Torrent {
+	if SearchFilter == nil {
+		return torrents
+	}
+	return SearchFilter.Filter(torrents)
+}
@@ -0,0 +1,53 @@
+package strikeapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// seedsFilter keeps the torrents with enough seeds
+type seedsFilter int
+
+func (f seedsFilter) Filter(torrents []Torrent) []Torrent {
+	filtered := []Torrent{}
+	for _, t := range torrents {
+		if t.Seeds >= int(f) {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+func TestSearchFilter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"results":2,"statuscode":200,"torrents":[{"torrent_hash":"A1","torrent_title":"Arch Linux","seeds":645},{"torrent_hash":"A2","torrent_title":"Arch Linux fake","seeds":2}]}`)
+	}))
+	defer ts.Close()
+	APIEndpoint = ts.URL
+
+	s := &memoryStore{descriptions: map[string]string{}}
+	LocalStore = s
+	SearchFilter = seedsFilter(10)
+	defer func() { LocalStore, SearchFilter = nil, nil }()
+
+	torrents, err := Search("Arch")
+	if err != nil || len(torrents) != 1 || torrents[0].Hash != "A1" {
+		t.Errorf("Bad filtered search : %v %v", torrents, err)
+	}
+	if torrents, err = GetTopTorrents(""); err != nil || len(torrents) != 1 {
+		t.Errorf("Bad filtered top torrents : %v %v", torrents, err)
+	}
+	if torrents, err = SearchLocal("Arch"); err != nil || len(torrents) != 2 {
+		t.Errorf("Bad filtered local search : %v %v", torrents, err)
+	}
+	// The store still gets everything
+	if len(s.torrents) != 4 {
+		t.Errorf("Should have stored 4 torrents, got %d", len(s.torrents))
+	}
+	// The explicit hashes aren't filtered
+	if torrents, err = GetTorrentsInfos([]string{"A1", "A2"}); err != nil || len(torrents) != 2 {
+		t.Errorf("Bad torrent infos : %v %v", torrents, err)
+	}
+}
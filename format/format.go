@@ -0,0 +1,287 @@
+// Package format renders torrents as an aligned table, JSON, NDJSON, CSV or
+// through a user-supplied text/template.
+package format
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Output formats, a template is given as "template=<text>"
+const (
+	Table          = "table"
+	JSON           = "json"
+	NDJSON         = "ndjson"
+	CSV            = "csv"
+	TemplatePrefix = "template="
+)
+
+// Formatter writes torrents in a given format
+type Formatter interface {
+	// Format will write a list of torrents
+	Format(w io.Writer, torrents []strikeapi.Torrent) error
+	// FormatTorrent will write a single torrent
+	FormatTorrent(w io.Writer, t *strikeapi.Torrent) error
+}
+
+// New will return the Formatter of an output format, columns are used by
+// the table and CSV formats, DefaultColumns when empty
+func New(output string, columns []string) (Formatter, error) {
+	if strings.HasPrefix(output, TemplatePrefix) {
+		return NewTemplate(strings.TrimPrefix(output, TemplatePrefix))
+	}
+
+	switch output {
+	case JSON:
+		return &JSONFormatter{Indent: "  "}, nil
+	case NDJSON:
+		return &NDJSONFormatter{}, nil
+	case Table, CSV:
+	default:
+		return nil, fmt.Errorf("unknown output format %q", output)
+	}
+
+	// The columns are only checked for the formats using them
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+	cols, err := lookupColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+	if output == CSV {
+		return &CSVFormatter{columns: cols}, nil
+	}
+	return &TableFormatter{columns: cols}, nil
+}
+
+// Column represents a field of a Torrent in the table and CSV formats
+type Column struct {
+	Name   string
+	Header string
+	// Value returns the raw value, Human the one displayed in tables
+	Value func(t *strikeapi.Torrent) string
+	Human func(t *strikeapi.Torrent) string
+}
+
+// Columns are the available columns
+var Columns = []Column{
+	{"hash", "HASH", func(t *strikeapi.Torrent) string { return t.Hash }, nil},
+	{"title", "TITLE", func(t *strikeapi.Torrent) string { return t.Title }, nil},
+	{"category", "CATEGORY", func(t *strikeapi.Torrent) string { return t.Category }, nil},
+	{"subcategory", "SUBCATEGORY", func(t *strikeapi.Torrent) string { return t.SubCategory }, nil},
+	{"seeds", "SEEDS", func(t *strikeapi.Torrent) string { return strconv.Itoa(t.Seeds) }, nil},
+	{"leeches", "LEECHES", func(t *strikeapi.Torrent) string { return strconv.Itoa(t.Leeches) }, nil},
+	{"size", "SIZE", func(t *strikeapi.Torrent) string { return strconv.FormatFloat(t.Size, 'f', 0, 64) },
+		func(t *strikeapi.Torrent) string { return HumanSize(t.Size) }},
+	{"files", "FILES", func(t *strikeapi.Torrent) string { return strconv.Itoa(t.FileCount) }, nil},
+	{"downloads", "DOWNLOADS", func(t *strikeapi.Torrent) string { return strconv.Itoa(t.DownloadCount) }, nil},
+	{"uploaded", "UPLOADED", func(t *strikeapi.Torrent) string { return t.UploadDate }, nil},
+	{"uploader", "UPLOADER", func(t *strikeapi.Torrent) string { return t.UploaderUsername }, nil},
+	{"page", "PAGE", func(t *strikeapi.Torrent) string { return t.Page }, nil},
+	{"magnet", "MAGNET", func(t *strikeapi.Torrent) string { return t.MagnetURI }, nil},
+}
+
+// DefaultColumns are the columns used when none are given
+var DefaultColumns = []string{"hash", "title", "category", "seeds", "leeches", "size", "uploaded"}
+
+// ColumnNames will return the names of the available columns
+func ColumnNames() []string {
+	names := []string{}
+	for _, c := range Columns {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func lookupColumns(names []string) ([]Column, error) {
+	cols := []Column{}
+	for _, name := range names {
+		found := false
+		for _, c := range Columns {
+			if c.Name == strings.ToLower(strings.TrimSpace(name)) {
+				cols = append(cols, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column %q, available columns : %s", name, strings.Join(ColumnNames(), ", "))
+		}
+	}
+	return cols, nil
+}
+
+// HumanSize will format a size in bytes with binary units
+func HumanSize(size float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	i := 0
+	for size >= 1024 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", size, units[i])
+	}
+	return fmt.Sprintf("%.2f %s", size, units[i])
+}
+
+// TableFormatter writes torrents as an aligned text table
+type TableFormatter struct {
+	columns []Column
+}
+
+// Format implements Formatter
+func (f *TableFormatter) Format(w io.Writer, torrents []strikeapi.Torrent) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	headers := []string{}
+	for _, c := range f.columns {
+		headers = append(headers, c.Header)
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+
+	for i := range torrents {
+		values := []string{}
+		for _, c := range f.columns {
+			value := c.Value
+			if c.Human != nil {
+				value = c.Human
+			}
+			// Tabs and newlines would break the alignment
+			values = append(values, strings.Join(strings.Fields(value(&torrents[i])), " "))
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// FormatTorrent implements Formatter
+func (f *TableFormatter) FormatTorrent(w io.Writer, t *strikeapi.Torrent) error {
+	return f.Format(w, []strikeapi.Torrent{*t})
+}
+
+// JSONFormatter writes torrents as a JSON array, or a JSON object for a
+// single torrent
+type JSONFormatter struct {
+	Indent string
+}
+
+// Format implements Formatter
+func (f *JSONFormatter) Format(w io.Writer, torrents []strikeapi.Torrent) error {
+	if torrents == nil {
+		torrents = []strikeapi.Torrent{}
+	}
+	return f.encode(w, torrents)
+}
+
+// FormatTorrent implements Formatter
+func (f *JSONFormatter) FormatTorrent(w io.Writer, t *strikeapi.Torrent) error {
+	return f.encode(w, t)
+}
+
+func (f *JSONFormatter) encode(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", f.Indent)
+	return enc.Encode(v)
+}
+
+// NDJSONFormatter writes one JSON object per line
+type NDJSONFormatter struct{}
+
+// Format implements Formatter
+func (f *NDJSONFormatter) Format(w io.Writer, torrents []strikeapi.Torrent) error {
+	enc := json.NewEncoder(w)
+	for i := range torrents {
+		if err := enc.Encode(&torrents[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatTorrent implements Formatter
+func (f *NDJSONFormatter) FormatTorrent(w io.Writer, t *strikeapi.Torrent) error {
+	return json.NewEncoder(w).Encode(t)
+}
+
+// CSVFormatter writes torrents as CSV with a header line
+type CSVFormatter struct {
+	columns []Column
+}
+
+// Format implements Formatter
+func (f *CSVFormatter) Format(w io.Writer, torrents []strikeapi.Torrent) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{}
+	for _, c := range f.columns {
+		header = append(header, c.Name)
+	}
+	cw.Write(header)
+
+	for i := range torrents {
+		record := []string{}
+		for _, c := range f.columns {
+			record = append(record, c.Value(&torrents[i]))
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// FormatTorrent implements Formatter
+func (f *CSVFormatter) FormatTorrent(w io.Writer, t *strikeapi.Torrent) error {
+	return f.Format(w, []strikeapi.Torrent{*t})
+}
+
+// TemplateFormatter executes a text/template for every torrent, a newline
+// is added after each one
+type TemplateFormatter struct {
+	tmpl *template.Template
+}
+
+// TemplateFuncs are the functions available in the templates
+var TemplateFuncs = template.FuncMap{
+	"humanSize": HumanSize,
+	"join":      strings.Join,
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+}
+
+// NewTemplate will parse a template executed with a *strikeapi.Torrent
+func NewTemplate(text string) (*TemplateFormatter, error) {
+	tmpl, err := template.New("torrent").Funcs(TemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateFormatter{tmpl: tmpl}, nil
+}
+
+// Format implements Formatter
+func (f *TemplateFormatter) Format(w io.Writer, torrents []strikeapi.Torrent) error {
+	for i := range torrents {
+		if err := f.FormatTorrent(w, &torrents[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatTorrent implements Formatter
+func (f *TemplateFormatter) FormatTorrent(w io.Writer, t *strikeapi.Torrent) error {
+	if err := f.tmpl.Execute(w, t); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
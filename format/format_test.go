@@ -0,0 +1,122 @@
+package format
+
+import (
+	"bytes"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+var testTorrents = []strikeapi.Torrent{
+	{
+		Title:      "Slackware 14.1 x86_64 DVD ISO",
+		Hash:       "156B69B8643BD11849A5D8F2122E13FBB61BD041",
+		Category:   "Applications",
+		Seeds:      192,
+		Leeches:    9,
+		Size:       2437393940.48,
+		UploadDate: "Feb 24, 2014",
+	},
+	{
+		Title:       "Marvel NOW, \"complete\"",
+		Hash:        "6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088",
+		Category:    "Books",
+		SubCategory: "Comics",
+		Seeds:       790,
+		Leeches:     458,
+		Size:        905141288.96,
+		UploadDate:  "Mar 25, 2015",
+	},
+}
+
+func format(t *testing.T, output string, columns []string) string {
+	f, err := New(output, columns)
+	if err != nil {
+		t.Fatalf("Error creating the formatter : %s", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := f.Format(buf, testTorrents); err != nil {
+		t.Fatalf("Error formatting : %s", err)
+	}
+	return buf.String()
+}
+
+func TestTable(t *testing.T) {
+	expected := `HASH                                      TITLE                          SEEDS  SIZE
+156B69B8643BD11849A5D8F2122E13FBB61BD041  Slackware 14.1 x86_64 DVD ISO  192    2.27 GiB
+6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088  Marvel NOW, "complete"         790    863.21 MiB
+`
+	if got := format(t, Table, []string{"hash", "title", "seeds", "size"}); got != expected {
+		t.Errorf("Bad table :\n%s", got)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	expected := `title,subcategory,size
+Slackware 14.1 x86_64 DVD ISO,,2437393940
+"Marvel NOW, ""complete""",Comics,905141289
+`
+	if got := format(t, CSV, []string{"title", "subcategory", "size"}); got != expected {
+		t.Errorf("Bad CSV :\n%s", got)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	got := format(t, NDJSON, nil)
+	if bytes.Count([]byte(got), []byte("\n")) != 2 || !bytes.HasPrefix([]byte(got), []byte(`{"torrent_title":"Slackware 14.1 x86_64 DVD ISO"`)) {
+		t.Errorf("Bad NDJSON :\n%s", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	f, _ := New(JSON, nil)
+	buf := &bytes.Buffer{}
+	if err := f.Format(buf, nil); err != nil || buf.String() != "[]\n" {
+		t.Errorf("Bad empty JSON : %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := f.FormatTorrent(buf, &testTorrents[0]); err != nil || !bytes.HasPrefix(buf.Bytes(), []byte("{\n  \"torrent_title\"")) {
+		t.Errorf("Bad JSON :\n%s", buf.String())
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	expected := "Slackware 14.1 x86_64 DVD ISO (2.27 GiB) APPLICATIONS\nMarvel NOW, \"complete\" (863.21 MiB) BOOKS\n"
+	if got := format(t, "template={{.Title}} ({{humanSize .Size}}) {{upper .Category}}", nil); got != expected {
+		t.Errorf("Bad template output :\n%s", got)
+	}
+
+	if _, err := New("template={{.Title", nil); err == nil {
+		t.Errorf("Should get a template error")
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	if _, err := New("xml", nil); err == nil {
+		t.Errorf("Should get an error for an unknown format")
+	}
+	if _, err := New(Table, []string{"nope"}); err == nil {
+		t.Errorf("Should get an error for an unknown column")
+	}
+	// The columns are ignored by the JSON formats
+	for _, output := range []string{JSON, NDJSON} {
+		if _, err := New(output, []string{"nope"}); err != nil {
+			t.Errorf("%s shouldn't check the columns, got %v", output, err)
+		}
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	for size, expected := range map[float64]string{
+		0:          "0 B",
+		1023:       "1023 B",
+		1024:       "1.00 KiB",
+		615514112:  "587.00 MiB",
+		1099511627: "1.02 GiB",
+	} {
+		if got := HumanSize(size); got != expected {
+			t.Errorf("HumanSize(%.0f) = %q, expected %q", size, got, expected)
+		}
+	}
+}
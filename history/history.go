@@ -0,0 +1,184 @@
+// Package history computes the trends of the seeds of torrents from the
+// samples kept in the local store: growth rate, peak and decay half-life.
+//
+// A sample is recorded each time a torrent is fetched with the store set as
+// strikeapi.LocalStore, Poll fetches torrents periodically to record more.
+package history
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/store"
+)
+
+// Statuses of a Trend
+const (
+	StatusUnknown = "unknown"
+	StatusRising  = "rising"
+	StatusStable  = "stable"
+	StatusDying   = "dying"
+	StatusDead    = "dead"
+)
+
+// ErrNotEnoughSamples is returned when a trend needs at least two samples
+var ErrNotEnoughSamples = errors.New("not enough samples")
+
+// Trend represents the evolution of the seeds of a torrent
+type Trend struct {
+	Hash    string    `json:"hash"`
+	Samples int       `json:"samples"`
+	First   time.Time `json:"first"`
+	Last    time.Time `json:"last"`
+	// Seeds and Leeches are the latest values
+	Seeds   int `json:"seeds"`
+	Leeches int `json:"leeches"`
+	// Peak is the highest number of seeds, seen at PeakTime
+	Peak     int       `json:"peak"`
+	PeakTime time.Time `json:"peak_time"`
+	// GrowthRate is the number of seeds won per day, from a linear fit of
+	// all the samples
+	GrowthRate float64 `json:"growth_rate"`
+	// HalfLife is the time the seeds take to halve, from an exponential fit
+	// of the samples since the peak, 0 when they aren't decreasing
+	HalfLife time.Duration `json:"half_life"`
+	Status   string        `json:"status"`
+}
+
+// Analyze will compute the trend of the samples of a torrent, they must be
+// sorted by time
+func Analyze(hash string, samples []store.Sample) *Trend {
+	t := &Trend{Hash: hash, Samples: len(samples), Status: StatusUnknown}
+	if len(samples) == 0 {
+		return t
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+	t.First, t.Last = first.Time, last.Time
+	t.Seeds, t.Leeches = last.Seeds, last.Leeches
+
+	peak := 0
+	for i, s := range samples {
+		if s.Seeds > samples[peak].Seeds {
+			peak = i
+		}
+	}
+	t.Peak, t.PeakTime = samples[peak].Seeds, samples[peak].Time
+
+	if len(samples) < 2 || !last.Time.After(first.Time) {
+		return t
+	}
+
+	// Linear fit of the seeds over the days
+	xs, ys := []float64{}, []float64{}
+	for _, s := range samples {
+		xs = append(xs, days(s.Time.Sub(first.Time)))
+		ys = append(ys, float64(s.Seeds))
+	}
+	t.GrowthRate = slope(xs, ys)
+
+	// Exponential fit of the decay since the peak, log(seeds) is linear
+	xs, ys = []float64{}, []float64{}
+	for _, s := range samples[peak:] {
+		if s.Seeds <= 0 {
+			break
+		}
+		xs = append(xs, days(s.Time.Sub(t.PeakTime)))
+		ys = append(ys, math.Log(float64(s.Seeds)))
+	}
+	if len(xs) >= 2 {
+		if k := slope(xs, ys); k < 0 {
+			t.HalfLife = time.Duration(math.Ln2 / -k * float64(24*time.Hour))
+		}
+	}
+
+	t.Status = status(t)
+	return t
+}
+
+// status will classify a trend, a torrent is dying once it lost half of its
+// peak and keeps decreasing
+func status(t *Trend) string {
+	switch {
+	case t.Seeds == 0:
+		return StatusDead
+	case t.HalfLife > 0 && t.GrowthRate < 0 && t.Seeds*2 <= t.Peak:
+		return StatusDying
+	case t.GrowthRate > 0 && t.Seeds == t.Peak:
+		return StatusRising
+	}
+	return StatusStable
+}
+
+// Healthy will tell whether a torrent is worth downloading : seeded and not
+// dying
+func (t *Trend) Healthy() bool {
+	return t.Seeds > 0 && t.Status != StatusDying && t.Status != StatusDead
+}
+
+// ForHash will compute the trend of a torrent from the samples of the store
+func ForHash(s *store.Store, hash string) (*Trend, error) {
+	samples, err := s.Samples(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) < 2 {
+		return nil, ErrNotEnoughSamples
+	}
+	return Analyze(strings.ToUpper(hash), samples), nil
+}
+
+// Poll will fetch the torrents every interval until the context is done,
+// storing a sample of each of them every time. The errors are logged, the
+// next fetch is tried anyway.
+func Poll(ctx context.Context, s *store.Store, hashes []string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		torrents, err := strikeapi.GetTorrentsInfos(hashes)
+		if err != nil {
+			log.Println("Couldn't fetch the torrents", err)
+		}
+		// The torrents are already stored when s is the LocalStore
+		if strikeapi.LocalStore != s {
+			for i := range torrents {
+				if err := s.Put(&torrents[i]); err != nil {
+					log.Println("Couldn't store the torrent", err)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func days(d time.Duration) float64 {
+	return d.Hours() / 24
+}
+
+// slope will return the slope of the least squares line through the points
+func slope(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
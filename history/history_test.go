@@ -0,0 +1,144 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/store"
+)
+
+var start = time.Date(2015, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+// samples returns one sample a day with the given seeds
+func samples(seeds ...int) []store.Sample {
+	s := []store.Sample{}
+	for i, seed := range seeds {
+		s = append(s, store.Sample{Time: start.Add(time.Duration(i) * 24 * time.Hour), Seeds: seed, Leeches: seed / 10})
+	}
+	return s
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  []store.Sample
+		status   string
+		growth   float64
+		halfLife time.Duration
+		peak     int
+	}{
+		{"empty", nil, StatusUnknown, 0, 0, 0},
+		{"single", samples(10), StatusUnknown, 0, 0, 10},
+		{"rising", samples(10, 20, 30, 40), StatusRising, 10, 0, 40},
+		{"stable", samples(50, 52, 49, 51), StatusStable, 0, -1, 52},
+		// Halves every two days after the peak
+		{"dying", samples(100, 800, 566, 400, 283, 200), StatusDying, 0, 48 * time.Hour, 800},
+		{"dead", samples(5, 3, 0), StatusDead, -2.5, -1, 5},
+	}
+
+	for _, test := range tests {
+		trend := Analyze("HASH", test.samples)
+		if trend.Status != test.status {
+			t.Errorf("%s : expected status %s, got %s", test.name, test.status, trend.Status)
+		}
+		if test.growth != 0 && math.Abs(trend.GrowthRate-test.growth) > 0.01 {
+			t.Errorf("%s : expected a growth of %f, got %f", test.name, test.growth, trend.GrowthRate)
+		}
+		// A half life of -1 isn't checked
+		if diff := trend.HalfLife - test.halfLife; test.halfLife >= 0 && (diff > time.Minute*10 || diff < -time.Minute*10) {
+			t.Errorf("%s : expected a half life of %s, got %s", test.name, test.halfLife, trend.HalfLife)
+		}
+		if trend.Peak != test.peak || trend.Samples != len(test.samples) {
+			t.Errorf("%s : bad trend %+v", test.name, trend)
+		}
+	}
+
+	trend := Analyze("HASH", samples(100, 800, 566, 400, 283, 200))
+	if !trend.PeakTime.Equal(start.Add(24*time.Hour)) || trend.Seeds != 200 || trend.Leeches != 20 || trend.Healthy() {
+		t.Errorf("Bad dying trend %+v", trend)
+	}
+	if !Analyze("HASH", samples(10, 20)).Healthy() {
+		t.Errorf("A rising torrent is healthy")
+	}
+}
+
+func openStore(t *testing.T) (*store.Store, func()) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.Open(filepath.Join(dir, "torrents.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestForHash(t *testing.T) {
+	s, cleanup := openStore(t)
+	defer cleanup()
+
+	s.AddSample("B425907E5755031BDA4A8D1B6DCCACA97DA14C04", store.Sample{Time: start, Seeds: 10})
+	if _, err := ForHash(s, "B425907E5755031BDA4A8D1B6DCCACA97DA14C04"); err != ErrNotEnoughSamples {
+		t.Errorf("Should get an ErrNotEnoughSamples, got %v", err)
+	}
+	s.AddSample("B425907E5755031BDA4A8D1B6DCCACA97DA14C04", store.Sample{Time: start.Add(time.Hour), Seeds: 20})
+
+	trend, err := ForHash(s, "b425907e5755031bda4a8d1b6dccaca97da14c04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trend.Hash != "B425907E5755031BDA4A8D1B6DCCACA97DA14C04" || trend.Status != StatusRising || math.Abs(trend.GrowthRate-240) > 0.01 {
+		t.Errorf("Bad trend %+v", trend)
+	}
+	if _, err := ForHash(s, "unknown"); err != store.ErrNotFound {
+		t.Errorf("Should get an ErrNotFound, got %v", err)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	s, cleanup := openStore(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	seeds := 600
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The first fetch fails, the next ones are still made
+		requests++
+		if requests == 1 {
+			fmt.Fprintln(w, `{"statuscode":503,"message":"Service unavailable"}`)
+			return
+		}
+		seeds += 10
+		fmt.Fprintf(w, `{"results":1,"statuscode":200,"torrents":[{"torrent_hash":"B425907E5755031BDA4A8D1B6DCCACA97DA14C04","torrent_title":"Arch Linux","seeds":%d}]}`, seeds)
+	}))
+	defer ts.Close()
+	strikeapi.APIEndpoint = ts.URL
+
+	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Millisecond)
+	defer cancel()
+	if err := Poll(ctx, s, []string{"B425907E5755031BDA4A8D1B6DCCACA97DA14C04"}, 10*time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("Should stop with the context, got %v", err)
+	}
+
+	samples, _ := s.Samples("B425907E5755031BDA4A8D1B6DCCACA97DA14C04")
+	if len(samples) < 2 || samples[0].Seeds != 610 || samples[1].Seeds != 620 {
+		t.Errorf("Bad samples %+v", samples)
+	}
+}
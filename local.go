@@ -0,0 +1,56 @@
+package strikeapi
+
+import (
+	"errors"
+	"log"
+)
+
+// ErrNoLocalStore is returned by SearchLocal when LocalStore isn't set
+var ErrNoLocalStore = errors.New("no local store set")
+
+// Store represents a local copy of the torrents fetched from the API, see the
+// store package
+type Store interface {
+	Put(t *Torrent) error
+	PutDescription(hash, description string) error
+	Search(query string) ([]Torrent, error)
+}
+
+// LocalStore records every torrent and description fetched when set
+var LocalStore Store
+
+// SearchLocal will search the torrents of the LocalStore, it works offline
+func SearchLocal(query string) ([]Torrent, error) {
+	if LocalStore == nil {
+		return nil, ErrNoLocalStore
+	}
+	torrents, err := LocalStore.Search(query)
+	if err != nil {
+		return nil, err
+	}
+	return filterTorrents(safeTorrents(torrents)), nil
+}
+
+// storeTorrents will record torrents in the LocalStore, the errors are only
+// logged as the store must not break the API calls, and the next torrents
+// are still recorded
+func storeTorrents(torrents []Torrent) {
+	if LocalStore == nil {
+		return
+	}
+	for i := range torrents {
+		if err := LocalStore.Put(&torrents[i]); err != nil {
+			log.Println("Couldn't store the torrent", err)
+		}
+	}
+}
+
+// storeDescription will record a description in the LocalStore
+func storeDescription(hash, description string) {
+	if LocalStore == nil {
+		return
+	}
+	if err := LocalStore.PutDescription(hash, description); err != nil {
+		log.Println("Couldn't store the description", err)
+	}
+}
@@ -0,0 +1,87 @@
+package strikeapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// memoryStore is a Store keeping the torrents in memory
+type memoryStore struct {
+	torrents     []Torrent
+	descriptions map[string]string
+	// fails are the hashes which can't be stored
+	fails map[string]bool
+}
+
+func (s *memoryStore) Put(t *Torrent) error {
+	if s.fails[t.Hash] {
+		return fmt.Errorf("can't store %s", t.Hash)
+	}
+	s.torrents = append(s.torrents, *t)
+	return nil
+}
+
+func (s *memoryStore) PutDescription(hash, description string) error {
+	s.descriptions[hash] = description
+	return nil
+}
+
+func (s *memoryStore) Search(query string) ([]Torrent, error) {
+	torrents := []Torrent{}
+	for _, t := range s.torrents {
+		if strings.Contains(t.Title, query) {
+			torrents = append(torrents, t)
+		}
+	}
+	return torrents, nil
+}
+
+func TestLocalStore(t *testing.T) {
+	if _, err := SearchLocal("Arch"); err != ErrNoLocalStore {
+		t.Errorf("Should get an ErrNoLocalStore, got %v", err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/torrents/descriptions/":
+			fmt.Fprintln(w, `{"statuscode":200,"message":"VGhpcyB0b3JyZW50IGhhcyBubyBkZXNjcmlwdGlvbg=="}`)
+		default:
+			fmt.Fprintln(w, `{"results":1,"statuscode":200,"torrents":[{"torrent_hash":"B425907E5755031BDA4A8D1B6DCCACA97DA14C04","torrent_title":"Arch Linux 2015.01.01 (x86/x64)","seeds":645}]}`)
+		}
+	}))
+	defer ts.Close()
+	APIEndpoint = ts.URL
+
+	s := &memoryStore{descriptions: map[string]string{}}
+	LocalStore = s
+	defer func() { LocalStore = nil }()
+
+	Search("Arch")
+	GetTopTorrents("")
+	GetTorrentInfos("B425907E5755031BDA4A8D1B6DCCACA97DA14C04")
+	GetDescription("B425907E5755031BDA4A8D1B6DCCACA97DA14C04")
+
+	if len(s.torrents) != 3 {
+		t.Errorf("Should have stored 3 torrents, got %d", len(s.torrents))
+	}
+	if s.descriptions["B425907E5755031BDA4A8D1B6DCCACA97DA14C04"] != "This torrent has no description" {
+		t.Errorf("Description not stored : %v", s.descriptions)
+	}
+	if torrents, err := SearchLocal("Arch"); err != nil || len(torrents) != 3 {
+		t.Errorf("Bad local search : %v %v", torrents, err)
+	}
+}
+
+func TestStoreTorrentsError(t *testing.T) {
+	s := &memoryStore{fails: map[string]bool{"A1": true}}
+	LocalStore = s
+	defer func() { LocalStore = nil }()
+
+	storeTorrents([]Torrent{{Hash: "A1"}, {Hash: "B2"}, {Hash: "C3"}})
+	if len(s.torrents) != 2 || s.torrents[0].Hash != "B2" || s.torrents[1].Hash != "C3" {
+		t.Errorf("Should store the torrents after an error, got %v", s.torrents)
+	}
+}
@@ -0,0 +1,317 @@
+// Package notify posts the torrents found by watchers to webhooks.
+//
+// Each delivery is signed with HMAC-SHA256, retried with backoff and
+// recorded in a dead-letter log after its last failure. The body is a JSON
+// Payload, or the output of the template of the destination to fit the
+// payloads of Slack, Discord and others:
+//
+//	n := notify.New(
+//		notify.Destination{Name: "hook", URL: "https://example.com/hook", Secret: "s3cr3t"},
+//		notify.Destination{Name: "slack", URL: slackURL, Template: notify.SlackTemplate},
+//	)
+//	n.DeadLetter = "dead-letter.jsonl"
+//	w.Run(ctx, n.Emit(ctx))
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/format"
+	"github.com/PouuleT/go-strikeapi/watch"
+)
+
+// SignatureHeader holds the HMAC-SHA256 of the body, as "sha256=<hex>"
+const SignatureHeader = "X-Strike-Signature-256"
+
+// Default settings of a Notifier
+const (
+	DefaultRetries = 3
+	DefaultBackoff = time.Second
+)
+
+// Templates fitting the payloads of chat services
+const (
+	SlackTemplate   = `{"text": {{printf "New torrent for %s: <%s|%s> (%s, %d seeds)" .Rule .Magnet .Torrent.Title (humanSize .Torrent.Size) .Torrent.Seeds | json}}}`
+	DiscordTemplate = `{"content": {{printf "New torrent for %s: **%s** (%s, %d seeds)\n%s" .Rule .Torrent.Title (humanSize .Torrent.Size) .Torrent.Seeds .Magnet | json}}}`
+)
+
+// TemplateFuncs are the functions available in the templates, the ones of
+// the format package and json
+var TemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		buf := &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		// Chat services use <url|text> links
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	},
+}
+
+func init() {
+	for name, f := range format.TemplateFuncs {
+		TemplateFuncs[name] = f
+	}
+}
+
+// Payload represents a notification
+type Payload struct {
+	Rule    string            `json:"rule"`
+	Torrent strikeapi.Torrent `json:"torrent"`
+	Magnet  string            `json:"magnet"`
+	Found   time.Time         `json:"found"`
+}
+
+// Destination represents a webhook
+type Destination struct {
+	Name string
+	URL  string
+	// Secret signs the body in the SignatureHeader when set
+	Secret string
+	// Template renders the body from the Payload, JSON when empty
+	Template string
+	Headers  map[string]string
+}
+
+// DeliveryError represents a notification which couldn't be delivered
+type DeliveryError struct {
+	Destination string
+	Attempts    int
+	Err         error
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("delivery to %s failed after %d attempts : %s", e.Destination, e.Attempts, e.Err)
+}
+
+// statusError represents an HTTP error of a webhook
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("webhook answered %d %s", e.status, http.StatusText(e.status))
+}
+
+// retryable will tell whether a delivery error may succeed later, the
+// webhooks rejecting the payload are not retried
+func retryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status >= 500 || statusErr.status == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// deadLetter is a line of the dead-letter log
+type deadLetter struct {
+	Time        time.Time `json:"time"`
+	Destination string    `json:"destination"`
+	URL         string    `json:"url"`
+	Attempts    int       `json:"attempts"`
+	Error       string    `json:"error"`
+	Body        string    `json:"body"`
+}
+
+// Notifier sends the notifications to its destinations
+type Notifier struct {
+	Destinations []Destination
+	HTTPClient   *http.Client
+	// Retries is the number of attempts after the first one
+	Retries int
+	// Backoff is the wait before the first retry, it doubles after each one
+	Backoff time.Duration
+	// DeadLetter is the file where the failed deliveries are appended
+	DeadLetter string
+
+	mu sync.Mutex
+}
+
+// New will create a Notifier with the default settings
+func New(destinations ...Destination) *Notifier {
+	return &Notifier{
+		Destinations: destinations,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+		Retries:      DefaultRetries,
+		Backoff:      DefaultBackoff,
+	}
+}
+
+// NewPayload will create the Payload of a torrent matching a rule
+func NewPayload(rule string, t *strikeapi.Torrent, found time.Time) Payload {
+	return Payload{Rule: rule, Torrent: *t, Magnet: t.MagnetURI, Found: found}
+}
+
+// Notify will deliver the Payload to all the destinations, the errors are
+// DeliveryErrors
+func (n *Notifier) Notify(ctx context.Context, p Payload) error {
+	errs := []error{}
+	for _, d := range n.Destinations {
+		if err := n.deliver(ctx, d, p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Emit returns a function giving the matches of a watcher to the Notifier,
+// the delivery errors are logged
+func (n *Notifier) Emit(ctx context.Context) func(watch.Match) {
+	return func(m watch.Match) {
+		if err := n.Notify(ctx, NewPayload(m.Watch, &m.Torrent, m.Found)); err != nil {
+			log.Println("Couldn't notify", err)
+		}
+	}
+}
+
+// deliver will post the Payload to a destination, with the retries
+func (n *Notifier) deliver(ctx context.Context, d Destination, p Payload) error {
+	body, err := render(d, p)
+	if err != nil {
+		// The dead letter keeps the Payload as JSON, nothing was posted
+		payload, _ := json.Marshal(p)
+		if dlErr := n.writeDeadLetter(d, 0, err, payload); dlErr != nil {
+			log.Println("Couldn't write the dead letter", dlErr)
+		}
+		return &DeliveryError{Destination: d.Name, Err: err}
+	}
+
+	attempts := 0
+	backoff := n.Backoff
+	for {
+		attempts++
+		err = n.post(ctx, d, body)
+		if err == nil {
+			return nil
+		}
+		if attempts > n.Retries || !retryable(err) || ctx.Err() != nil {
+			break
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+		backoff *= 2
+	}
+
+	deliveryErr := &DeliveryError{Destination: d.Name, Attempts: attempts, Err: err}
+	if dlErr := n.writeDeadLetter(d, attempts, err, body); dlErr != nil {
+		log.Println("Couldn't write the dead letter", dlErr)
+	}
+	return deliveryErr
+}
+
+func (n *Notifier) post(ctx context.Context, d Destination, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if d.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(d.Secret, body))
+	}
+	for key, value := range d.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := n.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &statusError{resp.StatusCode}
+	}
+	return nil
+}
+
+// writeDeadLetter will append a failed delivery to the dead-letter log
+func (n *Notifier) writeDeadLetter(d Destination, attempts int, err error, body []byte) error {
+	if n.DeadLetter == "" {
+		return nil
+	}
+	data, jsonErr := json.Marshal(deadLetter{
+		Time:        time.Now(),
+		Destination: d.Name,
+		URL:         d.URL,
+		Attempts:    attempts,
+		Error:       err.Error(),
+		Body:        string(body),
+	})
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, fileErr := os.OpenFile(n.DeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if fileErr != nil {
+		return fileErr
+	}
+	if _, writeErr := f.Write(append(data, '\n')); writeErr != nil {
+		f.Close()
+		return writeErr
+	}
+	return f.Close()
+}
+
+// render will build the body of a destination
+func render(d Destination, p Payload) ([]byte, error) {
+	if d.Template == "" {
+		return json.Marshal(p)
+	}
+	tmpl, err := template.New(d.Name).Funcs(TemplateFuncs).Parse(d.Template)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Sign will return the signature of a body, as sent in the SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify will check the signature of a body, for the receivers
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
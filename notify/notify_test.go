@@ -0,0 +1,191 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/watch"
+)
+
+var testTorrent = strikeapi.Torrent{
+	Title:     "Slackware 14.1 x86_64 DVD ISO",
+	Hash:      "156B69B8643BD11849A5D8F2122E13FBB61BD041",
+	Seeds:     192,
+	Size:      2437393940.48,
+	MagnetURI: "magnet:?xt=urn:btih:156B69B8643BD11849A5D8F2122E13FBB61BD041",
+}
+
+var found = time.Date(2015, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+// receiver is a webhook answering with the given statuses, then 200
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, string(body))
+	r.headers = append(r.headers, req.Header)
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newNotifier(destinations ...Destination) *Notifier {
+	n := New(destinations...)
+	n.Backoff = time.Millisecond
+	return n
+}
+
+func TestNotify(t *testing.T) {
+	hook, slack := &receiver{}, &receiver{}
+	hookServer, slackServer := httptest.NewServer(hook), httptest.NewServer(slack)
+	defer hookServer.Close()
+	defer slackServer.Close()
+
+	n := newNotifier(
+		Destination{Name: "hook", URL: hookServer.URL, Secret: "s3cr3t", Headers: map[string]string{"X-Token": "abc"}},
+		Destination{Name: "slack", URL: slackServer.URL, Template: SlackTemplate},
+	)
+	if err := n.Notify(context.Background(), NewPayload("slackware", &testTorrent, found)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hook.bodies) != 1 {
+		t.Fatalf("Should have one delivery, got %d", len(hook.bodies))
+	}
+	p := Payload{}
+	if err := json.Unmarshal([]byte(hook.bodies[0]), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Rule != "slackware" || p.Torrent.Hash != testTorrent.Hash || p.Magnet != testTorrent.MagnetURI || !p.Found.Equal(found) {
+		t.Errorf("Bad payload %+v", p)
+	}
+	headers := hook.headers[0]
+	if !Verify("s3cr3t", []byte(hook.bodies[0]), headers.Get(SignatureHeader)) || headers.Get("X-Token") != "abc" || headers.Get("Content-Type") != "application/json" {
+		t.Errorf("Bad headers %v", headers)
+	}
+	if Verify("other", []byte(hook.bodies[0]), headers.Get(SignatureHeader)) {
+		t.Errorf("The signature shouldn't match another secret")
+	}
+
+	expected := `{"text": "New torrent for slackware: <magnet:?xt=urn:btih:156B69B8643BD11849A5D8F2122E13FBB61BD041|Slackware 14.1 x86_64 DVD ISO> (2.27 GiB, 192 seeds)"}`
+	if len(slack.bodies) != 1 || slack.bodies[0] != expected {
+		t.Errorf("Bad slack body %q", slack.bodies)
+	}
+	if slack.headers[0].Get(SignatureHeader) != "" {
+		t.Errorf("Deliveries without secret aren't signed")
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"a":1}' | openssl dgst -sha256 -hmac secret
+	if s := Sign("secret", []byte(`{"a":1}`)); s != "sha256=aa9e2e3575f5d7098b6caccd790888c36d5fdb63342a73bada2d6a51747a8494" {
+		t.Errorf("Bad signature %s", s)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	flaky := &receiver{statuses: []int{500, 429}}
+	broken := &receiver{statuses: []int{502, 502, 502, 502, 502}}
+	rejecting := &receiver{statuses: []int{400}}
+	servers := []*httptest.Server{httptest.NewServer(flaky), httptest.NewServer(broken), httptest.NewServer(rejecting)}
+	for _, s := range servers {
+		defer s.Close()
+	}
+
+	n := newNotifier(
+		Destination{Name: "flaky", URL: servers[0].URL},
+		Destination{Name: "broken", URL: servers[1].URL},
+		Destination{Name: "rejecting", URL: servers[2].URL},
+		Destination{Name: "template", URL: servers[0].URL, Template: "{{.Nope}}"},
+	)
+	n.DeadLetter = filepath.Join(dir, "dead-letter.jsonl")
+
+	err = n.Notify(context.Background(), NewPayload("slackware", &testTorrent, found))
+	if err == nil {
+		t.Fatalf("Should fail")
+	}
+	var deliveryErr *DeliveryError
+	if !errors.As(err, &deliveryErr) || deliveryErr.Destination != "broken" || deliveryErr.Attempts != 4 {
+		t.Errorf("Bad error %v", err)
+	}
+	if len(flaky.bodies) != 3 || len(broken.bodies) != 4 || len(rejecting.bodies) != 1 {
+		t.Errorf("Bad attempts : %d %d %d", len(flaky.bodies), len(broken.bodies), len(rejecting.bodies))
+	}
+
+	data, err := ioutil.ReadFile(n.DeadLetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Should have 3 dead letters :\n%s", data)
+	}
+	letter := deadLetter{}
+	json.Unmarshal([]byte(lines[0]), &letter)
+	if letter.Destination != "broken" || letter.Attempts != 4 || letter.Error != "webhook answered 502 Bad Gateway" || !strings.Contains(letter.Body, testTorrent.Hash) {
+		t.Errorf("Bad dead letter %+v", letter)
+	}
+	if !strings.Contains(lines[1], `"destination":"rejecting","url":"`+servers[2].URL+`","attempts":1`) {
+		t.Errorf("Bad dead letter %s", lines[1])
+	}
+	// The template failing isn't posted
+	letter = deadLetter{}
+	json.Unmarshal([]byte(lines[2]), &letter)
+	if letter.Destination != "template" || letter.Attempts != 0 || !strings.Contains(letter.Error, "Nope") || !strings.Contains(letter.Body, testTorrent.Hash) {
+		t.Errorf("Bad dead letter %+v", letter)
+	}
+}
+
+func TestEmitAndCancel(t *testing.T) {
+	broken := &receiver{statuses: []int{500, 500, 500, 500}}
+	server := httptest.NewServer(broken)
+	defer server.Close()
+
+	n := newNotifier(Destination{Name: "broken", URL: server.URL})
+	n.Backoff = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.Emit(ctx)(watch.Match{Watch: "slackware", Torrent: testTorrent, Found: found})
+		close(done)
+	}()
+
+	// The backoff is interrupted by the context
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("The delivery should stop with the context")
+	}
+	if len(broken.bodies) != 1 {
+		t.Errorf("Should have one attempt, got %d", len(broken.bodies))
+	}
+}
@@ -0,0 +1,308 @@
+// Package qbittorrent sends torrents found with the Strike API to a
+// qBittorrent client through its Web API.
+package qbittorrent
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Custom errors
+var (
+	ErrLogin    = errors.New("qbittorrent: login failed")
+	ErrRejected = errors.New("qbittorrent: torrent rejected")
+	ErrNoSource = errors.New("torrent has neither a magnet nor a hash")
+)
+
+// Client represents a qBittorrent Web API
+type Client struct {
+	// URL of the Web UI, e.g. http://localhost:8080
+	URL      string
+	Username string
+	Password string
+
+	// Category, SavePath, Tags and Paused are applied to every added torrent
+	Category string
+	SavePath string
+	Tags     []string
+	Paused   bool
+
+	// HTTPClient sends the requests, it's copied to keep the SID cookie in a
+	// jar of the Client
+	HTTPClient *http.Client
+
+	mu       sync.Mutex
+	client   *http.Client
+	loggedIn bool
+}
+
+// New returns a Client for the given Web UI URL and credentials
+func New(url, username, password string) *Client {
+	return &Client{URL: url, Username: username, Password: password}
+}
+
+// Login will authenticate against the Web API, the SID cookie is kept by
+// the Client for the next calls
+func (c *Client) Login() error {
+	form := url.Values{}
+	form.Add("username", c.Username)
+	form.Add("password", c.Password)
+
+	resp, err := c.do("POST", "/api/v2/auth/login", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "Ok." {
+		return ErrLogin
+	}
+
+	c.mu.Lock()
+	c.loggedIn = true
+	c.mu.Unlock()
+	return nil
+}
+
+// Send will add a Torrent to qBittorrent, using its magnet when available
+// and its .torrent file otherwise, it implements strikeapi.Sink
+func (c *Client) Send(t *strikeapi.Torrent) error {
+	if t.MagnetURI != "" {
+		hash := t.Hash
+		if hash == "" {
+			hash = magnetHash(t.MagnetURI)
+		}
+		return c.addMagnet(hash, t.MagnetURI)
+	}
+	if t.Hash == "" {
+		return ErrNoSource
+	}
+
+	data, err := t.GetTorrentFile()
+	if err != nil {
+		return err
+	}
+	return c.addTorrentFile(t.Hash, t.Hash+".torrent", data)
+}
+
+// AddMagnet will add a torrent from a magnet URI
+func (c *Client) AddMagnet(magnet string) error {
+	return c.addMagnet(magnetHash(magnet), magnet)
+}
+
+// AddTorrentFile will add a torrent from the content of a .torrent file, a
+// torrent refused by qBittorrent gives ErrRejected since its hash is unknown
+func (c *Client) AddTorrentFile(name string, data []byte) error {
+	return c.addTorrentFile("", name, data)
+}
+
+func (c *Client) addMagnet(hash, magnet string) error {
+	return c.add(hash, func(w *multipart.Writer) error {
+		return w.WriteField("urls", magnet)
+	})
+}
+
+func (c *Client) addTorrentFile(hash, name string, data []byte) error {
+	return c.add(hash, func(w *multipart.Writer) error {
+		part, err := w.CreateFormFile("torrents", name)
+		if err != nil {
+			return err
+		}
+		_, err = part.Write(data)
+		return err
+	})
+}
+
+// magnetHash will return the hex info hash of a magnet URI, empty when it
+// has none
+func magnetHash(magnet string) string {
+	u, err := url.Parse(magnet)
+	if err != nil {
+		return ""
+	}
+	for _, xt := range u.Query()["xt"] {
+		if !strings.HasPrefix(xt, "urn:btih:") {
+			continue
+		}
+		hash := strings.TrimPrefix(xt, "urn:btih:")
+		// The base32 hashes are converted to hex
+		if len(hash) == 32 {
+			data, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash))
+			if err != nil {
+				return ""
+			}
+			hash = hex.EncodeToString(data)
+		}
+		return hash
+	}
+	return ""
+}
+
+// add will send a torrent, the hash confirms the duplicates
+func (c *Client) add(hash string, source func(w *multipart.Writer) error) error {
+	c.mu.Lock()
+	loggedIn := c.loggedIn
+	c.mu.Unlock()
+	if !loggedIn {
+		if err := c.Login(); err != nil {
+			return err
+		}
+	}
+
+	err := c.postAdd(source)
+	// The session may have expired, login again once
+	if err == errForbidden {
+		if err = c.Login(); err != nil {
+			return err
+		}
+		if err = c.postAdd(source); err == errForbidden {
+			return fmt.Errorf("qbittorrent: add refused after re-login: %w", err)
+		}
+	}
+	if err != errFails {
+		return err
+	}
+
+	// qBittorrent answers "Fails." for the torrents it already has and the
+	// invalid ones
+	if hash == "" {
+		return ErrRejected
+	}
+	has, err := c.has(hash)
+	if err != nil {
+		return err
+	}
+	if has {
+		return strikeapi.ErrDuplicate
+	}
+	return ErrRejected
+}
+
+// has will tell whether qBittorrent has the torrent of a hash
+func (c *Client) has(hash string) (bool, error) {
+	resp, err := c.do("GET", "/api/v2/torrents/info?hashes="+url.QueryEscape(strings.ToLower(hash)), "", nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("qbittorrent: unexpected status %s", resp.Status)
+	}
+
+	torrents := []struct {
+		Hash string `json:"hash"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&torrents); err != nil {
+		log.Println("Couln't unmarshall result : ", err)
+		return false, err
+	}
+	return len(torrents) > 0, nil
+}
+
+var (
+	errForbidden = errors.New("qbittorrent: forbidden")
+	errFails     = errors.New("qbittorrent: fails")
+)
+
+func (c *Client) postAdd(source func(w *multipart.Writer) error) error {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	if err := source(w); err != nil {
+		return err
+	}
+	if c.Category != "" {
+		w.WriteField("category", c.Category)
+	}
+	if c.SavePath != "" {
+		w.WriteField("savepath", c.SavePath)
+	}
+	if len(c.Tags) > 0 {
+		w.WriteField("tags", strings.Join(c.Tags, ","))
+	}
+	if c.Paused {
+		// qBittorrent 5 renamed paused to stopped
+		w.WriteField("paused", "true")
+		w.WriteField("stopped", "true")
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	resp, err := c.do("POST", "/api/v2/torrents/add", w.FormDataContentType(), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	answer, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return errForbidden
+	case resp.StatusCode == http.StatusUnsupportedMediaType:
+		return ErrRejected
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("qbittorrent: unexpected status %s", resp.Status)
+	case strings.TrimSpace(string(answer)) == "Fails.":
+		return errFails
+	}
+	return nil
+}
+
+func (c *Client) do(method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(c.URL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	// The Web API checks the Referer against its own host
+	req.Header.Set("Referer", c.URL)
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		log.Printf("Counldn't make the %s %s", method, err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// httpClient will return a copy of HTTPClient with the cookie jar of the
+// Client, a shared client like http.DefaultClient never gets the SID cookie
+func (c *Client) httpClient() *http.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client == nil {
+		c.client = &http.Client{}
+		if c.HTTPClient != nil {
+			*c.client = *c.HTTPClient
+		}
+		jar, _ := cookiejar.New(nil)
+		c.client.Jar = jar
+	}
+	return c.client
+}
@@ -0,0 +1,182 @@
+package qbittorrent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+const testMagnet = "magnet:?xt=urn:btih:156B69B8643BD11849A5D8F2122E13FBB61BD041&dn=Slackware+14.1+x86_64+DVD+ISO"
+
+// fakeServer is a local qBittorrent Web API stand-in
+type fakeServer struct {
+	*httptest.Server
+	logins int
+	fields map[string]string
+	files  map[string]string
+	answer string
+	// hashes are the torrents qBittorrent has
+	hashes []string
+	// forbidden refuses every torrent, even logged in
+	forbidden bool
+}
+
+func newFakeServer() *fakeServer {
+	f := &fakeServer{answer: "Ok."}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			r.ParseForm()
+			if r.Form.Get("username") != "admin" || r.Form.Get("password") != "adminadmin" {
+				fmt.Fprint(w, "Fails.")
+				return
+			}
+			f.logins++
+			http.SetCookie(w, &http.Cookie{Name: "SID", Value: "sid-42", Path: "/"})
+			fmt.Fprint(w, "Ok.")
+		case "/api/v2/torrents/add":
+			if c, err := r.Cookie("SID"); err != nil || c.Value != "sid-42" || f.forbidden {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			r.ParseMultipartForm(1 << 20)
+			f.fields = map[string]string{}
+			for k, v := range r.MultipartForm.Value {
+				f.fields[k] = v[0]
+			}
+			f.files = map[string]string{}
+			for k, v := range r.MultipartForm.File {
+				file, _ := v[0].Open()
+				data, _ := ioutil.ReadAll(file)
+				f.files[k] = v[0].Filename + ":" + string(data)
+			}
+			fmt.Fprint(w, f.answer)
+		case "/api/v2/torrents/info":
+			torrents := []string{}
+			for _, hash := range f.hashes {
+				if hash == r.URL.Query().Get("hashes") {
+					torrents = append(torrents, fmt.Sprintf(`{"hash":%q}`, hash))
+				}
+			}
+			fmt.Fprintf(w, "[%s]", strings.Join(torrents, ","))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return f
+}
+
+func TestSendMagnet(t *testing.T) {
+	ts := newFakeServer()
+	defer ts.Close()
+
+	c := New(ts.URL, "admin", "adminadmin")
+	c.Category = "iso"
+	c.SavePath = "/data/iso"
+	c.Tags = []string{"strike", "linux"}
+	c.Paused = true
+
+	var sink strikeapi.Sink = c
+	if err := sink.Send(&strikeapi.Torrent{MagnetURI: testMagnet}); err != nil {
+		t.Fatalf("Error sending the torrent : %s", err)
+	}
+
+	expected := map[string]string{
+		"urls":     testMagnet,
+		"category": "iso",
+		"savepath": "/data/iso",
+		"tags":     "strike,linux",
+		"paused":   "true",
+		"stopped":  "true",
+	}
+	if !reflect.DeepEqual(ts.fields, expected) {
+		t.Errorf("Bad fields sent : %+v", ts.fields)
+	}
+
+	// The SID cookie is reused
+	if err := c.AddMagnet(testMagnet); err != nil {
+		t.Fatalf("Error sending the torrent : %s", err)
+	}
+	if ts.logins != 1 {
+		t.Errorf("Should have logged in once, got %d", ts.logins)
+	}
+}
+
+func TestAddTorrentFile(t *testing.T) {
+	ts := newFakeServer()
+	defer ts.Close()
+
+	c := New(ts.URL, "admin", "adminadmin")
+	if err := c.AddTorrentFile("slackware.torrent", []byte("d4:infoe")); err != nil {
+		t.Fatalf("Error sending the torrent : %s", err)
+	}
+	if ts.files["torrents"] != "slackware.torrent:d4:infoe" {
+		t.Errorf("Bad file sent : %+v", ts.files)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	ts := newFakeServer()
+	defer ts.Close()
+	ts.answer = "Fails."
+	c := New(ts.URL, "admin", "adminadmin")
+
+	// qBittorrent answers "Fails." for an invalid torrent too
+	if err := c.AddMagnet(testMagnet); err != ErrRejected {
+		t.Errorf("Should get an ErrRejected, got %v", err)
+	}
+	if err := c.AddTorrentFile("slackware.torrent", []byte("d4:infoe")); err != ErrRejected {
+		t.Errorf("Should get an ErrRejected without hash, got %v", err)
+	}
+
+	ts.hashes = []string{"156b69b8643bd11849a5d8f2122e13fbb61bd041"}
+	if err := c.AddMagnet(testMagnet); err != strikeapi.ErrDuplicate {
+		t.Errorf("Should get an ErrDuplicate, got %v", err)
+	}
+	// The base32 hashes of the magnets are checked too
+	if err := c.AddMagnet("magnet:?xt=urn:btih:CVVWTODEHPIRQSNF3DZBELQT7O3BXUCB"); err != strikeapi.ErrDuplicate {
+		t.Errorf("Should get an ErrDuplicate from a base32 hash, got %v", err)
+	}
+}
+
+func TestSharedHTTPClient(t *testing.T) {
+	ts := newFakeServer()
+	defer ts.Close()
+
+	shared := &http.Client{}
+	c := New(ts.URL, "admin", "adminadmin")
+	c.HTTPClient = shared
+	if err := c.AddMagnet(testMagnet); err != nil {
+		t.Fatalf("Error sending the torrent : %s", err)
+	}
+	if shared.Jar != nil {
+		t.Error("The SID cookie shouldn't be kept in the shared client")
+	}
+}
+
+func TestBadLogin(t *testing.T) {
+	ts := newFakeServer()
+	defer ts.Close()
+
+	err := New(ts.URL, "admin", "wrong").AddMagnet(testMagnet)
+	if err != ErrLogin {
+		t.Errorf("Should get an ErrLogin, got %v", err)
+	}
+}
+
+func TestForbidden(t *testing.T) {
+	ts := newFakeServer()
+	defer ts.Close()
+	ts.forbidden = true
+
+	err := New(ts.URL, "admin", "adminadmin").AddMagnet(testMagnet)
+	if err == nil || !strings.Contains(err.Error(), "refused after re-login") || ts.logins != 2 {
+		t.Errorf("Should fail after logging in again, got %v and %d logins", err, ts.logins)
+	}
+}
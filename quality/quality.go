@@ -0,0 +1,237 @@
+// Package quality ranks torrents against a Profile describing the allowed
+// and preferred copies, from the releases parsed from their titles:
+//
+//	p := &quality.Profile{
+//		Resolutions:          []string{"1080p", "720p"},
+//		PreferredResolutions: []string{"1080p"},
+//		PreferredSources:     []string{"BluRay", "WEB-DL"},
+//		MaxMBPerMinute:       50,
+//		MinSeedRatio:         1,
+//	}
+//	for _, s := range p.Rank(torrents) {
+//		fmt.Println(s.String())
+//	}
+package quality
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Default runtimes used to compute the size per minute
+const (
+	DefaultEpisodeRuntime = 45 * time.Minute
+	DefaultMovieRuntime   = 110 * time.Minute
+)
+
+// Weights of the preferences, a preferred resolution beats any source and
+// a preferred source beats any codec
+const (
+	ResolutionWeight = 1000
+	SourceWeight     = 100
+	CodecWeight      = 10
+	ProperWeight     = 1
+)
+
+// MaxRank is the rank of the first preferred value, the ones after the
+// ninth all get a rank of 1 so a tier never scores its weight times 10
+const MaxRank = 9
+
+// Profile represents the copies wanted, the empty fields aren't checked.
+// The values are the ones of strikeapi.Release, compared case insensitively,
+// and the torrents whose value is unknown are allowed
+type Profile struct {
+	Name string `json:"name,omitempty"`
+	// Allowed values, any when empty
+	Resolutions []string `json:"resolutions,omitempty"`
+	Sources     []string `json:"sources,omitempty"`
+	Codecs      []string `json:"codecs,omitempty"`
+	// Preferred values score more, the first ones more than the next ones up
+	// to MaxRank
+	PreferredResolutions []string `json:"preferred_resolutions,omitempty"`
+	PreferredSources     []string `json:"preferred_sources,omitempty"`
+	PreferredCodecs      []string `json:"preferred_codecs,omitempty"`
+	// PreferProper scores the proper and repack releases
+	PreferProper bool `json:"prefer_proper,omitempty"`
+	// Size per minute bounds of the videos, in MiB
+	MinMBPerMinute float64 `json:"min_mb_per_minute,omitempty"`
+	MaxMBPerMinute float64 `json:"max_mb_per_minute,omitempty"`
+	// Runtimes of the episodes and movies in minutes, the defaults when zero
+	EpisodeMinutes float64 `json:"episode_minutes,omitempty"`
+	MovieMinutes   float64 `json:"movie_minutes,omitempty"`
+	// MinSeedRatio is the minimum number of seeds per leech
+	MinSeedRatio float64 `json:"min_seed_ratio,omitempty"`
+}
+
+// Score represents the evaluation of a torrent by a Profile
+type Score struct {
+	Torrent strikeapi.Torrent
+	Release strikeapi.Release
+	// Allowed tells whether the torrent passes the checks of the Profile
+	Allowed bool
+	Total   int
+	// Reasons explain the checks and the points, in order
+	Reasons []string
+}
+
+// String will explain the score
+func (s *Score) String() string {
+	verdict := "allowed"
+	if !s.Allowed {
+		verdict = "rejected"
+	}
+	return fmt.Sprintf("%s %s, score %d : %s", s.Torrent.Title, verdict, s.Total, strings.Join(s.Reasons, ", "))
+}
+
+// Score will evaluate a torrent
+func (p *Profile) Score(t *strikeapi.Torrent) Score {
+	s := Score{Torrent: *t, Release: t.Release(), Allowed: true}
+	r := &s.Release
+
+	s.check("resolution", r.Resolution, p.Resolutions)
+	s.check("source", r.Source, p.Sources)
+	s.check("codec", r.Codec, p.Codecs)
+
+	if p.MinMBPerMinute > 0 || p.MaxMBPerMinute > 0 {
+		if runtime := p.runtime(r); runtime > 0 {
+			perMinute := t.Size / (1 << 20) / runtime.Minutes()
+			switch {
+			case p.MinMBPerMinute > 0 && perMinute < p.MinMBPerMinute:
+				s.reject("%.1f MiB per minute below %.1f", perMinute, p.MinMBPerMinute)
+			case p.MaxMBPerMinute > 0 && perMinute > p.MaxMBPerMinute:
+				s.reject("%.1f MiB per minute above %.1f", perMinute, p.MaxMBPerMinute)
+			default:
+				s.explain("%.1f MiB per minute", perMinute)
+			}
+		} else {
+			s.explain("unknown runtime")
+		}
+	}
+
+	if p.MinSeedRatio > 0 {
+		ratio := float64(t.Seeds)
+		if t.Leeches > 0 {
+			ratio /= float64(t.Leeches)
+		}
+		if ratio < p.MinSeedRatio {
+			s.reject("seed ratio %.2f below %.2f", ratio, p.MinSeedRatio)
+		} else {
+			s.explain("seed ratio %.2f", ratio)
+		}
+	}
+
+	if !s.Allowed {
+		return s
+	}
+	s.prefer("resolution", r.Resolution, p.PreferredResolutions, ResolutionWeight)
+	s.prefer("source", r.Source, p.PreferredSources, SourceWeight)
+	s.prefer("codec", r.Codec, p.PreferredCodecs, CodecWeight)
+	if p.PreferProper && (r.Proper || r.Repack) {
+		s.Total += ProperWeight
+		s.explain("proper (+%d)", ProperWeight)
+	}
+	return s
+}
+
+// Rank will score the torrents, the allowed ones first then by score and
+// seeds
+func (p *Profile) Rank(torrents []strikeapi.Torrent) []Score {
+	scores := make([]Score, len(torrents))
+	for i := range torrents {
+		scores[i] = p.Score(&torrents[i])
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		a, b := &scores[i], &scores[j]
+		if a.Allowed != b.Allowed {
+			return a.Allowed
+		}
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Torrent.Seeds > b.Torrent.Seeds
+	})
+	return scores
+}
+
+// runtime will return the expected runtime of a release, 0 when unknown
+func (p *Profile) runtime(r *strikeapi.Release) time.Duration {
+	// Not a video
+	if r.Resolution == "" && r.Source == "" && r.Codec == "" || r.Version != "" || r.Arch != "" {
+		return 0
+	}
+	if !r.IsEpisode() {
+		if p.MovieMinutes > 0 {
+			return time.Duration(p.MovieMinutes * float64(time.Minute))
+		}
+		return DefaultMovieRuntime
+	}
+	// The number of episodes of a season pack is unknown
+	if r.Episode == 0 {
+		return 0
+	}
+	episodes := 1
+	if r.EpisodeEnd > r.Episode {
+		episodes += r.EpisodeEnd - r.Episode
+	}
+	runtime := time.Duration(p.EpisodeMinutes * float64(time.Minute))
+	if runtime == 0 {
+		runtime = DefaultEpisodeRuntime
+	}
+	return time.Duration(episodes) * runtime
+}
+
+// check will reject the values not allowed
+func (s *Score) check(name, value string, allowed []string) {
+	if len(allowed) == 0 {
+		return
+	}
+	switch {
+	case value == "":
+		s.explain("unknown %s", name)
+	case index(allowed, value) < 0:
+		s.reject("%s %s not allowed", name, value)
+	default:
+		s.explain("%s %s allowed", name, value)
+	}
+}
+
+// prefer will add the points of a preferred value
+func (s *Score) prefer(name, value string, preferred []string, weight int) {
+	i := index(preferred, value)
+	if value == "" || i < 0 {
+		return
+	}
+	rank := len(preferred) - i
+	if len(preferred) > MaxRank {
+		rank = MaxRank - i
+	}
+	if rank < 1 {
+		rank = 1
+	}
+	points := rank * weight
+	s.Total += points
+	s.explain("%s %s preferred (+%d)", name, value, points)
+}
+
+func (s *Score) explain(format string, args ...interface{}) {
+	s.Reasons = append(s.Reasons, fmt.Sprintf(format, args...))
+}
+
+func (s *Score) reject(format string, args ...interface{}) {
+	s.Allowed = false
+	s.explain(format, args...)
+}
+
+// index will return the position of a value in a list, case insensitive
+func index(values []string, value string) int {
+	for i, v := range values {
+		if strings.EqualFold(v, value) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,132 @@
+package quality
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+const gib = 1 << 30
+
+var testProfile = Profile{
+	Resolutions:          []string{"1080p", "720p"},
+	PreferredResolutions: []string{"1080p", "720p"},
+	PreferredSources:     []string{"bluray", "WEB-DL"},
+	PreferredCodecs:      []string{"x265"},
+	PreferProper:         true,
+	MinMBPerMinute:       5,
+	MaxMBPerMinute:       40,
+	MinSeedRatio:         1,
+}
+
+var testTorrents = []strikeapi.Torrent{
+	{Title: "Movie.2015.1080p.BluRay.x264-GRP", Hash: "T1", Size: 8 * gib, Seeds: 200, Leeches: 10},
+	{Title: "Movie.2015.1080p.WEB-DL.x265-GRP", Hash: "T2", Size: 3 * gib, Seeds: 50, Leeches: 10},
+	{Title: "Movie.2015.720p.BluRay.x264", Hash: "T3", Size: 2 * gib, Seeds: 100, Leeches: 20},
+	{Title: "Movie.2015.2160p.WEB-DL", Hash: "T4", Size: 4 * gib, Seeds: 300, Leeches: 10},
+	{Title: "Movie.2015.1080p.HDTV.x264", Hash: "T5", Size: 2 * gib, Seeds: 5, Leeches: 10},
+	{Title: "Movie.2015.1080p.BluRay.PROPER.x264", Hash: "T6", Size: 4 * gib, Seeds: 10},
+	{Title: "Movie 2015", Hash: "T7", Size: 1 * gib, Seeds: 10},
+	{Title: "Show.S01E01-E02.720p.WEB-DL", Hash: "T8", Size: 3 * gib, Seeds: 30, Leeches: 1},
+	{Title: "Show.S01E03.720p.HDTV", Hash: "T9", Size: 100 << 20, Seeds: 1},
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		hash    string
+		allowed bool
+		total   int
+		reasons []string
+	}{
+		{"T1", false, 0, []string{"resolution 1080p allowed", "74.5 MiB per minute above 40.0", "seed ratio 20.00"}},
+		{"T2", true, 2110, []string{"resolution 1080p allowed", "27.9 MiB per minute", "seed ratio 5.00", "resolution 1080p preferred (+2000)", "source WEB-DL preferred (+100)", "codec x265 preferred (+10)"}},
+		{"T4", false, 0, []string{"resolution 2160p not allowed", "37.2 MiB per minute", "seed ratio 30.00"}},
+		{"T5", false, 0, []string{"resolution 1080p allowed", "18.6 MiB per minute", "seed ratio 0.50 below 1.00"}},
+		{"T6", true, 2201, []string{"resolution 1080p allowed", "37.2 MiB per minute", "seed ratio 10.00", "resolution 1080p preferred (+2000)", "source BluRay preferred (+200)", "proper (+1)"}},
+		{"T7", true, 0, []string{"unknown resolution", "unknown runtime", "seed ratio 10.00"}},
+		{"T8", true, 1100, []string{"resolution 720p allowed", "34.1 MiB per minute", "seed ratio 30.00", "resolution 720p preferred (+1000)", "source WEB-DL preferred (+100)"}},
+		{"T9", false, 0, []string{"resolution 720p allowed", "2.2 MiB per minute below 5.0", "seed ratio 1.00"}},
+	}
+
+	torrents := map[string]*strikeapi.Torrent{}
+	for i := range testTorrents {
+		torrents[testTorrents[i].Hash] = &testTorrents[i]
+	}
+	for _, test := range tests {
+		s := testProfile.Score(torrents[test.hash])
+		if s.Allowed != test.allowed || s.Total != test.total || !reflect.DeepEqual(s.Reasons, test.reasons) {
+			t.Errorf("Bad score of %s : %s", test.hash, s.String())
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	order := []string{}
+	for _, s := range testProfile.Rank(testTorrents) {
+		order = append(order, s.Torrent.Hash)
+	}
+	if strings.Join(order, ",") != "T6,T2,T3,T8,T7,T4,T1,T5,T9" {
+		t.Errorf("Bad ranking %v", order)
+	}
+
+	// Without checks, everything is allowed and ranked by seeds
+	order = []string{}
+	for _, s := range (&Profile{}).Rank(testTorrents[:3]) {
+		if !s.Allowed || s.Total != 0 || len(s.Reasons) != 0 {
+			t.Errorf("Bad score %s", s.String())
+		}
+		order = append(order, s.Torrent.Hash)
+	}
+	if strings.Join(order, ",") != "T1,T3,T2" {
+		t.Errorf("Bad ranking %v", order)
+	}
+}
+
+func TestRuntimes(t *testing.T) {
+	p := &Profile{MaxMBPerMinute: 40, EpisodeMinutes: 22, MovieMinutes: 90}
+	tests := []struct {
+		title  string
+		reason string
+	}{
+		{"Show.S01E01.720p.HDTV", "46.5 MiB per minute above 40.0"},
+		{"Show.S01E01-E03.720p.HDTV", "15.5 MiB per minute"},
+		{"Show.S01.720p.HDTV", "unknown runtime"},
+		{"Movie.2015.720p.BluRay", "11.4 MiB per minute"},
+		{"Slackware 14.1 x86_64 DVD ISO", "unknown runtime"},
+		{"Slackware 14.1 x86_64", "unknown runtime"},
+	}
+	for _, test := range tests {
+		s := p.Score(&strikeapi.Torrent{Title: test.title, Size: 1 * gib})
+		if len(s.Reasons) != 1 || s.Reasons[0] != test.reason {
+			t.Errorf("%s should be explained by %q, got %q", test.title, test.reason, s.Reasons)
+		}
+	}
+}
+
+func TestProfileJSON(t *testing.T) {
+	p := Profile{}
+	err := json.Unmarshal([]byte(`{"name": "hd", "resolutions": ["1080p"], "max_mb_per_minute": 40, "episode_minutes": 22, "min_seed_ratio": 0.5}`), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "hd" || p.Resolutions[0] != "1080p" || p.MaxMBPerMinute != 40 || p.EpisodeMinutes != 22 || p.MinSeedRatio != 0.5 {
+		t.Errorf("Bad profile %+v", p)
+	}
+}
+
+func TestManyPreferences(t *testing.T) {
+	p := Profile{
+		PreferredResolutions: []string{"1080p", "720p"},
+		PreferredSources:     []string{"BluRay", "Remux", "BDRip", "BRRip", "WEB-DL", "WEBRip", "HDRip", "DVDRip", "HDTV", "PDTV", "WEB"},
+	}
+	bluray720 := p.Score(&strikeapi.Torrent{Title: "Movie.2015.720p.BluRay", Hash: "M1"})
+	web1080 := p.Score(&strikeapi.Torrent{Title: "Movie.2015.1080p.WEB", Hash: "M2"})
+
+	// A preferred resolution beats any source, even with 11 preferred sources
+	if bluray720.Total != 1900 || web1080.Total != 2100 {
+		t.Errorf("Bad scores %s, %s", bluray720.String(), web1080.String())
+	}
+}
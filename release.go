@@ -0,0 +1,335 @@
+package strikeapi
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Release represents the information found in the title of a torrent, the
+// fields not found are empty
+type Release struct {
+	Title string `json:"title"`
+	Year  int    `json:"year,omitempty"`
+	// Season is 0 for the specials, SeasonEnd is the last season of a pack
+	// like S01-S03
+	Season    int `json:"season,omitempty"`
+	SeasonEnd int `json:"season_end,omitempty"`
+	// EpisodeEnd is the last episode of a range like S01E01-E03
+	Episode    int  `json:"episode,omitempty"`
+	EpisodeEnd int  `json:"episode_end,omitempty"`
+	Special    bool `json:"special,omitempty"`
+	Complete   bool `json:"complete,omitempty"`
+	// Resolution is like 1080p, 4K and UHD are 2160p
+	Resolution string   `json:"resolution,omitempty"`
+	Source     string   `json:"source,omitempty"`
+	Codec      string   `json:"codec,omitempty"`
+	Audio      string   `json:"audio,omitempty"`
+	Channels   string   `json:"channels,omitempty"`
+	HDR        string   `json:"hdr,omitempty"`
+	Languages  []string `json:"languages,omitempty"`
+	Group      string   `json:"group,omitempty"`
+	Proper     bool     `json:"proper,omitempty"`
+	Repack     bool     `json:"repack,omitempty"`
+	// Version and Arch describe the software, the version is only looked for
+	// in the titles without episode, resolution, codec nor audio
+	Version string `json:"version,omitempty"`
+	Arch    string `json:"arch,omitempty"`
+}
+
+// IsEpisode will tell whether the release is an episode or a season pack
+func (r *Release) IsEpisode() bool {
+	return r.Episode > 0 || r.Season > 0 || r.Special
+}
+
+// Release will parse the title of the torrent
+func (t *Torrent) Release() Release {
+	return ParseRelease(t.Title)
+}
+
+// bounded will build a regexp matching the expression between separators,
+// the expression is the first group
+func bounded(expr string) *regexp.Regexp {
+	return regexp.MustCompile(`(?:^|[\s._\-\[\(/])(` + expr + `)(?:$|[\s._\-\]\),+/])`)
+}
+
+var (
+	episodeRe      = bounded(`(?i:S(\d{1,3})[\s.]?E(\d{1,4})(?:-?E(\d{1,4})|-(\d{1,4}))?)`)
+	crossEpisodeRe = bounded(`(\d{1,2})x(\d{2,3})(?:-(\d{2,3}))?`)
+	seasonRe       = bounded(`(?i:S(\d{1,2})(?:-S?(\d{1,2}))?|seasons?[\s.]?(\d{1,2})(?:[\s.]?-[\s.]?(\d{1,2}))?)`)
+	absoluteRe     = regexp.MustCompile(`\s-\s(\d{1,3})(?:v\d)?(?:$|[\s\[\(])`)
+	specialRe      = bounded(`(?i:specials?|ova|oad)`)
+	completeRe     = bounded(`(?i:complete)`)
+	yearRe         = bounded(`(?:19|20)\d{2}`)
+	resolutionRe   = bounded(`(?i:2160p|1080p|1080i|720p|576p|480p|4k|uhd)`)
+	sourceRe       = bounded(`(?i:blu-?ray|bdremux|remux|bdrip|brrip|web-?dl|web-?rip|web|hdtv|pdtv|sdtv|dvdrip|dvdscr|dvd[59]?|hdrip|hdcam|camrip|cam|hdts|telesync|telecine|screener|scr)|TS|TC|R5`)
+	codecRe        = bounded(`(?i:x26[45]|h\.?26[45]|hevc|avc|xvid|divx|av1|vp9|mpeg-?2)`)
+	audioRe        = bounded(`(?i:(dts-?hd[\s.]?ma|dts-?hd|dts-?x|dts|truehd|atmos|e-?ac-?3|ddp|dd\+|dd|ac-?3|aac|flac|mp3|opus|lpcm|pcm)(?:[\s.]?([1-7]\.[01]))?)`)
+	hdrRe          = bounded(`(?i:hdr10\+|hdr10plus|hdr10|hdr|dolby[\s.]?vision|dovi)|DV`)
+	languageRe     = bounded(`MULTi|MULTI|TRUEFRENCH|FRENCH|VFF|VFQ|VOSTFR|SUBFRENCH|GERMAN|iTALiAN|ITALIAN|iTA|ITA|SPANISH|ENGLISH|ENG|RUSSIAN|JAPANESE|KOREAN|HINDI|DUTCH|PORTUGUESE|CHINESE|NORDIC|SWEDISH|POLISH|(?i:dual[\s.-]?audio)`)
+	properRe       = bounded(`PROPER`)
+	repackRe       = bounded(`REPACK|RERIP`)
+	versionRe      = bounded(`[vV]\d+(?:\.\d+)*[a-z]?|\d+(?:\.\d+){1,3}[a-z]?`)
+	archRe         = bounded(`(?i:x86[_-]64|x64|amd64|x86|i[3-6]86|arm64|aarch64|armhf|armv7l?|32[\s-]?bits?|64[\s-]?bits?|win64|win32)`)
+	leadingGroupRe = regexp.MustCompile(`^\[([^\]]+)\]\s*`)
+	groupRe        = regexp.MustCompile(`-([A-Za-z0-9]+)(?:\[[^\]]*\])?$`)
+)
+
+// canonical names of the matches, by lowercase name without separators
+var (
+	sources = map[string]string{
+		"bluray": "BluRay", "bdremux": "Remux", "remux": "Remux", "bdrip": "BDRip", "brrip": "BRRip",
+		"webdl": "WEB-DL", "webrip": "WEBRip", "web": "WEB", "hdtv": "HDTV", "pdtv": "PDTV", "sdtv": "SDTV",
+		"dvdrip": "DVDRip", "dvdscr": "SCR", "dvd": "DVD", "dvd5": "DVD", "dvd9": "DVD", "hdrip": "HDRip",
+		"hdcam": "CAM", "camrip": "CAM", "cam": "CAM", "hdts": "TS", "telesync": "TS", "ts": "TS",
+		"telecine": "TC", "tc": "TC", "screener": "SCR", "scr": "SCR", "r5": "R5",
+	}
+	codecs = map[string]string{
+		"x264": "x264", "x265": "x265", "h264": "H.264", "avc": "H.264", "h265": "H.265", "hevc": "H.265",
+		"xvid": "XviD", "divx": "DivX", "av1": "AV1", "vp9": "VP9", "mpeg2": "MPEG-2",
+	}
+	audios = map[string]string{
+		"dtshdma": "DTS-HD MA", "dtshd": "DTS-HD", "dtsx": "DTS:X", "dts": "DTS", "truehd": "TrueHD",
+		"atmos": "Atmos", "eac3": "EAC3", "ddp": "EAC3", "dd+": "EAC3", "dd": "AC3", "ac3": "AC3",
+		"aac": "AAC", "flac": "FLAC", "mp3": "MP3", "opus": "Opus", "lpcm": "PCM", "pcm": "PCM",
+	}
+	hdrs = map[string]string{
+		"hdr10+": "HDR10+", "hdr10plus": "HDR10+", "hdr10": "HDR10", "hdr": "HDR",
+		"dolbyvision": "DV", "dovi": "DV", "dv": "DV",
+	}
+	languages = map[string]string{
+		"multi": "Multi", "truefrench": "French", "french": "French", "vff": "French", "vfq": "French",
+		"vostfr": "VOSTFR", "subfrench": "VOSTFR", "german": "German", "italian": "Italian", "ita": "Italian",
+		"spanish": "Spanish", "english": "English", "eng": "English", "russian": "Russian",
+		"japanese": "Japanese", "korean": "Korean", "hindi": "Hindi", "dutch": "Dutch",
+		"portuguese": "Portuguese", "chinese": "Chinese", "nordic": "Nordic", "swedish": "Swedish",
+		"polish": "Polish", "dualaudio": "Dual Audio",
+	}
+	archs = map[string]string{
+		"x8664": "x86_64", "x64": "x86_64", "amd64": "x86_64", "64bit": "x86_64", "64bits": "x86_64", "win64": "x86_64",
+		"x86": "x86", "i386": "x86", "i486": "x86", "i586": "x86", "i686": "x86", "32bit": "x86", "32bits": "x86", "win32": "x86",
+		"arm64": "arm64", "aarch64": "arm64", "armhf": "armhf", "armv7": "armhf", "armv7l": "armhf",
+	}
+)
+
+// key will lower a match and drop its separators to look it up
+func key(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '_' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// releaseMatch is a match of a bounded regexp, groups[0] is the whole
+// expression and the next ones its groups
+type releaseMatch struct {
+	start, end int
+	groups     []string
+}
+
+func submatches(s string, m []int) releaseMatch {
+	rm := releaseMatch{start: m[2], end: m[3]}
+	for i := 2; i < len(m); i += 2 {
+		group := ""
+		if m[i] >= 0 {
+			group = s[m[i]:m[i+1]]
+		}
+		rm.groups = append(rm.groups, group)
+	}
+	return rm
+}
+
+// findAll will return the matches of a bounded regexp, the separators
+// between two matches are shared
+func findAll(re *regexp.Regexp, s string) []releaseMatch {
+	matches := []releaseMatch{}
+	for pos := 0; pos < len(s); {
+		m := re.FindStringSubmatchIndex(s[pos:])
+		if m == nil {
+			break
+		}
+		for i := range m {
+			if m[i] >= 0 {
+				m[i] += pos
+			}
+		}
+		// Ignore ^ matching in the middle of the title
+		if pos > 0 && m[0] == pos && m[2] == pos {
+			pos++
+			continue
+		}
+		matches = append(matches, submatches(s, m))
+		pos = m[3]
+	}
+	return matches
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// firstOf will return the first non empty string
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// ParseRelease will parse a torrent title, a scene name like
+// "Show.Name.S01E02.1080p.WEB-DL.x264-GRP" or free text like
+// "Slackware 14.1 x86_64 DVD ISO"
+func ParseRelease(title string) Release {
+	r := Release{}
+	s := strings.TrimSpace(title)
+
+	start := 0
+	if m := leadingGroupRe.FindStringSubmatchIndex(s); m != nil {
+		r.Group = s[m[2]:m[3]]
+		start = m[1]
+	}
+
+	// The title ends at the first marker, the spans of the markers aren't
+	// part of the release group
+	end := len(s)
+	spans := [][2]int{}
+	first := func(re *regexp.Regexp) (releaseMatch, bool) {
+		for _, m := range findAll(re, s) {
+			if m.start > start {
+				return m, true
+			}
+		}
+		return releaseMatch{}, false
+	}
+	marker := func(m releaseMatch) {
+		spans = append(spans, [2]int{m.start, m.end})
+		if m.start < end {
+			end = m.start
+		}
+	}
+
+	if m, ok := first(episodeRe); ok {
+		r.Season, r.Episode = atoi(m.groups[1]), atoi(m.groups[2])
+		r.EpisodeEnd = atoi(firstOf(m.groups[3], m.groups[4]))
+		marker(m)
+	} else if m, ok := first(crossEpisodeRe); ok {
+		r.Season, r.Episode, r.EpisodeEnd = atoi(m.groups[1]), atoi(m.groups[2]), atoi(m.groups[3])
+		marker(m)
+	} else if m, ok := first(seasonRe); ok {
+		r.Season = atoi(firstOf(m.groups[1], m.groups[3]))
+		r.SeasonEnd = atoi(firstOf(m.groups[2], m.groups[4]))
+		marker(m)
+	} else if m := absoluteRe.FindStringSubmatchIndex(s); m != nil && m[0] > start {
+		r.Episode = atoi(s[m[2]:m[3]])
+		marker(releaseMatch{start: m[0], end: m[3]})
+	}
+	if r.Season == 0 && r.Episode > 0 && r.SeasonEnd == 0 && episodeRe.MatchString(s) {
+		r.Special = true
+	}
+	if m, ok := first(specialRe); ok {
+		r.Special = true
+		marker(m)
+	}
+	if m, ok := first(completeRe); ok {
+		r.Complete = true
+		marker(m)
+	}
+
+	// The last year, the title may hold another one
+	years := findAll(yearRe, s)
+	for i := len(years) - 1; i >= 0; i-- {
+		if years[i].start > start {
+			r.Year = atoi(years[i].groups[0])
+			marker(years[i])
+			break
+		}
+	}
+
+	if m, ok := first(resolutionRe); ok {
+		r.Resolution = strings.ToLower(m.groups[0])
+		if r.Resolution == "4k" || r.Resolution == "uhd" {
+			r.Resolution = "2160p"
+		}
+		marker(m)
+	}
+	if m, ok := first(sourceRe); ok {
+		r.Source = sources[key(m.groups[0])]
+		marker(m)
+	}
+	if m, ok := first(codecRe); ok {
+		r.Codec = codecs[key(m.groups[0])]
+		marker(m)
+	}
+	if m, ok := first(audioRe); ok {
+		r.Audio = audios[key(m.groups[1])]
+		r.Channels = m.groups[2]
+		marker(m)
+	}
+	if m, ok := first(hdrRe); ok {
+		r.HDR = hdrs[key(m.groups[0])]
+		marker(m)
+	}
+	for _, m := range findAll(languageRe, s) {
+		if m.start <= start {
+			continue
+		}
+		language := languages[key(m.groups[0])]
+		known := false
+		for _, l := range r.Languages {
+			known = known || l == language
+		}
+		if !known {
+			r.Languages = append(r.Languages, language)
+		}
+		marker(m)
+	}
+	if m, ok := first(properRe); ok {
+		r.Proper = true
+		marker(m)
+	}
+	if m, ok := first(repackRe); ok {
+		r.Repack = true
+		marker(m)
+	}
+	if m, ok := first(archRe); ok {
+		r.Arch = archs[key(m.groups[0])]
+		marker(m)
+	}
+	if !r.IsEpisode() && r.Resolution == "" && r.Codec == "" && r.Audio == "" {
+		if m, ok := first(versionRe); ok {
+			r.Version = strings.TrimLeft(m.groups[0], "vV")
+			marker(m)
+		}
+	}
+
+	if m := groupRe.FindStringSubmatchIndex(s); m != nil && r.Group == "" && m[0] >= end {
+		inMarker := false
+		for _, span := range spans {
+			inMarker = inMarker || (m[2] < span[1] && m[3] > span[0])
+		}
+		if !inMarker {
+			r.Group = s[m[2]:m[3]]
+		}
+	}
+
+	r.Title = cleanTitle(s[start:end])
+	return r
+}
+
+// cleanTitle will replace the separators of a scene name by spaces
+func cleanTitle(s string) string {
+	if !strings.Contains(strings.TrimSpace(s), " ") {
+		s = strings.Replace(s, ".", " ", -1)
+	}
+	s = strings.Replace(s, "_", " ", -1)
+	s = strings.TrimFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("-.([", r)
+	})
+	return strings.Join(strings.Fields(s), " ")
+}
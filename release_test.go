@@ -0,0 +1,101 @@
+package strikeapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseRelease(t *testing.T) {
+	tests := []struct {
+		title    string
+		expected Release
+	}{
+		// Software
+		{"Slackware 14.1 x86_64 DVD ISO", Release{Title: "Slackware", Source: "DVD", Version: "14.1", Arch: "x86_64"}},
+		{"Arch Linux 2015.01.01 (x86/x64)", Release{Title: "Arch Linux", Year: 2015, Version: "2015.01.01", Arch: "x86"}},
+		{"Ubuntu 16.04.1 Desktop amd64", Release{Title: "Ubuntu", Version: "16.04.1", Arch: "x86_64"}},
+		{"Ubuntu-14.04-server-i386", Release{Title: "Ubuntu", Version: "14.04", Arch: "x86"}},
+		{"Adobe.Photoshop.CC.2015.v16.0.1.x64", Release{Title: "Adobe Photoshop CC", Year: 2015, Version: "16.0.1", Arch: "x86_64"}},
+		{"7-Zip 9.20 32-bit", Release{Title: "7-Zip", Version: "9.20", Arch: "x86"}},
+		{"VLC media player v2.2.1 Win64", Release{Title: "VLC media player", Version: "2.2.1", Arch: "x86_64"}},
+		{"Microsoft Office 2016 Pro Plus x86", Release{Title: "Microsoft Office", Year: 2016, Arch: "x86"}},
+		{"Raspbian Jessie armhf", Release{Title: "Raspbian Jessie", Arch: "armhf"}},
+		{"Some Book Title", Release{Title: "Some Book Title"}},
+
+		// Episodes
+		{"Show.Name.S01E02.1080p.WEB-DL.DD5.1.H.264-GRP", Release{Title: "Show Name", Season: 1, Episode: 2, Resolution: "1080p", Source: "WEB-DL", Codec: "H.264", Audio: "AC3", Channels: "5.1", Group: "GRP"}},
+		{"Show.Name.S01E02.720p.HDTV.x264-GRP[rarbg]", Release{Title: "Show Name", Season: 1, Episode: 2, Resolution: "720p", Source: "HDTV", Codec: "x264", Group: "GRP"}},
+		{"Show Name S01E01-E03 720p WEB x265", Release{Title: "Show Name", Season: 1, Episode: 1, EpisodeEnd: 3, Resolution: "720p", Source: "WEB", Codec: "x265"}},
+		{"Show.Name.S01E01-03.720p", Release{Title: "Show Name", Season: 1, Episode: 1, EpisodeEnd: 3, Resolution: "720p"}},
+		{"Show.Name.S02E05E06.PROPER.720p.HDTV.x264-KILLERS", Release{Title: "Show Name", Season: 2, Episode: 5, EpisodeEnd: 6, Resolution: "720p", Source: "HDTV", Codec: "x264", Group: "KILLERS", Proper: true}},
+		{"Show.Name.S00E03.Special.480p.HDTV.XviD", Release{Title: "Show Name", Episode: 3, Special: true, Resolution: "480p", Source: "HDTV", Codec: "XviD"}},
+		{"Show.Name.Specials.720p.HDTV", Release{Title: "Show Name", Special: true, Resolution: "720p", Source: "HDTV"}},
+		{"Anime Name OVA 1080p", Release{Title: "Anime Name", Special: true, Resolution: "1080p"}},
+		{"Show.Name.S01-S03.COMPLETE.1080p.BluRay.x264", Release{Title: "Show Name", Season: 1, SeasonEnd: 3, Complete: true, Resolution: "1080p", Source: "BluRay", Codec: "x264"}},
+		{"Show Name Season 2 Complete 720p", Release{Title: "Show Name", Season: 2, Complete: true, Resolution: "720p"}},
+		{"Show Name Seasons 1-4 480p", Release{Title: "Show Name", Season: 1, SeasonEnd: 4, Resolution: "480p"}},
+		{"Show.Name.S03.2160p.NF.WEB-DL.DDP5.1.HDR.HEVC-GRP", Release{Title: "Show Name", Season: 3, Resolution: "2160p", Source: "WEB-DL", Codec: "H.265", Audio: "EAC3", Channels: "5.1", HDR: "HDR", Group: "GRP"}},
+		{"Show.Name.1x02.HDTV.XviD-LOL", Release{Title: "Show Name", Season: 1, Episode: 2, Source: "HDTV", Codec: "XviD", Group: "LOL"}},
+		{"[HorribleSubs] Anime Name - 07 [720p]", Release{Title: "Anime Name", Episode: 7, Resolution: "720p", Group: "HorribleSubs"}},
+		{"Show.Name.2015.S01E02.720p.HDTV.x264-GRP", Release{Title: "Show Name", Year: 2015, Season: 1, Episode: 2, Resolution: "720p", Source: "HDTV", Codec: "x264", Group: "GRP"}},
+		{"Mr. Robot S02E01 1080p", Release{Title: "Mr. Robot", Season: 2, Episode: 1, Resolution: "1080p"}},
+		{"Show_Name_S01E02_720p", Release{Title: "Show Name", Season: 1, Episode: 2, Resolution: "720p"}},
+		{"show.name.s01e02.720p.hdtv.x264", Release{Title: "show name", Season: 1, Episode: 2, Resolution: "720p", Source: "HDTV", Codec: "x264"}},
+		{"Show.Name.S01E02.iTALiAN.720p.HDTV.x264", Release{Title: "Show Name", Season: 1, Episode: 2, Resolution: "720p", Source: "HDTV", Codec: "x264", Languages: []string{"Italian"}}},
+		{"Show.Name.S01E02.VOSTFR.720p.HDTV.x264-GRP", Release{Title: "Show Name", Season: 1, Episode: 2, Resolution: "720p", Source: "HDTV", Codec: "x264", Languages: []string{"VOSTFR"}, Group: "GRP"}},
+		{"Show.Name.S01E02.1080i.HDTV.MPEG2.DD5.1-CtrlHD", Release{Title: "Show Name", Season: 1, Episode: 2, Resolution: "1080i", Source: "HDTV", Codec: "MPEG-2", Audio: "AC3", Channels: "5.1", Group: "CtrlHD"}},
+		{"Show.Name.S10E100.720p.WEB.h264-TBS", Release{Title: "Show Name", Season: 10, Episode: 100, Resolution: "720p", Source: "WEB", Codec: "H.264", Group: "TBS"}},
+		{"Show Name - S01E02 - Episode Title [1080p]", Release{Title: "Show Name", Season: 1, Episode: 2, Resolution: "1080p"}},
+		{"Show.Name.S01E02.WEB-DL", Release{Title: "Show Name", Season: 1, Episode: 2, Source: "WEB-DL"}},
+
+		// Movies
+		{"Movie.Name.2015.1080p.BluRay.x264.DTS-HD.MA.7.1-FGT", Release{Title: "Movie Name", Year: 2015, Resolution: "1080p", Source: "BluRay", Codec: "x264", Audio: "DTS-HD MA", Channels: "7.1", Group: "FGT"}},
+		{"Movie Name (2015) 720p BRRip x264 AAC", Release{Title: "Movie Name", Year: 2015, Resolution: "720p", Source: "BRRip", Codec: "x264", Audio: "AAC"}},
+		{"Blade.Runner.2049.2017.2160p.UHD.BluRay.REMUX.HDR.HEVC.Atmos-EPSiLON", Release{Title: "Blade Runner 2049", Year: 2017, Resolution: "2160p", Source: "BluRay", Codec: "H.265", Audio: "Atmos", HDR: "HDR", Group: "EPSiLON"}},
+		{"2012.2009.1080p.BluRay.x264", Release{Title: "2012", Year: 2009, Resolution: "1080p", Source: "BluRay", Codec: "x264"}},
+		{"Movie.Name.2016.FRENCH.720p.WEB-DL.x264", Release{Title: "Movie Name", Year: 2016, Resolution: "720p", Source: "WEB-DL", Codec: "x264", Languages: []string{"French"}}},
+		{"Movie.Name.2016.MULTi.TRUEFRENCH.1080p.BluRay.x264-GRP", Release{Title: "Movie Name", Year: 2016, Resolution: "1080p", Source: "BluRay", Codec: "x264", Languages: []string{"Multi", "French"}, Group: "GRP"}},
+		{"Movie Name 2014 Dual Audio 720p BluRay", Release{Title: "Movie Name", Year: 2014, Resolution: "720p", Source: "BluRay", Languages: []string{"Dual Audio"}}},
+		{"Movie.Name.2019.REPACK.1080p.WEBRip.x264.AAC5.1", Release{Title: "Movie Name", Year: 2019, Resolution: "1080p", Source: "WEBRip", Codec: "x264", Audio: "AAC", Channels: "5.1", Repack: true}},
+		{"Movie.Name.2018.HDCAM.XviD", Release{Title: "Movie Name", Year: 2018, Source: "CAM", Codec: "XviD"}},
+		{"Movie Name 2017 HDTS x264", Release{Title: "Movie Name", Year: 2017, Source: "TS", Codec: "x264"}},
+		{"Movie.Name.2020.1080p.AMZN.WEB-DL.DDP5.1.H.265-NTb", Release{Title: "Movie Name", Year: 2020, Resolution: "1080p", Source: "WEB-DL", Codec: "H.265", Audio: "EAC3", Channels: "5.1", Group: "NTb"}},
+		{"Movie.Name.2021.2160p.WEB-DL.DV.HDR10+.HEVC", Release{Title: "Movie Name", Year: 2021, Resolution: "2160p", Source: "WEB-DL", Codec: "H.265", HDR: "DV"}},
+		{"Movie.Name.2021.4K.HDR10.WEBRip", Release{Title: "Movie Name", Year: 2021, Resolution: "2160p", Source: "WEBRip", HDR: "HDR10"}},
+		{"Movie.Name.2013.480p.DVDRip.XviD.AC3-GRP", Release{Title: "Movie Name", Year: 2013, Resolution: "480p", Source: "DVDRip", Codec: "XviD", Audio: "AC3", Group: "GRP"}},
+		{"Movie.Name.2012.PROPER.DVDSCR.XviD", Release{Title: "Movie Name", Year: 2012, Source: "SCR", Codec: "XviD", Proper: true}},
+		{"The French Connection 1971 720p BluRay", Release{Title: "The French Connection", Year: 1971, Resolution: "720p", Source: "BluRay"}},
+
+		// Music
+		{"Music Artist - Album Name (2015) FLAC", Release{Title: "Music Artist - Album Name", Year: 2015, Audio: "FLAC"}},
+		{"Music Artist - Album Name 2014 MP3 320kbps", Release{Title: "Music Artist - Album Name", Year: 2014, Audio: "MP3"}},
+	}
+
+	for _, test := range tests {
+		r := ParseRelease(test.title)
+		if !reflect.DeepEqual(r, test.expected) {
+			t.Errorf("%q\n\tgot      %+v\n\texpected %+v", test.title, r, test.expected)
+		}
+	}
+}
+
+func TestTorrentRelease(t *testing.T) {
+	torrent := Torrent{Title: "Show.Name.S01E02.720p.HDTV.x264-GRP", Category: "TV"}
+	r := torrent.Release()
+	if r.Title != "Show Name" || !r.IsEpisode() {
+		t.Errorf("Bad release %+v", r)
+	}
+	if movie := ParseRelease("Movie.Name.2015.1080p"); movie.IsEpisode() {
+		t.Errorf("A movie isn't an episode")
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"title":"Show Name","season":1,"episode":2,"resolution":"720p","source":"HDTV","codec":"x264","group":"GRP"}`
+	if string(data) != expected {
+		t.Errorf("Bad JSON %s", data)
+	}
+}
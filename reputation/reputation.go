@@ -0,0 +1,199 @@
+// Package reputation scores the uploaders from the local history of their
+// releases, and hides or ranks last the torrents of the untrusted ones:
+//
+//	p := reputation.New()
+//	p.Deny = []string{"spammer"}
+//	p.MinTrust = 0.3
+//	p.Learn(localStore.Torrents())
+//	strikeapi.SearchFilter = p
+//
+// The trust of an uploader goes from 0 to 1. The score of its releases grows
+// with their average seeds and falls with the share of releases flagged by
+// the safety checks, the trust moves from a neutral trust to that score as
+// the releases add up. The uploaders without history get the neutral trust. Only the releases with a file list can be flagged, the store only
+// has the file lists of the torrents loaded with strikeapi.GetTorrentsInfos.
+package reputation
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/safety"
+)
+
+// Default settings of an UploaderPolicy
+const (
+	// DefaultReleases is the number of releases giving as much weight to
+	// their score as to the neutral trust
+	DefaultReleases = 5
+	// DefaultSeeds is the average seeds giving half of the trust earned by
+	// popularity
+	DefaultSeeds = 10
+	// DefaultFlagSeverity is the severity of a safety report flagging a
+	// release
+	DefaultFlagSeverity = safety.Medium
+	// NeutralTrust is the trust of the uploaders without history
+	NeutralTrust = 0.5
+)
+
+// Stats represents the history of an uploader
+type Stats struct {
+	Uploader     string  `json:"uploader"`
+	Releases     int     `json:"releases"`
+	AverageSeeds float64 `json:"average_seeds"`
+	// Flagged is the number of releases whose safety report reached the
+	// FlagSeverity
+	Flagged int `json:"flagged"`
+}
+
+// String will summarize the stats
+func (s *Stats) String() string {
+	return fmt.Sprintf("%s : %d releases, %.1f seeds on average, %d flagged", s.Uploader, s.Releases, s.AverageSeeds, s.Flagged)
+}
+
+// UploaderPolicy represents the uploaders trusted, it implements
+// strikeapi.Filter
+type UploaderPolicy struct {
+	// Allow lists the uploaders always trusted
+	Allow []string
+	// Deny lists the uploaders whose torrents are always hidden
+	Deny []string
+	// MinTrust is the trust below which an uploader is untrusted, the
+	// uploaders without history have the NeutralTrust
+	MinTrust float64
+	// Hide hides the torrents of the untrusted uploaders, they're ranked last
+	// otherwise
+	Hide bool
+	// Releases is the number of releases weighing as much as the neutral
+	// trust, Seeds the average seeds giving half of the trust of popularity
+	Releases int
+	Seeds    float64
+	// FlagSeverity is the severity of a safety report flagging a release
+	FlagSeverity safety.Severity
+
+	mu    sync.Mutex
+	stats map[string]*Stats
+}
+
+// New will create an UploaderPolicy with the default settings
+func New() *UploaderPolicy {
+	return &UploaderPolicy{
+		Releases:     DefaultReleases,
+		Seeds:        DefaultSeeds,
+		FlagSeverity: DefaultFlagSeverity,
+		stats:        map[string]*Stats{},
+	}
+}
+
+// Learn will compute the stats of the uploaders from their releases,
+// replacing the previous ones, the same hash is only counted once. The
+// releases without file list are never flagged.
+func (p *UploaderPolicy) Learn(torrents []strikeapi.Torrent) {
+	flagSeverity := p.FlagSeverity
+	if flagSeverity == safety.None {
+		flagSeverity = DefaultFlagSeverity
+	}
+
+	stats := map[string]*Stats{}
+	seen := map[string]bool{}
+	for i := range torrents {
+		t := &torrents[i]
+		hash := strings.ToUpper(t.Hash)
+		if hash != "" && seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		key := strings.ToLower(t.UploaderUsername)
+		s, ok := stats[key]
+		if !ok {
+			s = &Stats{Uploader: t.UploaderUsername}
+			stats[key] = s
+		}
+		// The average is kept as a running mean
+		s.Releases++
+		s.AverageSeeds += (float64(t.Seeds) - s.AverageSeeds) / float64(s.Releases)
+		if report := safety.Inspect(t); report.Severity >= flagSeverity {
+			s.Flagged++
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats = stats
+}
+
+// Stats will return the stats of an uploader, empty without history
+func (p *UploaderPolicy) Stats(uploader string) Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if s, ok := p.stats[strings.ToLower(uploader)]; ok {
+		return *s
+	}
+	return Stats{Uploader: uploader}
+}
+
+// Trust will return the trust of an uploader, 1 when allowed, 0 when denied
+// and NeutralTrust without history
+func (p *UploaderPolicy) Trust(uploader string) float64 {
+	switch {
+	case contains(p.Allow, uploader):
+		return 1
+	case contains(p.Deny, uploader):
+		return 0
+	}
+
+	s := p.Stats(uploader)
+	if s.Releases == 0 {
+		return NeutralTrust
+	}
+	releases, seeds := float64(p.Releases), p.Seeds
+	if releases <= 0 {
+		releases = DefaultReleases
+	}
+	if seeds <= 0 {
+		seeds = DefaultSeeds
+	}
+	popularity := s.AverageSeeds / (s.AverageSeeds + seeds)
+	clean := 1 - float64(s.Flagged)/float64(s.Releases)
+	// A few releases only move the trust a little from the neutral trust
+	weight := float64(s.Releases) / (float64(s.Releases) + releases)
+	return weight*popularity*clean + (1-weight)*NeutralTrust
+}
+
+// Trusted will tell whether an uploader is trusted
+func (p *UploaderPolicy) Trusted(uploader string) bool {
+	if contains(p.Deny, uploader) && !contains(p.Allow, uploader) {
+		return false
+	}
+	return p.Trust(uploader) >= p.MinTrust
+}
+
+// Filter will drop the torrents of the denied uploaders, and hide or move
+// after the others the torrents of the untrusted ones, keeping their order
+func (p *UploaderPolicy) Filter(torrents []strikeapi.Torrent) []strikeapi.Torrent {
+	trusted := []strikeapi.Torrent{}
+	untrusted := []strikeapi.Torrent{}
+	for _, t := range torrents {
+		switch {
+		case p.Trusted(t.UploaderUsername):
+			trusted = append(trusted, t)
+		case p.Hide || contains(p.Deny, t.UploaderUsername):
+		default:
+			untrusted = append(untrusted, t)
+		}
+	}
+	return append(trusted, untrusted...)
+}
+
+func contains(uploaders []string, uploader string) bool {
+	for _, u := range uploaders {
+		if strings.EqualFold(u, uploader) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package reputation
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// history has a good uploader, a new one and one sharing executables
+var history = []strikeapi.Torrent{
+	{Hash: "G1", Title: "Movie.2015.720p.BluRay", UploaderUsername: "Good", Seeds: 20},
+	{Hash: "G2", Title: "Movie.2016.720p.BluRay", UploaderUsername: "good", Seeds: 40},
+	{Hash: "G3", Title: "Movie.2017.720p.BluRay", UploaderUsername: "Good", Seeds: 30},
+	{Hash: "G4", Title: "Movie.2018.720p.BluRay", UploaderUsername: "Good", Seeds: 30},
+	{Hash: "G5", Title: "Movie.2019.720p.BluRay", UploaderUsername: "Good", Seeds: 30},
+	// The same hash fetched twice
+	{Hash: "g5", Title: "Movie.2019.720p.BluRay", UploaderUsername: "Good", Seeds: 1000},
+	{Hash: "N1", Title: "Album 2015", UploaderUsername: "New", Seeds: 10},
+	{Hash: "B1", Title: "Movie.2015.1080p", Category: strikeapi.Movies, UploaderUsername: "Bad", Seeds: 100, FilesInfo: &strikeapi.FilesInfo{
+		FileInfo: []strikeapi.FileInfo{{FileName: "movie.mkv.exe", FileSize: 1000}},
+	}},
+	{Hash: "B2", Title: "Movie.2016.720p", UploaderUsername: "Bad", Seeds: 100},
+}
+
+func TestLearn(t *testing.T) {
+	p := New()
+	p.Learn(history)
+
+	tests := []struct {
+		uploader string
+		stats    Stats
+		trust    float64
+	}{
+		// 5/10 * 30/40 + 5/10 * 0.5
+		{"GOOD", Stats{Uploader: "Good", Releases: 5, AverageSeeds: 30}, 0.625},
+		// 1/6 * 10/20 + 5/6 * 0.5
+		{"New", Stats{Uploader: "New", Releases: 1, AverageSeeds: 10}, 0.5},
+		// 2/7 * 100/110 * 1/2 + 5/7 * 0.5
+		{"Bad", Stats{Uploader: "Bad", Releases: 2, AverageSeeds: 100, Flagged: 1}, 0.4870},
+		{"Unknown", Stats{Uploader: "Unknown"}, NeutralTrust},
+	}
+	for _, test := range tests {
+		s := p.Stats(test.uploader)
+		if s != test.stats {
+			t.Errorf("Bad stats of %s : %s", test.uploader, s.String())
+		}
+		if trust := p.Trust(test.uploader); math.Abs(trust-test.trust) > 0.0001 {
+			t.Errorf("Bad trust of %s : %.4f", test.uploader, trust)
+		}
+	}
+
+	// Learning again replaces the stats
+	p.Learn(history[:1])
+	if s := p.Stats("Good"); s.Releases != 1 {
+		t.Errorf("Bad stats %s", s.String())
+	}
+}
+
+func TestFirstRelease(t *testing.T) {
+	p := New()
+	// A first release with modest seeds keeps at least the neutral trust
+	for seeds := DefaultSeeds; seeds <= 1000; seeds *= 10 {
+		p.Learn([]strikeapi.Torrent{{Hash: "F1", UploaderUsername: "First", Seeds: seeds}})
+		if trust := p.Trust("First"); trust < NeutralTrust {
+			t.Errorf("A first release with %d seeds should have at least the neutral trust, got %.4f", seeds, trust)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	p := New()
+	p.Learn(history)
+	p.MinTrust = 0.5
+	p.Allow = []string{"Unknown"}
+	p.Deny = []string{"new"}
+
+	results := []strikeapi.Torrent{
+		{Hash: "R1", UploaderUsername: "Bad"},
+		{Hash: "R2", UploaderUsername: "Good"},
+		{Hash: "R3", UploaderUsername: "New"},
+		{Hash: "R4", UploaderUsername: "Stranger"},
+		{Hash: "R5", UploaderUsername: "unknown"},
+	}
+	order := func(torrents []strikeapi.Torrent) string {
+		hashes := []string{}
+		for _, t := range torrents {
+			hashes = append(hashes, t.Hash)
+		}
+		return strings.Join(hashes, ",")
+	}
+
+	// The uploaders without history, like Stranger, have a neutral trust
+	if result := order(p.Filter(results)); result != "R2,R4,R5,R1" {
+		t.Errorf("Bad ranking %s", result)
+	}
+	p.Hide = true
+	if result := order(p.Filter(results)); result != "R2,R4,R5" {
+		t.Errorf("Bad filtering %s", result)
+	}
+	p.MinTrust = 0.6
+	if result := order(p.Filter(results)); result != "R2,R5" {
+		t.Errorf("Bad filtering above the neutral trust %s", result)
+	}
+	p.MinTrust = 0.5
+	// Allowed wins over denied
+	p.Allow = append(p.Allow, "NEW")
+	if !p.Trusted("new") || p.Trust("new") != 1 {
+		t.Error("An allowed uploader should be trusted")
+	}
+}
+
+func TestSearchFilter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"results":2,"statuscode":200,"torrents":[{"torrent_hash":"S1","uploader_username":"Bad"},{"torrent_hash":"S2","uploader_username":"Good"}]}`)
+	}))
+	defer ts.Close()
+	strikeapi.APIEndpoint = ts.URL
+
+	p := New()
+	p.Learn(history)
+	p.MinTrust = 0.5
+	strikeapi.SearchFilter = p
+	defer func() { strikeapi.SearchFilter = nil }()
+
+	torrents, err := strikeapi.Search("Movie")
+	if err != nil || len(torrents) != 2 || torrents[0].Hash != "S2" {
+		t.Errorf("Bad search results : %v %v", torrents, err)
+	}
+}
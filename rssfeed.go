@@ -0,0 +1,331 @@
+package strikeapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Custom errors of the torrent feeds
+var (
+	ErrNoRSSFeed       = errors.New("torrent has no rss feed")
+	ErrFeedNotModified = errors.New("feed not modified")
+)
+
+// Kinds of the torrent feed items
+const (
+	FeedItemComment = "comment"
+	FeedItemUpdate  = "update"
+)
+
+// TorrentFeed represents the RSS feed of a Torrent
+type TorrentFeed struct {
+	Title       string
+	Link        string
+	Description string
+	Updated     time.Time
+	Items       []FeedItem
+
+	// ETag and LastModified are sent back by FetchTorrentFeed to make a
+	// conditional request
+	ETag         string
+	LastModified string
+}
+
+// FeedItem represents a comment or an update in a TorrentFeed
+type FeedItem struct {
+	Kind        string
+	Title       string
+	Link        string
+	GUID        string
+	Author      string
+	Description string
+	Published   time.Time
+}
+
+// Comments will return the comments of the TorrentFeed
+func (f *TorrentFeed) Comments() []FeedItem {
+	return f.itemsOfKind(FeedItemComment)
+}
+
+// Updates will return the updates of the TorrentFeed
+func (f *TorrentFeed) Updates() []FeedItem {
+	return f.itemsOfKind(FeedItemUpdate)
+}
+
+func (f *TorrentFeed) itemsOfKind(kind string) []FeedItem {
+	items := []FeedItem{}
+	for _, item := range f.Items {
+		if item.Kind == kind {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// FetchTorrentFeed will download and parse the RSS feed of a Torrent
+// When previous is given the request is conditional and ErrFeedNotModified
+// is returned if the feed didn't change
+func FetchTorrentFeed(ctx context.Context, t *Torrent, previous *TorrentFeed) (*TorrentFeed, error) {
+	if t.RSSFeed == "" {
+		return nil, ErrNoRSSFeed
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", t.RSSFeed, nil)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		if previous.ETag != "" {
+			req.Header.Set("If-None-Match", previous.ETag)
+		}
+		if previous.LastModified != "" {
+			req.Header.Set("If-Modified-Since", previous.LastModified)
+		}
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		log.Println("Counldn't make the GET ", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrFeedNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error while getting the rss feed : %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Println("Couldn't read response body", err)
+		return nil, err
+	}
+
+	feed, err := ParseTorrentFeed(body)
+	if err != nil {
+		return nil, err
+	}
+	feed.ETag = resp.Header.Get("ETag")
+	feed.LastModified = resp.Header.Get("Last-Modified")
+	return feed, nil
+}
+
+// ParseTorrentFeed will parse the RSS feed of a Torrent
+// The parser is tolerant: unknown entities, unclosed tags, invalid
+// characters and truncated documents are accepted as long as the channel
+// could be found
+func ParseTorrentFeed(data []byte) (*TorrentFeed, error) {
+	dec := xml.NewDecoder(bytes.NewReader(stripInvalidXMLChars(data)))
+	dec.Strict = false
+	dec.AutoClose = feedAutoClose
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = charsetReader
+
+	feed := &TorrentFeed{}
+	var item *FeedItem
+	var path []string
+	foundChannel := false
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !foundChannel {
+				log.Println("Couln't parse the feed : ", err)
+				return nil, err
+			}
+			// Keep what was parsed from a broken document
+			break
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(tok.Name.Local)
+			path = append(path, name)
+			switch name {
+			case "channel":
+				foundChannel = true
+			case "item":
+				item = &FeedItem{}
+			}
+		case xml.EndElement:
+			name := strings.ToLower(tok.Name.Local)
+			if name == "item" && item != nil {
+				if item.Kind == "" {
+					item.Kind = feedItemKind(item)
+				}
+				feed.Items = append(feed.Items, *item)
+				item = nil
+			}
+			// Pop up to the matching element, missing end tags are skipped
+			for i := len(path) - 1; i >= 0; i-- {
+				if path[i] == name {
+					path = path[:i]
+					break
+				}
+			}
+		case xml.CharData:
+			if len(path) == 0 {
+				continue
+			}
+			text := strings.TrimSpace(string(tok))
+			if text == "" {
+				continue
+			}
+			if item != nil {
+				setItemField(item, path[len(path)-1], text)
+			} else {
+				setFeedField(feed, path[len(path)-1], text)
+			}
+		}
+	}
+
+	if !foundChannel {
+		return nil, errors.New("no rss channel found")
+	}
+	// A truncated item is kept
+	if item != nil && item.Title != "" {
+		item.Kind = feedItemKind(item)
+		feed.Items = append(feed.Items, *item)
+	}
+	return feed, nil
+}
+
+func setFeedField(feed *TorrentFeed, name, text string) {
+	switch name {
+	case "title":
+		feed.Title += text
+	case "link":
+		feed.Link = text
+	case "description":
+		feed.Description += text
+	case "lastbuilddate", "pubdate":
+		if t, ok := parseFeedDate(text); ok && t.After(feed.Updated) {
+			feed.Updated = t
+		}
+	}
+}
+
+func setItemField(item *FeedItem, name, text string) {
+	switch name {
+	case "title":
+		item.Title += text
+	case "link":
+		item.Link = text
+	case "guid":
+		item.GUID = text
+	case "author", "creator":
+		item.Author = text
+	case "description", "encoded":
+		item.Description += text
+	case "pubdate", "date":
+		if t, ok := parseFeedDate(text); ok {
+			item.Published = t
+		}
+	case "category":
+		switch strings.ToLower(text) {
+		case FeedItemComment, "comments":
+			item.Kind = FeedItemComment
+		case FeedItemUpdate, "updates":
+			item.Kind = FeedItemUpdate
+		}
+	}
+}
+
+// feedItemKind will guess the kind of an item without category
+func feedItemKind(item *FeedItem) string {
+	for _, s := range []string{item.GUID, item.Link, item.Title} {
+		if strings.Contains(strings.ToLower(s), "comment") {
+			return FeedItemComment
+		}
+	}
+	return FeedItemUpdate
+}
+
+// feedAutoClose are the HTML void elements found in feeds, xml.HTMLAutoClose
+// can't be used as it contains link
+var feedAutoClose = []string{"br", "hr", "img", "input", "meta", "wbr"}
+
+// feedDateLayouts are the date layouts found in RSS feeds
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+	UploadDateLayout,
+}
+
+func parseFeedDate(s string) (time.Time, bool) {
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// stripInvalidXMLChars will remove the control characters XML forbids, byte
+// by byte so the feeds which aren't in UTF-8 are kept as is
+func stripInvalidXMLChars(data []byte) []byte {
+	clean := make([]byte, 0, len(data))
+	for _, c := range data {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+			continue
+		}
+		clean = append(clean, c)
+	}
+	return clean
+}
+
+// windows1252 are the characters of Windows-1252 from 0x80 to 0x9F, the
+// others are the same as ISO-8859-1
+var windows1252 = [32]rune{
+	'\u20AC', '\u0081', '\u201A', '\u0192', '\u201E', '\u2026', '\u2020', '\u2021',
+	'\u02C6', '\u2030', '\u0160', '\u2039', '\u0152', '\u008D', '\u017D', '\u008F',
+	'\u0090', '\u2018', '\u2019', '\u201C', '\u201D', '\u2022', '\u2013', '\u2014',
+	'\u02DC', '\u2122', '\u0161', '\u203A', '\u0153', '\u009D', '\u017E', '\u0178',
+}
+
+// charsetReader will decode the feeds in ISO-8859-1 or Windows-1252 to
+// UTF-8, the other charsets aren't supported
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	var high *[32]rune
+	switch strings.ToLower(charset) {
+	case "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "latin-1", "l1":
+	case "windows-1252", "cp1252":
+		high = &windows1252
+	default:
+		return nil, fmt.Errorf("unsupported charset %s", charset)
+	}
+
+	data, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	decoded := make([]rune, len(data))
+	for i, c := range data {
+		decoded[i] = rune(c)
+		if high != nil && c >= 0x80 && c < 0xA0 {
+			decoded[i] = high[c-0x80]
+		}
+	}
+	return strings.NewReader(string(decoded)), nil
+}
@@ -0,0 +1,134 @@
+package strikeapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const rawRSSFeed = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<title>Slackware 14.1 x86_64 DVD ISO</title>
+	<link>https://getstrike.net/torrents/156B69B8643BD11849A5D8F2122E13FBB61BD041</link>
+	<description>Comments and updates &nbsp;&mdash; Slackware</description>
+	<lastBuildDate>Tue, 03 Mar 2015 10:00:00 +0000</lastBuildDate>
+	<item>
+		<title>Comment by Nusantara</title>
+		<link>https://getstrike.net/torrents/156B69B8643BD11849A5D8F2122E13FBB61BD041#comment-1</link>
+		<dc:creator>Nusantara</dc:creator>
+		<description>Works fine, thanks & enjoy<br></description>
+		<pubDate>Mon, 02 Mar 2015 09:30:00 +0000</pubDate>
+	</item>
+	<item>
+		<title>Seeds updated</title>
+		<category>update</category>
+		<guid>156B69B8643BD11849A5D8F2122E13FBB61BD041-update-2</guid>
+		<description>192 seeds` + "\x01" + `</description>
+		<pubDate>Tue, 3 Mar 2015 10:00:00 GMT</pubDate>
+	</item>
+	<item>
+		<title>Comment by The_Doctor-</title>
+		<category>comment</category>
+		<description>Truncated`
+
+func TestParseTorrentFeed(t *testing.T) {
+	feed, err := ParseTorrentFeed([]byte(rawRSSFeed))
+	if err != nil {
+		t.Fatalf("Error parsing the feed : %s", err)
+	}
+
+	if feed.Title != "Slackware 14.1 x86_64 DVD ISO" || feed.Description != "Comments and updates  — Slackware" {
+		t.Errorf("Bad feed : %+v", feed)
+	}
+	if !feed.Updated.Equal(time.Date(2015, time.March, 3, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Bad updated date : %s", feed.Updated)
+	}
+
+	expectedComments := []FeedItem{
+		{
+			Kind:        FeedItemComment,
+			Title:       "Comment by Nusantara",
+			Link:        "https://getstrike.net/torrents/156B69B8643BD11849A5D8F2122E13FBB61BD041#comment-1",
+			Author:      "Nusantara",
+			Description: "Works fine, thanks & enjoy",
+			Published:   time.Date(2015, time.March, 2, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			Kind:        FeedItemComment,
+			Title:       "Comment by The_Doctor-",
+			Description: "Truncated",
+		},
+	}
+	if comments := feed.Comments(); !reflect.DeepEqual(comments, expectedComments) {
+		t.Errorf("Comments not properly set : %+v", comments)
+	}
+
+	updates := feed.Updates()
+	if len(updates) != 1 || updates[0].Description != "192 seeds" || updates[0].Published.IsZero() {
+		t.Errorf("Updates not properly set : %+v", updates)
+	}
+}
+
+func TestParseTorrentFeedCharset(t *testing.T) {
+	tests := map[string]string{
+		"ISO-8859-1":   "Am\xe9lie \xabPoulain\xbb",
+		"windows-1252": "Am\xe9lie \x93Poulain\x94 \x80",
+	}
+	expected := map[string]string{
+		"ISO-8859-1":   "Amélie «Poulain»",
+		"windows-1252": "Amélie “Poulain” €",
+	}
+	for charset, title := range tests {
+		feed, err := ParseTorrentFeed([]byte(`<?xml version="1.0" encoding="` + charset + `"?><rss><channel><title>` + title + `</title></channel></rss>`))
+		if err != nil || feed.Title != expected[charset] {
+			t.Errorf("%s : expected %q, got %+v %v", charset, expected[charset], feed, err)
+		}
+	}
+
+	if _, err := ParseTorrentFeed([]byte(`<?xml version="1.0" encoding="KOI8-R"?><rss><channel><title>x</title></channel></rss>`)); err == nil {
+		t.Errorf("Should fail with an unsupported charset")
+	}
+}
+
+func TestParseTorrentFeedNotRSS(t *testing.T) {
+	if _, err := ParseTorrentFeed([]byte(`{"statuscode":404}`)); err == nil {
+		t.Errorf("Should get an error")
+	}
+}
+
+func TestFetchTorrentFeed(t *testing.T) {
+	// Fake server with a fake answer handling conditional requests
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Tue, 03 Mar 2015 10:00:00 GMT")
+		fmt.Fprintln(w, rawRSSFeed)
+	}))
+	defer ts.Close()
+
+	torrent := &Torrent{RSSFeed: ts.URL + "/torrents/156B69B8643BD11849A5D8F2122E13FBB61BD041?rss=1"}
+
+	feed, err := FetchTorrentFeed(context.Background(), torrent, nil)
+	if err != nil {
+		t.Fatalf("Error fetching the feed : %s", err)
+	}
+	if len(feed.Items) != 3 || feed.ETag != `"v1"` || feed.LastModified != "Tue, 03 Mar 2015 10:00:00 GMT" {
+		t.Errorf("Feed not properly set : %+v", feed)
+	}
+
+	if _, err := FetchTorrentFeed(context.Background(), torrent, feed); err != ErrFeedNotModified {
+		t.Errorf("Should get an ErrFeedNotModified, got %v", err)
+	}
+
+	if _, err := FetchTorrentFeed(context.Background(), &Torrent{}, nil); err != ErrNoRSSFeed {
+		t.Errorf("Should get an ErrNoRSSFeed")
+	}
+}
@@ -0,0 +1,575 @@
+// Package rules picks the torrents to grab automatically from search or
+// watcher results, following declarative rules written in JSON:
+//
+//	{"rules": [{
+//		"name": "tv",
+//		"category": "TV",
+//		"title": "S\\d+E\\d+",
+//		"min_size": "200MB",
+//		"max_size": "2GB",
+//		"min_seeds": 20,
+//		"uploader_denylist": ["spammer"],
+//		"max_risk": "low",
+//		"prefer": ["1080p", "720p"]
+//	}]}
+//
+// Each rule keeps the best torrent of each episode or release and hands it
+// to a sink. Every Decision explains why a torrent was accepted or rejected,
+// DryRun only gives the decisions.
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/format"
+	"github.com/PouuleT/go-strikeapi/quality"
+	"github.com/PouuleT/go-strikeapi/safety"
+	"github.com/PouuleT/go-strikeapi/watch"
+)
+
+// Groupings of the candidates
+const (
+	GroupEpisode = "episode"
+	GroupRelease = "release"
+)
+
+// Custom errors
+var (
+	ErrNoName = errors.New("rule without name")
+	ErrNoSink = errors.New("no sink to send the torrents to")
+)
+
+// Size is a number of bytes, written in JSON as a number or a string like
+// "200MB" or "1.5 GiB", the units are powers of 1024
+type Size float64
+
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tb":  1 << 40,
+	"tib": 1 << 40,
+}
+
+// ParseSize will parse a size like "200MB"
+func ParseSize(s string) (Size, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	value, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad size %q", s)
+	}
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("bad size unit %q", s)
+	}
+	return Size(value * unit), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *Size) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var bytes float64
+		if err := json.Unmarshal(data, &bytes); err != nil {
+			return fmt.Errorf("bad size %s", data)
+		}
+		*s = Size(bytes)
+		return nil
+	}
+	size, err := ParseSize(text)
+	if err != nil {
+		return err
+	}
+	*s = size
+	return nil
+}
+
+func (s Size) String() string {
+	return format.HumanSize(float64(s))
+}
+
+// Rule represents the torrents to grab, the empty fields aren't checked
+type Rule struct {
+	Name        string `json:"name"`
+	Category    string `json:"category,omitempty"`
+	SubCategory string `json:"subcategory,omitempty"`
+	// Title is a regular expression the title must match, case insensitive
+	Title string `json:"title,omitempty"`
+	// Exclude is a regular expression the title must not match
+	Exclude          string   `json:"exclude,omitempty"`
+	MinSize          Size     `json:"min_size,omitempty"`
+	MaxSize          Size     `json:"max_size,omitempty"`
+	MinSeeds         int      `json:"min_seeds,omitempty"`
+	UploaderDenylist []string `json:"uploader_denylist,omitempty"`
+	// Prefer are regular expressions scoring the candidates, the first ones
+	// weigh more
+	Prefer []string `json:"prefer,omitempty"`
+	// Quality rejects and scores the copies, its score adds to the one of
+	// Prefer
+	Quality *quality.Profile `json:"quality,omitempty"`
+	// MaxRisk rejects the torrents whose safety report is more severe, like
+	// "low", and the torrents without file list
+	MaxRisk *safety.Severity `json:"max_risk,omitempty"`
+	// GroupBy is episode or release, by default the episodes when the title
+	// has one and the release otherwise
+	GroupBy string `json:"group_by,omitempty"`
+	// Sink is the name of the sink of the Engine receiving the torrents, the
+	// default one when empty
+	Sink string `json:"sink,omitempty"`
+
+	title   *regexp.Regexp
+	exclude *regexp.Regexp
+	prefer  []*regexp.Regexp
+}
+
+// compile will check the rule and compile its regular expressions
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return ErrNoName
+	}
+	var err error
+	compile := func(expr string) *regexp.Regexp {
+		if expr == "" || err != nil {
+			return nil
+		}
+		var re *regexp.Regexp
+		re, err = regexp.Compile("(?i)" + expr)
+		return re
+	}
+	r.title = compile(r.Title)
+	r.exclude = compile(r.Exclude)
+	r.prefer = nil
+	for _, expr := range r.Prefer {
+		r.prefer = append(r.prefer, compile(expr))
+	}
+	if err != nil {
+		return fmt.Errorf("rule %s : %s", r.Name, err)
+	}
+
+	switch r.GroupBy {
+	case "", GroupEpisode, GroupRelease:
+	default:
+		return fmt.Errorf("rule %s : group_by must be episode or release, got %q", r.Name, r.GroupBy)
+	}
+	if r.MaxSize > 0 && r.MinSize > r.MaxSize {
+		return fmt.Errorf("rule %s : min_size is above max_size", r.Name)
+	}
+	return nil
+}
+
+// Load will read rules written as a JSON array or as {"rules": [...]}
+func Load(r io.Reader) ([]Rule, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	rules := []Rule{}
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		var file struct {
+			Rules []Rule `json:"rules"`
+		}
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return nil, err
+		}
+		rules = file.Rules
+	}
+
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// LoadFile will read the rules of a file
+func LoadFile(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Decision represents the evaluation of a torrent by a rule
+type Decision struct {
+	Rule    string
+	Torrent strikeapi.Torrent
+	// Accepted tells whether the torrent passes the rule
+	Accepted bool
+	// Selected tells whether it is the best accepted torrent of its Group
+	Selected bool
+	Group    string
+	Score    int
+	// Reasons explain the decision, the checks in order
+	Reasons []string
+	// Sent tells whether the torrent was given to the sink, Err holds the
+	// error of the sink
+	Sent bool
+	Err  error
+}
+
+// String will explain the decision
+func (d *Decision) String() string {
+	verdict := "rejected"
+	switch {
+	case d.Selected:
+		verdict = "selected"
+	case d.Accepted:
+		verdict = "accepted"
+	}
+	return fmt.Sprintf("%s %s by %s (%s, score %d) : %s", d.Torrent.Title, verdict, d.Rule, d.Group, d.Score, strings.Join(d.Reasons, ", "))
+}
+
+// Engine evaluates the rules and sends the selected torrents to the sinks
+type Engine struct {
+	Rules []Rule
+	// Sink receives the torrents of the rules without sink
+	Sink  strikeapi.Sink
+	Sinks map[string]strikeapi.Sink
+	// DryRun only evaluates the rules, nothing is sent
+	DryRun bool
+
+	// grabbed are the groups already sent by each rule
+	mu      sync.Mutex
+	grabbed map[string]bool
+}
+
+// New will create an Engine, checking the rules
+func New(rules []Rule, sink strikeapi.Sink) (*Engine, error) {
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, err
+		}
+	}
+	return &Engine{Rules: rules, Sink: sink, Sinks: map[string]strikeapi.Sink{}, grabbed: map[string]bool{}}, nil
+}
+
+// Evaluate will decide for each rule and torrent, the decisions are sorted
+// by rule, group and score. The search, top and watcher results have no file
+// list, they are loaded with strikeapi.GetTorrentsInfos for the rules with
+// MaxRisk.
+func (e *Engine) Evaluate(torrents []strikeapi.Torrent) []Decision {
+	for i := range e.Rules {
+		if e.Rules[i].MaxRisk != nil {
+			torrents = withFileLists(torrents)
+			break
+		}
+	}
+
+	decisions := []Decision{}
+	for i := range e.Rules {
+		r := &e.Rules[i]
+		ruleDecisions := []Decision{}
+		for j := range torrents {
+			ruleDecisions = append(ruleDecisions, r.evaluate(&torrents[j]))
+		}
+		selectBest(ruleDecisions)
+		decisions = append(decisions, ruleDecisions...)
+	}
+	return decisions
+}
+
+// withFileLists will load the missing file lists of the torrents in one
+// request, the torrents are left as is when it fails
+func withFileLists(torrents []strikeapi.Torrent) []strikeapi.Torrent {
+	hashes := []string{}
+	for i := range torrents {
+		if !hasFileList(&torrents[i]) {
+			hashes = append(hashes, torrents[i].Hash)
+		}
+	}
+	if len(hashes) == 0 {
+		return torrents
+	}
+	infos, err := strikeapi.GetTorrentsInfos(hashes)
+	if err != nil {
+		log.Println("Couldn't load the file lists", err)
+		return torrents
+	}
+
+	files := map[string]*strikeapi.FilesInfo{}
+	for _, t := range infos {
+		files[strings.ToUpper(t.Hash)] = t.FilesInfo
+	}
+	loaded := append([]strikeapi.Torrent{}, torrents...)
+	for i := range loaded {
+		if !hasFileList(&loaded[i]) {
+			loaded[i].FilesInfo = files[strings.ToUpper(loaded[i].Hash)]
+		}
+	}
+	return loaded
+}
+
+func hasFileList(t *strikeapi.Torrent) bool {
+	return t.FilesInfo != nil && len(t.FilesInfo.FileInfo) > 0
+}
+
+// Grab will evaluate the torrents and send the selected ones to the sinks,
+// once per group, unless DryRun is set
+func (e *Engine) Grab(torrents []strikeapi.Torrent) ([]Decision, error) {
+	decisions := e.Evaluate(torrents)
+	if e.DryRun {
+		return decisions, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.grabbed == nil {
+		e.grabbed = map[string]bool{}
+	}
+
+	errs := []error{}
+	for i := range decisions {
+		d := &decisions[i]
+		key := d.Rule + "\x00" + d.Group
+		if !d.Selected {
+			continue
+		}
+		if e.grabbed[key] {
+			d.Reasons = append(d.Reasons, "already grabbed")
+			continue
+		}
+
+		sink := e.Sink
+		if name := e.rule(d.Rule).Sink; name != "" {
+			sink = e.Sinks[name]
+		}
+		if sink == nil {
+			d.Err = ErrNoSink
+			errs = append(errs, fmt.Errorf("rule %s : %s", d.Rule, ErrNoSink))
+			continue
+		}
+
+		d.Err = sink.Send(&d.Torrent)
+		if d.Err != nil && d.Err != strikeapi.ErrDuplicate {
+			errs = append(errs, fmt.Errorf("rule %s : %s : %s", d.Rule, d.Torrent.Title, d.Err))
+			continue
+		}
+		d.Sent = d.Err == nil
+		e.grabbed[key] = true
+	}
+	return decisions, errors.Join(errs...)
+}
+
+// Emit returns a function grabbing the matches of a watcher, the first
+// accepted torrent of each group is grabbed and the errors are logged
+func (e *Engine) Emit() func(watch.Match) {
+	return func(m watch.Match) {
+		if _, err := e.Grab([]strikeapi.Torrent{m.Torrent}); err != nil {
+			log.Println("Couldn't grab", err)
+		}
+	}
+}
+
+func (e *Engine) rule(name string) *Rule {
+	for i := range e.Rules {
+		if e.Rules[i].Name == name {
+			return &e.Rules[i]
+		}
+	}
+	return &Rule{}
+}
+
+// evaluate will check a torrent against the rule
+func (r *Rule) evaluate(t *strikeapi.Torrent) Decision {
+	d := Decision{Rule: r.Name, Torrent: *t, Accepted: true, Group: r.group(t.Title)}
+	check := func(ok bool, accepted, rejected string, args ...interface{}) {
+		if !d.Accepted {
+			return
+		}
+		if ok {
+			d.Reasons = append(d.Reasons, fmt.Sprintf(accepted, args...))
+			return
+		}
+		d.Accepted = false
+		d.Reasons = append(d.Reasons, fmt.Sprintf(rejected, args...))
+	}
+
+	if r.Category != "" {
+		check(strings.EqualFold(t.Category, r.Category), "category %[1]s", "category %s isn't %s", t.Category, r.Category)
+	}
+	if r.SubCategory != "" {
+		check(strings.EqualFold(t.SubCategory, r.SubCategory), "subcategory %[1]s", "subcategory %s isn't %s", t.SubCategory, r.SubCategory)
+	}
+	if r.title != nil {
+		check(r.title.MatchString(t.Title), "title matches /%s/", "title doesn't match /%s/", r.Title)
+	}
+	if r.exclude != nil {
+		check(!r.exclude.MatchString(t.Title), "title doesn't match /%s/", "title matches the excluded /%s/", r.Exclude)
+	}
+	size := Size(t.Size)
+	if r.MinSize > 0 {
+		check(size >= r.MinSize, "size %s at least %s", "size %s below %s", size, r.MinSize)
+	}
+	if r.MaxSize > 0 {
+		check(size <= r.MaxSize, "size %s at most %s", "size %s above %s", size, r.MaxSize)
+	}
+	if r.MinSeeds > 0 {
+		check(t.Seeds >= r.MinSeeds, "%d seeds, at least %d", "%d seeds, less than %d", t.Seeds, r.MinSeeds)
+	}
+	if len(r.UploaderDenylist) > 0 {
+		denied := false
+		for _, uploader := range r.UploaderDenylist {
+			denied = denied || strings.EqualFold(uploader, t.UploaderUsername)
+		}
+		check(!denied, "uploader %s allowed", "uploader %s denied", t.UploaderUsername)
+	}
+
+	if r.MaxRisk != nil {
+		if hasFileList(t) {
+			report := safety.Inspect(t)
+			check(report.Severity <= *r.MaxRisk, "risk %[1]s", "risk %s above %s (%s)", report.Severity, *r.MaxRisk, report.String())
+		} else {
+			// The risk of a torrent without file list is unknown
+			check(false, "", "no file list to check the risk")
+		}
+	}
+	if d.Accepted && r.Quality != nil {
+		score := r.Quality.Score(t)
+		d.Reasons = append(d.Reasons, score.Reasons...)
+		d.Accepted = score.Allowed
+		d.Score += score.Total
+	}
+
+	if !d.Accepted {
+		return d
+	}
+	for i, re := range r.prefer {
+		if re.MatchString(t.Title) {
+			points := len(r.prefer) - i
+			d.Score += points
+			d.Reasons = append(d.Reasons, fmt.Sprintf("prefers /%s/ (+%d)", r.Prefer[i], points))
+		}
+	}
+	return d
+}
+
+// selectBest will mark the best accepted decision of each group, the score
+// then the seeds decide
+func selectBest(decisions []Decision) {
+	best := map[string]int{}
+	for i := range decisions {
+		d := &decisions[i]
+		if !d.Accepted {
+			continue
+		}
+		j, ok := best[d.Group]
+		if !ok || better(d, &decisions[j]) {
+			best[d.Group] = i
+		}
+	}
+	for _, i := range best {
+		decisions[i].Selected = true
+	}
+	for i := range decisions {
+		d := &decisions[i]
+		if d.Accepted && !d.Selected {
+			d.Reasons = append(d.Reasons, fmt.Sprintf("%s is better", decisions[best[d.Group]].Torrent.Title))
+		}
+	}
+
+	sort.SliceStable(decisions, func(i, j int) bool {
+		if decisions[i].Group != decisions[j].Group {
+			return decisions[i].Group < decisions[j].Group
+		}
+		return better(&decisions[i], &decisions[j])
+	})
+}
+
+func better(a, b *Decision) bool {
+	if a.Accepted != b.Accepted {
+		return a.Accepted
+	}
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.Torrent.Seeds > b.Torrent.Seeds
+}
+
+// qualityRe matches the words describing a copy rather than the release
+var qualityRe = regexp.MustCompile(`(?i)\b(2160p|1080p|720p|480p|4k|uhd|hdr|web-?dl|web-?rip|web|bluray|blu-ray|brrip|bdrip|dvdrip|hdtv|x264|x265|h\.?264|h\.?265|hevc|xvid|aac|ac3|dts|proper|repack)\b`)
+
+// group will return the episode or release of a title, the copies of an
+// episode are grouped whatever the year in the title
+func (r *Rule) group(title string) string {
+	if r.GroupBy == GroupRelease {
+		// The release is the title without the quality words and group
+		if i := strings.LastIndex(title, "-"); i > 0 && !strings.ContainsAny(title[i:], " .") {
+			title = title[:i]
+		}
+		return normalize(qualityRe.ReplaceAllString(title, " "))
+	}
+	release := strikeapi.ParseRelease(title)
+	key := []string{normalize(release.Title)}
+	if release.Year > 0 && !release.IsEpisode() {
+		key = append(key, strconv.Itoa(release.Year))
+	}
+	if release.IsEpisode() {
+		key = append(key, episode(&release))
+	}
+	if release.Version != "" {
+		key = append(key, release.Version)
+	}
+	if release.Arch != "" {
+		key = append(key, release.Arch)
+	}
+	return strings.Join(key, " ")
+}
+
+// episode will return the label of an episode, a range or a season pack
+func episode(release *strikeapi.Release) string {
+	label := ""
+	if release.Season > 0 || release.Episode > 0 && release.Special {
+		label = fmt.Sprintf("S%02d", release.Season)
+	}
+	if release.SeasonEnd > 0 {
+		label += fmt.Sprintf("-S%02d", release.SeasonEnd)
+	}
+	if release.Episode > 0 {
+		label += fmt.Sprintf("E%02d", release.Episode)
+	}
+	if release.EpisodeEnd > 0 {
+		label += fmt.Sprintf("-E%02d", release.EpisodeEnd)
+	}
+	if label == "" {
+		label = "special"
+	}
+	return label
+}
+
+// normalize will lower the words of a title and drop the punctuation
+func normalize(title string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
@@ -0,0 +1,329 @@
+package rules
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/strikeapitest"
+	"github.com/PouuleT/go-strikeapi/watch"
+)
+
+const testRules = `{"rules": [{
+	"name": "tv",
+	"category": "TV",
+	"title": "S\\d+E\\d+",
+	"min_size": "200MB",
+	"max_size": "2GB",
+	"min_seeds": 20,
+	"uploader_denylist": ["Spammer"],
+	"prefer": ["1080p", "720p"]
+}]}`
+
+const gib = 1 << 30
+
+var testTorrents = []strikeapi.Torrent{
+	{Title: "Show.Name.S01E02.720p.HDTV.x264-GRP", Hash: "A1", Category: "TV", Size: 0.8 * gib, Seeds: 300, UploaderUsername: "uploader"},
+	{Title: "Show.Name.S01E02.1080p.WEB-DL.x264-GRP", Hash: "A2", Category: "TV", Size: 1.5 * gib, Seeds: 50, UploaderUsername: "uploader"},
+	{Title: "Show Name S01E02 1080p HDTV", Hash: "A3", Category: "TV", Size: 1.4 * gib, Seeds: 40, UploaderUsername: "uploader"},
+	{Title: "Show.Name.S01E02.2160p.WEB-DL", Hash: "A4", Category: "TV", Size: 6 * gib, Seeds: 500, UploaderUsername: "uploader"},
+	{Title: "Show.Name.S01E03.480p.HDTV", Hash: "A5", Category: "TV", Size: 0.3 * gib, Seeds: 25, UploaderUsername: "uploader"},
+	{Title: "Show.Name.S01E03.1080p.HDTV", Hash: "A6", Category: "TV", Size: 1.2 * gib, Seeds: 5, UploaderUsername: "uploader"},
+	{Title: "Show.Name.S01E04.1080p.HDTV", Hash: "A7", Category: "TV", Size: 1.2 * gib, Seeds: 90, UploaderUsername: "spammer"},
+	{Title: "Show Name Season 1 1080p", Hash: "A8", Category: "TV", Size: 1.2 * gib, Seeds: 90, UploaderUsername: "uploader"},
+	{Title: "Movie.2015.1080p.BluRay", Hash: "A9", Category: "Movies", Size: 1.2 * gib, Seeds: 90, UploaderUsername: "uploader"},
+}
+
+// sink records the torrents sent
+type sink struct {
+	sent []string
+	err  error
+}
+
+func (s *sink) Send(t *strikeapi.Torrent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, t.Hash)
+	return nil
+}
+
+func newEngine(t *testing.T, s strikeapi.Sink) *Engine {
+	rules, err := Load(strings.NewReader(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(rules, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Size
+	}{
+		{"200MB", 200 << 20},
+		{"2GB", 2 << 30},
+		{"1.5 GiB", 1.5 * gib},
+		{"512", 512},
+		{"10k", 10 << 10},
+	}
+	for _, test := range tests {
+		size, err := ParseSize(test.input)
+		if err != nil {
+			t.Errorf("Couldn't parse %q : %s", test.input, err)
+			continue
+		}
+		if size != test.expected {
+			t.Errorf("%q should be %v, got %v", test.input, test.expected, size)
+		}
+	}
+
+	for _, input := range []string{"", "MB", "12 parsecs"} {
+		if _, err := ParseSize(input); err == nil {
+			t.Errorf("%q shouldn't parse", input)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	rules, err := Load(strings.NewReader(`[{"name": "linux", "min_size": 1024, "max_size": "4 GiB", "group_by": "release"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Name != "linux" || rules[0].MinSize != 1024 || rules[0].MaxSize != 4*gib || rules[0].GroupBy != GroupRelease {
+		t.Errorf("Bad rules %+v", rules)
+	}
+
+	invalid := []string{
+		`[{"title": "x"}]`,
+		`[{"name": "bad", "title": "("}]`,
+		`[{"name": "bad", "prefer": ["1080p", "["]}]`,
+		`[{"name": "bad", "group_by": "season"}]`,
+		`[{"name": "bad", "min_size": "2GB", "max_size": "1GB"}]`,
+		`[{"name": "bad", "min_size": "huge"}]`,
+		`{"rules": 3}`,
+	}
+	for _, input := range invalid {
+		if _, err := Load(strings.NewReader(input)); err == nil {
+			t.Errorf("%s should be invalid", input)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	e := newEngine(t, nil)
+	decisions := e.Evaluate(testTorrents)
+	if len(decisions) != len(testTorrents) {
+		t.Fatalf("Should have %d decisions, got %d", len(testTorrents), len(decisions))
+	}
+
+	expected := map[string]struct {
+		accepted, selected bool
+		group, reason      string
+	}{
+		"A1": {true, false, "show name S01E02", "Show.Name.S01E02.1080p.WEB-DL.x264-GRP is better"},
+		"A2": {true, true, "show name S01E02", "prefers /1080p/ (+2)"},
+		"A3": {true, false, "show name S01E02", "Show.Name.S01E02.1080p.WEB-DL.x264-GRP is better"},
+		"A4": {false, false, "show name S01E02", "size 6.00 GiB above 2.00 GiB"},
+		"A5": {true, true, "show name S01E03", "25 seeds, at least 20"},
+		"A6": {false, false, "show name S01E03", "5 seeds, less than 20"},
+		"A7": {false, false, "show name S01E04", "uploader spammer denied"},
+		"A8": {false, false, "show name S01", `title doesn't match /S\d+E\d+/`},
+		"A9": {false, false, "movie 2015", "category Movies isn't TV"},
+	}
+	for _, d := range decisions {
+		exp := expected[d.Torrent.Hash]
+		if d.Accepted != exp.accepted || d.Selected != exp.selected || d.Group != exp.group {
+			t.Errorf("Bad decision %s", d.String())
+		}
+		if !strings.Contains(strings.Join(d.Reasons, "\n"), exp.reason) {
+			t.Errorf("%s should be explained by %q, got %q", d.Torrent.Hash, exp.reason, d.Reasons)
+		}
+	}
+
+	// Sorted by group then from the best
+	order := []string{}
+	for _, d := range decisions {
+		order = append(order, d.Torrent.Hash)
+	}
+	if strings.Join(order, ",") != "A9,A8,A2,A3,A1,A4,A5,A6,A7" {
+		t.Errorf("Bad order %v", order)
+	}
+}
+
+func TestGrab(t *testing.T) {
+	s := &sink{}
+	e := newEngine(t, s)
+
+	e.DryRun = true
+	if _, err := e.Grab(testTorrents); err != nil || len(s.sent) != 0 {
+		t.Fatalf("The dry run shouldn't send anything, got %v %v", s.sent, err)
+	}
+
+	e.DryRun = false
+	decisions, err := e.Grab(testTorrents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(s.sent, ",") != "A2,A5" {
+		t.Errorf("Should send the best of each episode, got %v", s.sent)
+	}
+	for _, d := range decisions {
+		if d.Sent != d.Selected {
+			t.Errorf("Bad decision %s", d.String())
+		}
+	}
+
+	// The episodes are grabbed once
+	if _, err := e.Grab(testTorrents); err != nil || len(s.sent) != 2 {
+		t.Errorf("Shouldn't grab twice, got %v %v", s.sent, err)
+	}
+
+	// A duplicate counts as grabbed, other errors are returned
+	dup := &sink{err: strikeapi.ErrDuplicate}
+	if _, err := newEngine(t, dup).Grab(testTorrents); err != nil {
+		t.Errorf("Duplicates aren't errors, got %s", err)
+	}
+	broken := &sink{err: errors.New("down")}
+	if _, err := newEngine(t, broken).Grab(testTorrents); err == nil || !strings.Contains(err.Error(), "down") {
+		t.Errorf("Should fail, got %v", err)
+	}
+	if _, err := newEngine(t, nil).Grab(testTorrents); err == nil || !strings.Contains(err.Error(), ErrNoSink.Error()) {
+		t.Errorf("Should fail without sink, got %v", err)
+	}
+}
+
+func TestNamedSinksAndEmit(t *testing.T) {
+	rules := []Rule{{Name: "linux", Title: "slackware", MinSeeds: 10, Sink: "seedbox", Prefer: []string{"x86_64"}}}
+	e, err := New(rules, &sink{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedbox := &sink{}
+	e.Sinks["seedbox"] = seedbox
+
+	emit := e.Emit()
+	emit(watch.Match{Watch: "linux", Torrent: strikeapi.Torrent{Title: "Slackware 14.1 x86_64 DVD ISO", Hash: "B1", Seeds: 192}})
+	emit(watch.Match{Watch: "linux", Torrent: strikeapi.Torrent{Title: "Slackware 14.1 x86_64 DVD ISO", Hash: "B2", Seeds: 500}})
+	emit(watch.Match{Watch: "linux", Torrent: strikeapi.Torrent{Title: "Slackware 14.2 x86_64 DVD ISO", Hash: "B3", Seeds: 50}})
+	if strings.Join(seedbox.sent, ",") != "B1,B3" {
+		t.Errorf("Should grab each release once, got %v", seedbox.sent)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	episode, release := &Rule{}, &Rule{GroupBy: GroupRelease}
+	tests := []struct {
+		rule     *Rule
+		title    string
+		expected string
+	}{
+		{episode, "Show.Name.s1e2.720p", "show name S01E02"},
+		{episode, "Show Name - S01E02 - Title [1080p]", "show name S01E02"},
+		{episode, "Show.Name.2015.S01E02.1080p", "show name S01E02"},
+		{release, "Show.Name.S01E02.720p", "show name s01e02"},
+		{episode, "Show.Name.S01E02E03.720p", "show name S01E02-E03"},
+		{episode, "Show.Name.S01-S03.COMPLETE", "show name S01-S03"},
+		{episode, "Show.Name.S00E04.720p", "show name S00E04"},
+		{episode, "[Group] Anime - 07 [720p]", "anime E07"},
+		{episode, "Movie.2015.1080p.BluRay.x264-GRP", "movie 2015"},
+		{episode, "Movie 2015 720p WEB-DL", "movie 2015"},
+		{episode, "Movie.2015.PROPER.2160p.REMUX-GRP", "movie 2015"},
+		{episode, "Slackware 14.1 x86_64 DVD ISO", "slackware 14.1 x86_64"},
+	}
+	for _, test := range tests {
+		if group := test.rule.group(test.title); group != test.expected {
+			t.Errorf("%q should be in %q, got %q", test.title, test.expected, group)
+		}
+	}
+}
+
+func TestQuality(t *testing.T) {
+	rules, err := Load(strings.NewReader(`[{"name": "tv", "quality": {"resolutions": ["1080p", "720p"], "preferred_sources": ["WEB-DL", "HDTV"]}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(rules, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	selected := map[string]string{}
+	for _, d := range e.Evaluate(testTorrents) {
+		if d.Selected {
+			selected[d.Group] = d.Torrent.Hash
+		}
+		if d.Torrent.Hash == "A4" && (d.Accepted || d.Reasons[0] != "resolution 2160p not allowed") {
+			t.Errorf("Bad decision %s", d.String())
+		}
+	}
+	expected := map[string]string{
+		"show name S01E02": "A2",
+		"show name S01E03": "A6",
+		"show name S01E04": "A7",
+		"show name S01":    "A8",
+		"movie 2015":       "A9",
+	}
+	if !reflect.DeepEqual(selected, expected) {
+		t.Errorf("Bad selection %v", selected)
+	}
+}
+
+func TestMaxRisk(t *testing.T) {
+	rules, err := Load(strings.NewReader(`[{"name": "safe", "max_risk": "low"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(strings.NewReader(`[{"name": "safe", "max_risk": "critical"}]`)); err == nil {
+		t.Error("Should fail to load an unknown risk")
+	}
+	e, err := New(rules, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The search results have no file list, they're loaded from the API
+	server := strikeapitest.NewServer(strikeapi.Torrent{Title: "Movie.2015.720p.BluRay", Hash: "S2", Category: strikeapi.Movies, Seeds: 10,
+		FilesInfo: &strikeapi.FilesInfo{FileInfo: []strikeapi.FileInfo{{FileName: "movie.mkv", FileSize: 1 << 30}}},
+	})
+	defer server.Close()
+	defer server.Use()()
+
+	torrents := []strikeapi.Torrent{
+		{Title: "Movie.2015.1080p.BluRay", Hash: "S1", Category: strikeapi.Movies, Seeds: 100, FilesInfo: &strikeapi.FilesInfo{
+			FileInfo: []strikeapi.FileInfo{{FileName: "movie.mkv.exe", FileSize: 1 << 20}},
+		}},
+		{Title: "Movie.2015.720p.BluRay", Hash: "S2", Category: strikeapi.Movies, Seeds: 10},
+		{Title: "Movie.2015.480p.BluRay", Hash: "S3", Category: strikeapi.Movies, Seeds: 5},
+	}
+	decisions := e.Evaluate(torrents)
+	if len(decisions) != 3 {
+		t.Fatalf("Bad decisions %v", decisions)
+	}
+	for _, d := range decisions {
+		switch d.Torrent.Hash {
+		case "S1":
+			if d.Accepted || !strings.HasPrefix(d.Reasons[0], "risk high above low (high: executable movie.mkv.exe") {
+				t.Errorf("Bad decision %s", d.String())
+			}
+		case "S2":
+			if !d.Selected || d.Reasons[0] != "risk none" || d.Torrent.FilesInfo == nil {
+				t.Errorf("Bad decision %s", d.String())
+			}
+		case "S3":
+			if d.Accepted || d.Reasons[0] != "no file list to check the risk" {
+				t.Errorf("Bad decision %s", d.String())
+			}
+		}
+	}
+	// Only the missing file lists are requested, in one request
+	if server.Requests(strikeapitest.PathInfo) != 1 {
+		t.Errorf("Bad info requests %d", server.Requests(strikeapitest.PathInfo))
+	}
+}
@@ -0,0 +1,84 @@
+package strikeapi
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrUnsafeCategory is returned by the searches of the adult categories in
+// SafeMode
+var ErrUnsafeCategory = errors.New("adult categories are disabled in safe mode")
+
+// SafeMode drops the adult torrents from every result and rejects the
+// searches of the adult categories with ErrUnsafeCategory
+var SafeMode bool
+
+// SafeKeywords drops the torrents whose titles hold one of the words or
+// phrases in SafeMode, e.g. "nsfw"
+var SafeKeywords []string
+
+// adultSubCategories are the subcategories only holding adult content, the
+// others like Video are shared with other categories
+var adultSubCategories = []string{Hentai, OtherXXX}
+
+// checkSafeCategory will reject the adult categories in SafeMode
+func checkSafeCategory(category, subCategory string) error {
+	if SafeMode && adultCategory(category, subCategory) {
+		return ErrUnsafeCategory
+	}
+	return nil
+}
+
+// adultCategory will tell whether a category or a subcategory only holds
+// adult content
+func adultCategory(category, subCategory string) bool {
+	if strings.EqualFold(category, XXX) {
+		return true
+	}
+	for _, s := range adultSubCategories {
+		if strings.EqualFold(subCategory, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdult will tell whether a torrent is hidden by SafeMode, from its
+// category, its subcategory and the SafeKeywords
+func (t *Torrent) IsAdult() bool {
+	if adultCategory(t.Category, t.SubCategory) {
+		return true
+	}
+
+	title := " " + words(t.Title) + " "
+	for _, keyword := range SafeKeywords {
+		keyword = words(keyword)
+		if keyword != "" && strings.Contains(title, " "+keyword+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// safeTorrents will drop the adult torrents in SafeMode
+func safeTorrents(torrents []Torrent) []Torrent {
+	if !SafeMode {
+		return torrents
+	}
+	safe := []Torrent{}
+	for i := range torrents {
+		if !torrents[i].IsAdult() {
+			safe = append(safe, torrents[i])
+		}
+	}
+	return safe
+}
+
+// words will lower the words of a text and separate them by a space, the
+// keywords only match whole words
+func words(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
@@ -0,0 +1,95 @@
+package strikeapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const adultResponse = `{"results":4,"statuscode":200,"torrents":[` +
+	`{"torrent_hash":"S1","torrent_title":"Arch Linux","torrent_category":"Applications","sub_category":"Linux"},` +
+	`{"torrent_hash":"S2","torrent_title":"Adult video","torrent_category":"XXX","sub_category":"Video"},` +
+	`{"torrent_hash":"S3","torrent_title":"Some anime","torrent_category":"Anime","sub_category":"Hentai"},` +
+	`{"torrent_hash":"S4","torrent_title":"Movie.2015.NSFW.Cut","torrent_category":"Movies","sub_category":"Other XXX"},` +
+	`{"torrent_hash":"S5","torrent_title":"Movie.2015.NSFW.Cut","torrent_category":"Movies"},` +
+	`{"torrent_hash":"S6","torrent_title":"Sussex Gardens","torrent_category":"TV"}]}`
+
+func hashes(torrents []Torrent) string {
+	h := ""
+	for _, t := range torrents {
+		h += t.Hash
+	}
+	return h
+}
+
+func TestSafeMode(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintln(w, adultResponse)
+	}))
+	defer ts.Close()
+	APIEndpoint = ts.URL
+
+	// Everything is shown by default
+	torrents, err := Search("anything")
+	if err != nil || hashes(torrents) != "S1S2S3S4S5S6" {
+		t.Errorf("Bad results without safe mode : %s %v", hashes(torrents), err)
+	}
+
+	SafeMode, SafeKeywords = true, []string{"nsfw", "sex"}
+	defer func() { SafeMode, SafeKeywords = false, nil }()
+
+	if torrents, err = Search("anything"); err != nil || hashes(torrents) != "S1S6" {
+		t.Errorf("Bad search results : %s %v", hashes(torrents), err)
+	}
+	if torrents, err = GetTopTorrents(""); err != nil || hashes(torrents) != "S1S6" {
+		t.Errorf("Bad top torrents : %s %v", hashes(torrents), err)
+	}
+	if torrents, err = GetTorrentsInfos([]string{"S1", "S2"}); err != nil || hashes(torrents) != "S1S6" {
+		t.Errorf("Bad torrent infos : %s %v", hashes(torrents), err)
+	}
+
+	requests = 0
+	for _, search := range []func() ([]Torrent, error){
+		func() ([]Torrent, error) { return SearchWithCategory("anything", "xxx") },
+		func() ([]Torrent, error) { return SearchWithCategoryAndSubCategory("anything", Anime, Hentai) },
+		func() ([]Torrent, error) { return SearchQuery{Phrase: "anything", SubCategory: OtherXXX}.Search() },
+		func() ([]Torrent, error) { return GetTopTorrents(XXX) },
+	} {
+		if _, err := search(); err != ErrUnsafeCategory {
+			t.Errorf("Should get an ErrUnsafeCategory, got %v", err)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("The adult searches shouldn't reach the API, got %d requests", requests)
+	}
+
+	// The local store is filtered too
+	LocalStore = &memoryStore{torrents: []Torrent{{Title: "Adult", Hash: "L1", Category: XXX}, {Title: "Adults", Hash: "L2"}}}
+	defer func() { LocalStore = nil }()
+	if torrents, err = SearchLocal("Adult"); err != nil || hashes(torrents) != "L2" {
+		t.Errorf("Bad local results : %s %v", hashes(torrents), err)
+	}
+}
+
+func TestIsAdult(t *testing.T) {
+	SafeKeywords = []string{"Adult Only"}
+	defer func() { SafeKeywords = nil }()
+
+	tests := map[Torrent]bool{
+		{Category: "xxx"}:                                 true,
+		{Category: Movies, SubCategory: "hentai"}:         true,
+		{Category: Movies, SubCategory: Video}:            false,
+		{Title: "Movie (adult-only) 2015"}:                true,
+		{Title: "Movie adult 2015"}:                       false,
+		{Title: "Adult.Only.Movie", Category: Movies}:     true,
+		{Title: "Slackware 14.1", Category: Applications}: false,
+	}
+	for torrent, expected := range tests {
+		if torrent.IsAdult() != expected {
+			t.Errorf("%+v should be adult : %v", torrent, expected)
+		}
+	}
+}
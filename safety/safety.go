@@ -0,0 +1,226 @@
+// Package safety inspects the file lists of the torrents for the patterns
+// of fake releases and malware, like a "movie" holding an executable:
+//
+//	report := safety.Inspect(&torrent)
+//	if report.Severity >= safety.High {
+//		fmt.Println("Risky torrent :", report.String())
+//	}
+package safety
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strings"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Severity represents how risky a Finding is
+type Severity int
+
+// Severities, from the harmless to the dangerous
+const (
+	None Severity = iota
+	Info
+	Low
+	Medium
+	High
+)
+
+var severityNames = []string{"none", "info", "low", "medium", "high"}
+
+func (s Severity) String() string {
+	if s < None || s > High {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity will parse the name of a severity
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(n, name) {
+			return Severity(i), nil
+		}
+	}
+	return None, fmt.Errorf("unknown severity %q", name)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *Severity) UnmarshalText(text []byte) error {
+	severity, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
+// Names of the checks
+const (
+	CheckFileList        = "file-list"
+	CheckExecutable      = "executable"
+	CheckArchive         = "archive"
+	CheckDoubleExtension = "double-extension"
+	CheckPassword        = "password"
+	CheckPadding         = "padding"
+	CheckSizeMismatch    = "size-mismatch"
+)
+
+// Thresholds of the checks
+var (
+	// MinHDVideoSize is the size of the smallest video of an HD release
+	MinHDVideoSize = 100.0 * (1 << 20)
+	// SizeTolerance is the relative difference allowed between the size of
+	// the torrent and the sum of its files
+	SizeTolerance = 0.01
+)
+
+// File extensions, without the dot
+var (
+	executableExtensions = set("exe", "scr", "com", "bat", "cmd", "pif", "msi", "vbs", "vbe", "js", "jse", "wsf", "jar", "ps1", "lnk", "hta", "cpl", "apk")
+	archiveExtensions    = set("zip", "rar", "7z", "tar", "gz", "bz2", "xz", "cab", "r00", "r01")
+	videoExtensions      = set("mkv", "mp4", "avi", "m4v", "mov", "wmv", "mpg", "mpeg", "ts", "m2ts", "webm", "flv", "vob")
+	mediaExtensions      = set("mkv", "mp4", "avi", "m4v", "mov", "wmv", "mpg", "mpeg", "ts", "m2ts", "webm", "flv", "vob",
+		"mp3", "flac", "m4a", "ogg", "wav", "pdf", "epub", "mobi", "jpg", "png", "srt", "txt", "nfo", "doc", "docx")
+)
+
+// passwordHints are the words of the file names asking for a password,
+// usually sold on a website
+var passwordHints = []string{"password", "passwd", "pass.txt", "unlock", "get key", "keygen for"}
+
+// Categories holding media, where executables are suspicious, and videos,
+// where archives are suspicious too
+var (
+	mediaCategories = set(strings.ToLower(strikeapi.Anime), strings.ToLower(strikeapi.Movies), strings.ToLower(strikeapi.TV),
+		strings.ToLower(strikeapi.Music), strings.ToLower(strikeapi.Books), strings.ToLower(strikeapi.XXX))
+	videoCategories = set(strings.ToLower(strikeapi.Anime), strings.ToLower(strikeapi.Movies), strings.ToLower(strikeapi.TV),
+		strings.ToLower(strikeapi.XXX))
+)
+
+func set(values ...string) map[string]bool {
+	s := map[string]bool{}
+	for _, v := range values {
+		s[v] = true
+	}
+	return s
+}
+
+// Finding represents a suspicious pattern
+type Finding struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	// File is the file the finding is about, empty for the whole torrent
+	File    string `json:"file,omitempty"`
+	Message string `json:"message"`
+}
+
+// Report represents the findings of a torrent
+type Report struct {
+	Hash     string    `json:"hash"`
+	Findings []Finding `json:"findings"`
+	// Severity is the highest severity of the findings
+	Severity Severity `json:"severity"`
+}
+
+// String will summarize the report
+func (r *Report) String() string {
+	messages := []string{}
+	for _, f := range r.Findings {
+		messages = append(messages, fmt.Sprintf("%s: %s", f.Severity, f.Message))
+	}
+	if len(messages) == 0 {
+		return "no findings"
+	}
+	return strings.Join(messages, ", ")
+}
+
+func (r *Report) add(check string, severity Severity, file, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, Finding{Check: check, Severity: severity, File: file, Message: fmt.Sprintf(format, args...)})
+	if severity > r.Severity {
+		r.Severity = severity
+	}
+}
+
+// Inspect will check the files of a torrent, the category is the one of the
+// torrent or, when empty, guessed from its title
+func Inspect(t *strikeapi.Torrent) Report {
+	r := Report{Hash: t.Hash, Findings: []Finding{}}
+	if t.FilesInfo == nil || len(t.FilesInfo.FileInfo) == 0 {
+		r.add(CheckFileList, Info, "", "no file list to inspect")
+		return r
+	}
+
+	release := t.Release()
+	category := strings.ToLower(t.Category)
+	video := videoCategories[category]
+	if category == "" {
+		video = release.Resolution != "" || release.Source != "" || release.Codec != ""
+	}
+	media := video || mediaCategories[category]
+
+	total, videoSize, largestVideo := 0.0, 0.0, 0.0
+	for _, f := range t.FilesInfo.FileInfo {
+		total += f.FileSize
+		name := path.Base(strings.Replace(f.FileName, "\\", "/", -1))
+		lower := strings.ToLower(name)
+		parts := strings.Split(lower, ".")
+		ext := ""
+		if len(parts) > 1 {
+			ext = parts[len(parts)-1]
+		}
+
+		if videoExtensions[ext] {
+			videoSize += f.FileSize
+			largestVideo = math.Max(largestVideo, f.FileSize)
+		}
+		if media && executableExtensions[ext] {
+			r.add(CheckExecutable, High, f.FileName, "executable %s in a %s torrent", name, categoryName(t.Category, video))
+		}
+		if video && archiveExtensions[ext] {
+			r.add(CheckArchive, Medium, f.FileName, "archive %s in a %s torrent", name, categoryName(t.Category, video))
+		}
+		if len(parts) > 2 && mediaExtensions[parts[len(parts)-2]] && (executableExtensions[ext] || archiveExtensions[ext]) {
+			r.add(CheckDoubleExtension, High, f.FileName, "%s hides a .%s behind a .%s", name, ext, parts[len(parts)-2])
+		}
+		for _, hint := range passwordHints {
+			if strings.Contains(lower, hint) {
+				r.add(CheckPassword, Medium, f.FileName, "%s hints at a password protected archive", name)
+				break
+			}
+		}
+	}
+
+	hd := release.Resolution != "" && release.Resolution != "480p" && release.Resolution != "576p"
+	if video && hd {
+		switch {
+		case videoSize == 0:
+			r.add(CheckPadding, High, "", "%s release without video", release.Resolution)
+		case largestVideo < MinHDVideoSize:
+			r.add(CheckPadding, Medium, "", "%s release whose largest video is %.0f MiB", release.Resolution, largestVideo/(1<<20))
+		case videoSize < total/2:
+			r.add(CheckPadding, Medium, "", "%s release whose videos are %.0f%% of the size", release.Resolution, videoSize/total*100)
+		}
+	}
+
+	if t.Size > 0 && math.Abs(t.Size-total) > SizeTolerance*t.Size {
+		r.add(CheckSizeMismatch, Medium, "", "the files sum to %.0f bytes instead of %.0f", total, t.Size)
+	}
+	return r
+}
+
+func categoryName(category string, video bool) string {
+	if category != "" {
+		return category
+	}
+	if video {
+		return "video"
+	}
+	return "media"
+}
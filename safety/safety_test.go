@@ -0,0 +1,132 @@
+package safety
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+const mib = 1 << 20
+
+func files(sizes map[string]float64) *strikeapi.FilesInfo {
+	info := &strikeapi.FilesInfo{}
+	for name, size := range sizes {
+		info.FileInfo = append(info.FileInfo, strikeapi.FileInfo{FileName: name, FileSize: size})
+	}
+	return info
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		torrent  strikeapi.Torrent
+		severity Severity
+		checks   []string
+	}{
+		{
+			strikeapi.Torrent{Title: "Movie.2015.1080p.BluRay.x264-GRP", Category: strikeapi.Movies, Size: 4000 * mib,
+				FilesInfo: files(map[string]float64{"Movie/movie.mkv": 3990 * mib, "Movie/movie.nfo": 10 * mib})},
+			None, nil,
+		},
+		{
+			strikeapi.Torrent{Title: "Movie 2015"},
+			Info, []string{CheckFileList},
+		},
+		{
+			strikeapi.Torrent{Title: "Movie.2015.1080p.BluRay", Category: strikeapi.Movies,
+				FilesInfo: files(map[string]float64{"Movie/movie.mp4.exe": 2 * mib})},
+			High, []string{CheckExecutable, CheckDoubleExtension, CheckPadding},
+		},
+		{
+			strikeapi.Torrent{Title: "Movie.2015.720p.BluRay", Category: strikeapi.Movies,
+				FilesInfo: files(map[string]float64{"movie.rar": 900 * mib, "Password.txt": 1 * mib})},
+			High, []string{CheckArchive, CheckPassword, CheckPadding},
+		},
+		{
+			strikeapi.Torrent{Title: "Show.S01E01.1080p.WEB-DL", Category: strikeapi.TV,
+				FilesInfo: files(map[string]float64{"show.s01e01.mkv": 50 * mib, "sample.mkv": 1 * mib})},
+			Medium, []string{CheckPadding},
+		},
+		{
+			strikeapi.Torrent{Title: "Show.S01E01.1080p.WEB-DL", Category: strikeapi.TV,
+				FilesInfo: files(map[string]float64{"show.s01e01.mkv": 500 * mib, "extras.iso": 800 * mib})},
+			Medium, []string{CheckPadding},
+		},
+		{
+			// The category is guessed from the title
+			strikeapi.Torrent{Title: "Movie.2015.1080p.WEB-DL",
+				FilesInfo: files(map[string]float64{"movie.zip": 2000 * mib})},
+			High, []string{CheckArchive, CheckPadding},
+		},
+		{
+			strikeapi.Torrent{Title: "Slackware 14.1 x86_64", Category: strikeapi.Applications, Size: 2000 * mib,
+				FilesInfo: files(map[string]float64{"setup.exe": 1 * mib, "slackware.zip": 1000 * mib})},
+			Medium, []string{CheckSizeMismatch},
+		},
+		{
+			strikeapi.Torrent{Title: "Album 2015", Category: strikeapi.Music,
+				FilesInfo: files(map[string]float64{"01.flac": 30 * mib, "cover.jpg.scr": 1 * mib})},
+			High, []string{CheckExecutable, CheckDoubleExtension},
+		},
+	}
+
+	for _, test := range tests {
+		report := Inspect(&test.torrent)
+		checks := map[string]bool{}
+		for _, f := range report.Findings {
+			checks[f.Check] = true
+		}
+		ok := report.Severity == test.severity && len(checks) == len(test.checks)
+		for _, c := range test.checks {
+			ok = ok && checks[c]
+		}
+		if !ok {
+			t.Errorf("Bad report of %s, expected %s %v : %s %s", test.torrent.Title, test.severity, test.checks, report.Severity, report.String())
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	torrent := strikeapi.Torrent{Title: "Movie.2015.720p.BluRay", Hash: "ABC", Category: strikeapi.Movies,
+		FilesInfo: files(map[string]float64{"dir\\movie.avi.exe": 500 * mib})}
+	report := Inspect(&torrent)
+	if report.String() != "high: executable movie.avi.exe in a Movies torrent, high: movie.avi.exe hides a .exe behind a .avi, high: 720p release without video" {
+		t.Errorf("Bad report %q", report.String())
+	}
+	if report.Findings[0].File != "dir\\movie.avi.exe" {
+		t.Errorf("Bad file %q", report.Findings[0].File)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"severity":"high"`) || !strings.HasPrefix(string(data), `{"hash":"ABC"`) {
+		t.Errorf("Bad JSON %s", data)
+	}
+	decoded := Report{}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Severity != High || decoded.Findings[1].Check != CheckDoubleExtension {
+		t.Errorf("Bad decoded report %+v : %v", decoded, err)
+	}
+
+	empty := Report{}
+	if empty.String() != "no findings" {
+		t.Errorf("Bad empty report %q", empty.String())
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, s := range []Severity{None, Info, Low, Medium, High} {
+		parsed, err := ParseSeverity(strings.ToUpper(s.String()))
+		if err != nil || parsed != s {
+			t.Errorf("Bad severity %s : %s %v", s, parsed, err)
+		}
+	}
+	if _, err := ParseSeverity("critical"); err == nil {
+		t.Error("Should fail to parse an unknown severity")
+	}
+	if Severity(9).String() != "severity(9)" {
+		t.Errorf("Bad unknown severity %s", Severity(9))
+	}
+}
@@ -0,0 +1,210 @@
+package strikeapi
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Schemas of the envelopes of the answers, see the schemas directory
+const (
+	// SchemaTorrents is the schema of the search, info and top answers
+	SchemaTorrents = "torrents"
+	// SchemaStatus is the schema of the descriptions and download answers
+	SchemaStatus = "status"
+	// SchemaCount is the schema of the count answer
+	SchemaCount = "count"
+)
+
+// ValidationMode tells what to do with the answers not matching their schema
+type ValidationMode int
+
+// Validation modes
+const (
+	// ValidateOff doesn't check the answers
+	ValidateOff ValidationMode = iota
+	// ValidateWarn logs the schema drift
+	ValidateWarn
+	// ValidateStrict fails with a SchemaError
+	ValidateStrict
+)
+
+// SchemaValidation checks the answers of the API against their schema
+var SchemaValidation = ValidateOff
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// schemas are the parsed schemas by name
+var schemas = loadSchemas()
+
+// SchemaError represents an answer not matching its schema
+type SchemaError struct {
+	Schema   string
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("answer not matching the %s schema : %s", e.Schema, strings.Join(e.Problems, ", "))
+}
+
+// Schema will return the JSON Schema document of an envelope
+func Schema(name string) ([]byte, error) {
+	return schemaFiles.ReadFile("schemas/" + name + ".json")
+}
+
+// ValidateSchema will check an answer against a schema, the differences are
+// given by a SchemaError. Only the type, properties, required, items and
+// additionalProperties keywords are supported.
+func ValidateSchema(name string, data []byte) error {
+	s, ok := schemas[name]
+	if !ok {
+		return fmt.Errorf("unknown schema %q", name)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	e := &SchemaError{Schema: name}
+	s.validate("$", value, e)
+	if len(e.Problems) > 0 {
+		return e
+	}
+	return nil
+}
+
+// checkSchema will validate an answer following the SchemaValidation
+func checkSchema(name string, data []byte) error {
+	if SchemaValidation == ValidateOff {
+		return nil
+	}
+	err := ValidateSchema(name, data)
+	if err != nil && SchemaValidation == ValidateWarn {
+		log.Println("Schema drift : ", err)
+		return nil
+	}
+	return err
+}
+
+// schema represents the supported subset of a JSON Schema
+type schema struct {
+	Type                 schemaTypes        `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+}
+
+// schemaTypes accepts a type or a list of types
+type schemaTypes []string
+
+// UnmarshalJSON is a custom unmarshal function to accept a single type
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+func loadSchemas() map[string]*schema {
+	parsed := map[string]*schema{}
+	for _, name := range []string{SchemaTorrents, SchemaStatus, SchemaCount} {
+		data, err := Schema(name)
+		if err != nil {
+			panic(err)
+		}
+		s := &schema{}
+		if err := json.Unmarshal(data, s); err != nil {
+			panic(fmt.Sprintf("schema %s : %s", name, err))
+		}
+		parsed[name] = s
+	}
+	return parsed
+}
+
+// validate will add the problems of a value to the error, path locates the
+// value in the answer
+func (s *schema) validate(path string, value interface{}, e *SchemaError) {
+	kind := typeOf(value)
+	if len(s.Type) > 0 && !s.allows(kind) {
+		e.Problems = append(e.Problems, fmt.Sprintf("%s is %s instead of %s", path, kind, strings.Join(s.Type, " or ")))
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := v[key]; !ok {
+				e.Problems = append(e.Problems, fmt.Sprintf("%s.%s is missing", path, key))
+			}
+		}
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, ok := s.Properties[key]
+			switch {
+			case ok:
+				property.validate(path+"."+key, v[key], e)
+			case s.AdditionalProperties != nil && !*s.AdditionalProperties:
+				e.Problems = append(e.Problems, fmt.Sprintf("%s.%s is unexpected", path, key))
+			}
+		}
+	case []interface{}:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range v {
+			s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, e)
+		}
+	}
+}
+
+// allows will tell whether a type is allowed, the integers are numbers too
+func (s *schema) allows(kind string) bool {
+	for _, t := range s.Type {
+		if t == kind || (t == "number" && kind == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf will return the JSON Schema type of a decoded value
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		f, err := v.Float64()
+		if err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
@@ -0,0 +1,130 @@
+package strikeapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestFixtures checks the answers of testdata/fixtures/<schema>/ against
+// their schema, and that they decode
+func TestFixtures(t *testing.T) {
+	for _, name := range []string{SchemaTorrents, SchemaStatus, SchemaCount} {
+		paths, err := filepath.Glob(filepath.Join("testdata", "fixtures", name, "*.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) == 0 {
+			t.Errorf("No fixture for the %s schema", name)
+		}
+		for _, path := range paths {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ValidateSchema(name, data); err != nil {
+				t.Errorf("%s : %s", path, err)
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(data)
+			}))
+			resp, err := http.Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch name {
+			case SchemaTorrents:
+				_, err = decodeEnvelope[Response](resp, name)
+			case SchemaStatus:
+				_, err = decodeEnvelope[ResponseStatus](resp, name)
+			case SchemaCount:
+				_, err = decodeEnvelope[ResponseStatusInt](resp, name)
+			}
+			resp.Body.Close()
+			ts.Close()
+			// The error envelopes decode into an APIError
+			if _, ok := err.(*APIError); ok {
+				err = nil
+			}
+			if err != nil {
+				t.Errorf("%s doesn't decode : %s", path, err)
+			}
+		}
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		schema   string
+		data     string
+		problems []string
+	}{
+		{SchemaCount, `{"statuscode":200,"message":12}`, nil},
+		{SchemaCount, `{"statuscode":503,"message":"Service unavailable"}`, nil},
+		{SchemaCount, `{"statuscode":200,"message":12.5}`, []string{"$.message is number instead of integer or string"}},
+		{SchemaTorrents, `{"statuscode":404,"torrents":null}`, nil},
+		{SchemaCount, `{"statuscode":200.5}`, []string{"$.statuscode is number instead of integer"}},
+		{SchemaStatus, `{"message":"link","extra":true}`, []string{"$.statuscode is missing", "$.extra is unexpected"}},
+		{SchemaStatus, `[]`, []string{"$ is array instead of object"}},
+		{SchemaTorrents, `{"statuscode":200,"torrents":[{"torrent_hash":"A","torrent_title":"B","seeds":1,"leeches":2,"size":3.5,"file_info":null}]}`, nil},
+		{SchemaTorrents, `{"statuscode":200,"torrents":[{"torrent_hash":"A","torrent_title":"B","seeds":"1","leeches":2,"size":3,` +
+			`"file_info":{"file_names":["a",1],"file_lengths":[1]}}]}`, []string{
+			"$.torrents[0].file_info.file_names[1] is integer instead of string",
+			"$.torrents[0].seeds is string instead of integer",
+		}},
+		{SchemaTorrents, `{"statuscode":200,"torrents":[{"torrent_hash":"A"}]}`, []string{
+			"$.torrents[0].torrent_title is missing", "$.torrents[0].seeds is missing", "$.torrents[0].leeches is missing", "$.torrents[0].size is missing",
+		}},
+	}
+	for _, test := range tests {
+		err := ValidateSchema(test.schema, []byte(test.data))
+		var problems []string
+		if e, ok := err.(*SchemaError); ok {
+			problems = e.Problems
+		} else if err != nil {
+			t.Errorf("%s : %s", test.data, err)
+		}
+		if !reflect.DeepEqual(problems, test.problems) {
+			t.Errorf("%s : expected %q, got %q", test.data, test.problems, problems)
+		}
+	}
+
+	if err := ValidateSchema("unknown", []byte("{}")); err == nil {
+		t.Error("Should fail with an unknown schema")
+	}
+	if err := ValidateSchema(SchemaCount, []byte("{")); err == nil {
+		t.Error("Should fail with malformed JSON")
+	}
+	if data, err := Schema(SchemaTorrents); err != nil || len(data) == 0 {
+		t.Errorf("Should read the schema document, got %v", err)
+	}
+}
+
+func TestSchemaValidation(t *testing.T) {
+	// The answer has a new field
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"statuscode":200,"message":12,"deprecated":true}`)
+	}))
+	defer ts.Close()
+	APIEndpoint = ts.URL
+	defer func() { SchemaValidation = ValidateOff }()
+
+	for mode, fails := range map[ValidationMode]bool{ValidateOff: false, ValidateWarn: false, ValidateStrict: true} {
+		SchemaValidation = mode
+		count, err := CountTorrents()
+		if fails {
+			if e, ok := err.(*SchemaError); !ok || e.Schema != SchemaCount || e.Problems[0] != "$.deprecated is unexpected" {
+				t.Errorf("Should get a SchemaError, got %v", err)
+			}
+			continue
+		}
+		if err != nil || count != 12 {
+			t.Errorf("Should only warn in mode %d, got %d %v", mode, count, err)
+		}
+	}
+}
@@ -0,0 +1,580 @@
+// Package store keeps a local copy of the torrents fetched from the Strike
+// API, with a full-text index over their titles, file names and
+// descriptions.
+//
+// The store is a single file of JSON lines: a header giving the schema
+// version, then one entry per change. Entries are only appended, Compact
+// rewrites the file with the latest state. Each time a torrent is stored a
+// Sample of its seeds and leeches is kept, giving its history. Set it as
+// strikeapi.LocalStore to record everything fetched and search it with
+// strikeapi.SearchLocal:
+//
+//	s, err := store.Open("torrents.jsonl")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer s.Close()
+//	strikeapi.LocalStore = s
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// SchemaVersion is the version of the file format
+// Version 2 added the samples, the torrent entries of a version 1 file are
+// turned into samples and the file is compacted when opened
+const SchemaVersion = 2
+
+// Custom errors
+var (
+	ErrSchemaVersion = errors.New("unsupported schema version")
+	ErrNotFound      = errors.New("torrent not found")
+	ErrClosed        = errors.New("store closed")
+)
+
+// Entry types
+const (
+	entryTorrent     = "torrent"
+	entryDescription = "description"
+	entrySample      = "sample"
+)
+
+// Weights of the matches in the search results
+const (
+	weightTitle       = 3
+	weightFile        = 2
+	weightDescription = 1
+)
+
+// header is the first line of the file
+type header struct {
+	Schema  int       `json:"schema"`
+	Created time.Time `json:"created"`
+}
+
+// entry is a line of the file
+type entry struct {
+	Type        string             `json:"type"`
+	Time        time.Time          `json:"time"`
+	FirstSeen   *time.Time         `json:"first_seen,omitempty"`
+	Hash        string             `json:"hash,omitempty"`
+	Torrent     *strikeapi.Torrent `json:"torrent,omitempty"`
+	Description *string            `json:"description,omitempty"`
+	Sample      *Sample            `json:"sample,omitempty"`
+}
+
+// Sample represents the state of a torrent at a time
+type Sample struct {
+	Time          time.Time `json:"time"`
+	Seeds         int       `json:"seeds"`
+	Leeches       int       `json:"leeches"`
+	DownloadCount int       `json:"download_count"`
+}
+
+// Record represents a stored torrent
+type Record struct {
+	Torrent        strikeapi.Torrent
+	Description    string
+	HasDescription bool
+	FirstSeen      time.Time
+	LastSeen       time.Time
+	// Samples are sorted by time
+	Samples []Sample
+}
+
+// Store represents an open store
+type Store struct {
+	// Now gives the time of the entries, time.Now by default
+	Now func() time.Time
+
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	records map[string]*Record
+	// index maps a token to the hashes holding it and the weight of the match
+	index map[string]map[string]int
+	// tokens are the tokens indexed for each hash
+	tokens map[string]map[string]int
+	// stale counts the entries replaced by a later one
+	stale int
+	// schema is the version of the file being loaded
+	schema int
+}
+
+// Open will open the store at path, creating it if needed
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		Now:     time.Now,
+		path:    path,
+		file:    file,
+		records: map[string]*Record{},
+		index:   map[string]map[string]int{},
+		tokens:  map[string]map[string]int{},
+	}
+	if err := s.load(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s : %s", path, err)
+	}
+	if s.schema < SchemaVersion {
+		if err := s.Compact(); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("%s : couldn't upgrade the schema : %s", path, err)
+		}
+	}
+	return s, nil
+}
+
+// load will read the file and build the index, a truncated last line left by
+// a crash is dropped
+func (s *Store) load() error {
+	reader := bufio.NewReader(s.file)
+	var offset int64
+	missingNewline := false
+
+	line, err := reader.ReadBytes('\n')
+	if err == io.EOF && len(line) == 0 {
+		s.schema = SchemaVersion
+		return s.writeHeader()
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+	h := header{}
+	if err := json.Unmarshal(line, &h); err != nil || h.Schema == 0 {
+		return fmt.Errorf("bad header %q", bytes.TrimSpace(line))
+	}
+	if h.Schema > SchemaVersion {
+		return fmt.Errorf("%s %d, the newest known is %d", ErrSchemaVersion, h.Schema, SchemaVersion)
+	}
+	s.schema = h.Schema
+	offset += int64(len(line))
+	missingNewline = err == io.EOF
+
+	for n := 2; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if len(line) == 0 && err == io.EOF {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		e := entry{}
+		if decodeErr := json.Unmarshal(line, &e); decodeErr != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				// Last line, drop it
+				if err := s.file.Truncate(offset); err != nil {
+					return err
+				}
+				break
+			}
+			return fmt.Errorf("line %d : %s", n, decodeErr)
+		}
+		offset += int64(len(line))
+		missingNewline = err == io.EOF
+		s.apply(&e)
+	}
+
+	if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if missingNewline {
+		if _, err := s.file.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) writeHeader() error {
+	data, err := json.Marshal(header{Schema: SchemaVersion, Created: s.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+// apply will apply an entry to the records and the index
+func (s *Store) apply(e *entry) {
+	switch e.Type {
+	case entryTorrent:
+		if e.Torrent == nil {
+			return
+		}
+		r := s.record(e.Torrent.Hash, e.Time)
+		if r.Torrent.Title != "" {
+			s.stale++
+		}
+		if e.FirstSeen != nil {
+			r.FirstSeen = *e.FirstSeen
+		}
+		// The search results don't give the files, keep the known ones
+		filesInfo := r.Torrent.FilesInfo
+		r.Torrent = *e.Torrent
+		if r.Torrent.FilesInfo == nil {
+			r.Torrent.FilesInfo = filesInfo
+		}
+		r.LastSeen = e.Time
+
+		switch {
+		case e.Sample != nil:
+			r.addSample(*e.Sample)
+		case s.schema < 2:
+			r.addSample(Sample{Time: e.Time, Seeds: e.Torrent.Seeds, Leeches: e.Torrent.Leeches, DownloadCount: e.Torrent.DownloadCount})
+		}
+		s.reindex(r)
+	case entrySample:
+		if e.Sample == nil {
+			return
+		}
+		s.record(e.Hash, e.Time).addSample(*e.Sample)
+	case entryDescription:
+		if e.Description == nil {
+			return
+		}
+		r := s.record(e.Hash, e.Time)
+		if r.HasDescription {
+			s.stale++
+		}
+		r.Description, r.HasDescription = *e.Description, true
+		s.reindex(r)
+	}
+}
+
+// record will return the record of a hash, creating it if needed
+func (s *Store) record(hash string, t time.Time) *Record {
+	r, ok := s.records[hash]
+	if !ok {
+		r = &Record{Torrent: strikeapi.Torrent{Hash: hash}, FirstSeen: t, LastSeen: t}
+		s.records[hash] = r
+	}
+	return r
+}
+
+// addSample will insert a sample, keeping the samples sorted
+func (r *Record) addSample(sample Sample) {
+	i := sort.Search(len(r.Samples), func(i int) bool {
+		return r.Samples[i].Time.After(sample.Time)
+	})
+	r.Samples = append(r.Samples, Sample{})
+	copy(r.Samples[i+1:], r.Samples[i:])
+	r.Samples[i] = sample
+}
+
+// append will write an entry to the file and apply it
+func (s *Store) append(e *entry) error {
+	if s.file == nil {
+		return ErrClosed
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.apply(e)
+	return nil
+}
+
+// Put will store a torrent, replacing the previous version
+func (s *Store) Put(t *strikeapi.Torrent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	torrent := *t
+	torrent.Hash = strings.ToUpper(torrent.Hash)
+	sample := &Sample{Time: now, Seeds: t.Seeds, Leeches: t.Leeches, DownloadCount: t.DownloadCount}
+	return s.append(&entry{Type: entryTorrent, Time: now, Torrent: &torrent, Sample: sample})
+}
+
+// AddSample will store a sample of a torrent, e.g. polled from another source
+func (s *Store) AddSample(hash string, sample Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sample.Time.IsZero() {
+		sample.Time = s.Now()
+	}
+	return s.append(&entry{Type: entrySample, Time: sample.Time, Hash: strings.ToUpper(hash), Sample: &sample})
+}
+
+// Samples will return the samples of a hash, sorted by time
+func (s *Store) Samples(hash string) ([]Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[strings.ToUpper(hash)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]Sample{}, r.Samples...), nil
+}
+
+// PutDescription will store the description of a torrent
+func (s *Store) PutDescription(hash, description string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.append(&entry{Type: entryDescription, Time: s.Now(), Hash: strings.ToUpper(hash), Description: &description})
+}
+
+// Get will return the record of a hash
+func (s *Store) Get(hash string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[strings.ToUpper(hash)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	record := *r
+	record.Samples = append([]Sample{}, r.Samples...)
+	return &record, nil
+}
+
+// Len will return the number of stored torrents
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+// Torrents will return every stored torrent, sorted by hash
+func (s *Store) Torrents() []strikeapi.Torrent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hashes := []string{}
+	for hash, r := range s.records {
+		// Descriptions of torrents never fetched aren't torrents
+		if r.Torrent.Title != "" {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+
+	torrents := []strikeapi.Torrent{}
+	for _, hash := range hashes {
+		torrents = append(torrents, s.records[hash].Torrent)
+	}
+	return torrents
+}
+
+// Search will return the torrents matching all the words of the query, the
+// matches in the title rank above the ones in file names and descriptions,
+// then the torrents with the most seeds come first
+func (s *Store) Search(query string) ([]strikeapi.Torrent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	words := tokenize(query)
+	if len(words) == 0 {
+		return []strikeapi.Torrent{}, nil
+	}
+
+	scores := map[string]int{}
+	for hash, weight := range s.index[words[0]] {
+		scores[hash] = weight
+	}
+	for _, word := range words[1:] {
+		hashes := s.index[word]
+		for hash := range scores {
+			weight, ok := hashes[hash]
+			if !ok {
+				delete(scores, hash)
+				continue
+			}
+			scores[hash] += weight
+		}
+	}
+
+	hashes := []string{}
+	for hash := range scores {
+		// Descriptions of torrents never fetched aren't results
+		if s.records[hash].Torrent.Title != "" {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		a, b := hashes[i], hashes[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		if s.records[a].Torrent.Seeds != s.records[b].Torrent.Seeds {
+			return s.records[a].Torrent.Seeds > s.records[b].Torrent.Seeds
+		}
+		return a < b
+	})
+
+	torrents := []strikeapi.Torrent{}
+	for _, hash := range hashes {
+		torrents = append(torrents, s.records[hash].Torrent)
+	}
+	return torrents, nil
+}
+
+// reindex will update the index with the content of a record
+func (s *Store) reindex(r *Record) {
+	hash := r.Torrent.Hash
+	for token := range s.tokens[hash] {
+		delete(s.index[token], hash)
+		if len(s.index[token]) == 0 {
+			delete(s.index, token)
+		}
+	}
+
+	tokens := map[string]int{}
+	add := func(text string, weight int) {
+		for _, token := range tokenize(text) {
+			if tokens[token] < weight {
+				tokens[token] = weight
+			}
+		}
+	}
+	add(r.Description, weightDescription)
+	if r.Torrent.FilesInfo != nil {
+		for _, f := range r.Torrent.FilesInfo.FileInfo {
+			add(f.FileName, weightFile)
+		}
+	}
+	add(r.Torrent.Title, weightTitle)
+
+	for token, weight := range tokens {
+		if s.index[token] == nil {
+			s.index[token] = map[string]int{}
+		}
+		s.index[token][hash] = weight
+	}
+	s.tokens[hash] = tokens
+}
+
+// tokenize will split a text in lower case words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Stale will return the number of entries a compaction would drop
+func (s *Store) Stale() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stale
+}
+
+// Compact will rewrite the file with one entry per torrent and description,
+// with the current schema version
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return ErrClosed
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".compact")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(header{Schema: SchemaVersion, Created: s.Now()}); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	hashes := []string{}
+	for hash := range s.records {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		r := s.records[hash]
+		entries := []entry{}
+		for i := range r.Samples {
+			entries = append(entries, entry{Type: entrySample, Time: r.Samples[i].Time, Hash: hash, Sample: &r.Samples[i]})
+		}
+		if r.Torrent.Title != "" {
+			torrent, firstSeen := r.Torrent, r.FirstSeen
+			entries = append(entries, entry{Type: entryTorrent, Time: r.LastSeen, FirstSeen: &firstSeen, Torrent: &torrent})
+		}
+		if r.HasDescription {
+			description := r.Description
+			entries = append(entries, entry{Type: entryDescription, Time: r.LastSeen, Hash: hash, Description: &description})
+		}
+		for i := range entries {
+			if err := encoder.Encode(&entries[i]); err != nil {
+				tmp.Close()
+				return err
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	// Reopen the new file for the next entries
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = file
+	s.stale = 0
+	s.schema = SchemaVersion
+	return nil
+}
+
+// Close will close the file of the store
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return ErrClosed
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
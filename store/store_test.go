@@ -0,0 +1,299 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+var testTorrents = []strikeapi.Torrent{
+	{
+		Title: "Slackware 14.1 x86_64 DVD ISO",
+		Hash:  "156B69B8643BD11849A5D8F2122E13FBB61BD041",
+		Seeds: 192,
+		FilesInfo: &strikeapi.FilesInfo{FileInfo: []strikeapi.FileInfo{
+			{FileName: "slackware64-14.1-iso/slackware64-14.1-install-dvd.iso", FileSize: 2437393940},
+		}},
+	},
+	{
+		Title: "Arch Linux 2015.01.01 (x86/x64)",
+		Hash:  "B425907E5755031BDA4A8D1B6DCCACA97DA14C04",
+		Seeds: 645,
+	},
+	{
+		Title: "Linux Mint 17.1 Cinnamon",
+		Hash:  "6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088",
+		Seeds: 90,
+	},
+}
+
+// fakeNow returns a clock starting on the 1st of March 2015, moving by a
+// minute at each call
+func fakeNow() func() time.Time {
+	now := time.Date(2015, time.March, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+}
+
+func openTestStore(t *testing.T) (*Store, string) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "torrents.jsonl")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Now = fakeNow()
+	return s, path
+}
+
+func hashes(torrents []strikeapi.Torrent) []string {
+	h := []string{}
+	for _, t := range torrents {
+		h = append(h, t.Hash[:4])
+	}
+	return h
+}
+
+func TestSearch(t *testing.T) {
+	s, path := openTestStore(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer s.Close()
+
+	for i := range testTorrents {
+		if err := s.Put(&testTorrents[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.PutDescription("6c32b66cee44b7a0e3e42e22acf5e77bf3218088", "A Slackware alternative")
+
+	tests := map[string][]string{
+		"linux":             {"B425", "6C32"},
+		"LINUX mint":        {"6C32"},
+		"dvd":               {"156B"},
+		"install":           {"156B"},
+		"slackware":         {"156B", "6C32"},
+		"slackware 14.1":    {"156B"},
+		"windows":           {},
+		"":                  {},
+		"arch, linux 2015!": {"B425"},
+	}
+	for query, expected := range tests {
+		torrents, err := s.Search(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(hashes(torrents), expected) {
+			t.Errorf("%q : expected %v, got %v", query, expected, hashes(torrents))
+		}
+	}
+
+	// Updates replace the previous version and keep the files
+	update := testTorrents[0]
+	update.Title, update.Seeds, update.FilesInfo = "Slackware 14.1 Live", 10, nil
+	s.Put(&update)
+	if torrents, _ := s.Search("64"); len(torrents) != 0 {
+		t.Errorf("The old title is still indexed")
+	}
+	if torrents, _ := s.Search("live install"); len(torrents) != 1 || torrents[0].Seeds != 10 {
+		t.Errorf("Bad update : %+v", torrents)
+	}
+	s.PutDescription("ABCD", "A description without torrent")
+	if all := s.Torrents(); !reflect.DeepEqual(hashes(all), []string{"156B", "6C32", "B425"}) {
+		t.Errorf("Bad torrents %v", hashes(all))
+	}
+}
+
+func TestReopenAndCompact(t *testing.T) {
+	s, path := openTestStore(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	for i := 0; i < 3; i++ {
+		for j := range testTorrents {
+			s.Put(&testTorrents[j])
+		}
+	}
+	s.PutDescription(testTorrents[1].Hash, "first")
+	s.PutDescription(testTorrents[1].Hash, "rolling release")
+	expected, _ := s.Get(testTorrents[1].Hash)
+	if s.Stale() != 7 {
+		t.Errorf("Should have 7 stale entries, got %d", s.Stale())
+	}
+	s.Close()
+	if err := s.Put(&testTorrents[0]); err != ErrClosed {
+		t.Errorf("Should get an ErrClosed, got %v", err)
+	}
+
+	for _, compact := range []bool{false, true} {
+		s, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Len() != 3 {
+			t.Errorf("Should have 3 torrents, got %d", s.Len())
+		}
+		r, err := s.Get(strings.ToLower(testTorrents[1].Hash))
+		if err != nil || !reflect.DeepEqual(r, expected) {
+			t.Errorf("Bad record after reopening :\n%+v\n%+v", r, expected)
+		}
+		if torrents, _ := s.Search("rolling"); len(torrents) != 1 {
+			t.Errorf("Description not indexed")
+		}
+		if compact {
+			if err := s.Compact(); err != nil {
+				t.Fatal(err)
+			}
+			if s.Stale() != 0 {
+				t.Errorf("Stale entries after compaction")
+			}
+			// Entries are still appended after a compaction
+			s.PutDescription(testTorrents[0].Hash, "after")
+		}
+		s.Close()
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 15 {
+		t.Errorf("The compacted file should have 15 lines, got %d :\n%s", lines, data)
+	}
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if r, _ := s.Get(testTorrents[0].Hash); r.Description != "after" || r.Torrent.FilesInfo == nil || len(r.Samples) != 3 {
+		t.Errorf("Bad record after compaction : %+v", r)
+	}
+}
+
+func TestSamples(t *testing.T) {
+	s, path := openTestStore(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer s.Close()
+
+	torrent := testTorrents[1]
+	s.Put(&torrent)
+	torrent.Seeds, torrent.Leeches = 700, 20
+	s.Put(&torrent)
+
+	start := time.Date(2015, time.March, 1, 0, 0, 0, 0, time.UTC)
+	// Samples can come out of order
+	s.AddSample(torrent.Hash, Sample{Time: start.Add(-time.Hour), Seeds: 500})
+	s.AddSample("unknown", Sample{Seeds: 1})
+
+	samples, err := s.Samples(strings.ToLower(torrent.Hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Sample{
+		{Time: start.Add(-time.Hour), Seeds: 500},
+		{Time: start.Add(time.Minute), Seeds: 645},
+		{Time: start.Add(2 * time.Minute), Seeds: 700, Leeches: 20},
+	}
+	if !reflect.DeepEqual(samples, expected) {
+		t.Errorf("Bad samples :\n%+v\n%+v", samples, expected)
+	}
+	if samples, _ := s.Samples("UNKNOWN"); len(samples) != 1 || !samples[0].Time.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("Bad samples of an unknown torrent : %+v", samples)
+	}
+	if _, err := s.Samples("nothing"); err != ErrNotFound {
+		t.Errorf("Should get an ErrNotFound, got %v", err)
+	}
+	if torrents, _ := s.Search("unknown"); len(torrents) != 0 {
+		t.Errorf("Torrents only known by their samples aren't search results")
+	}
+}
+
+func TestSchemaUpgrade(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "v1.jsonl")
+
+	v1 := `{"schema":1,"created":"2015-03-01T00:00:00Z"}
+{"type":"torrent","time":"2015-03-01T00:00:00Z","torrent":{"torrent_hash":"B425907E5755031BDA4A8D1B6DCCACA97DA14C04","torrent_title":"Arch Linux","seeds":600,"leeches":10}}
+{"type":"torrent","time":"2015-03-02T00:00:00Z","torrent":{"torrent_hash":"B425907E5755031BDA4A8D1B6DCCACA97DA14C04","torrent_title":"Arch Linux","seeds":645,"leeches":13}}
+`
+	ioutil.WriteFile(path, []byte(v1), 0644)
+
+	for i := 0; i < 2; i++ {
+		s, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples, _ := s.Samples("B425907E5755031BDA4A8D1B6DCCACA97DA14C04")
+		if len(samples) != 2 || samples[0].Seeds != 600 || samples[1].Leeches != 13 {
+			t.Errorf("Bad samples after the upgrade : %+v", samples)
+		}
+		s.Close()
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	if !strings.HasPrefix(string(data), `{"schema":2,`) {
+		t.Errorf("The file should be upgraded :\n%s", data)
+	}
+}
+
+func TestTruncatedFile(t *testing.T) {
+	s, path := openTestStore(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	s.Put(&testTorrents[0])
+	s.Close()
+
+	// A crash while writing the last line
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"type":"torrent","torr`)
+	f.Close()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(&testTorrents[1])
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Len() != 2 {
+		t.Errorf("Should have 2 torrents, got %d", s.Len())
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := map[string]string{
+		"newer":   `{"schema":99}` + "\n",
+		"garbage": "torrents\n",
+		"corrupt": `{"schema":1}` + "\n" + "{]\n" + `{"type":"description","hash":"A","description":"a"}` + "\n",
+	}
+	for name, content := range tests {
+		path := filepath.Join(dir, name)
+		ioutil.WriteFile(path, []byte(content), 0644)
+		if _, err := Open(path); err == nil {
+			t.Errorf("%s : should fail", name)
+		}
+	}
+	if _, err := Open(filepath.Join(dir, "newer")); !strings.Contains(err.Error(), ErrSchemaVersion.Error()) {
+		t.Errorf("Bad error %v", err)
+	}
+}
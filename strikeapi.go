@@ -17,9 +17,13 @@ import (
 // APIEndpoint represents the APIEnpoint
 var APIEndpoint = "https://getstrike.net/api/v2"
 
+// HTTPClient is the client used to make the requests
+var HTTPClient = &http.Client{}
+
 // Custom errors
 var (
 	ErrEmptyHashes = errors.New("empty hash array given")
+	ErrDuplicate   = errors.New("torrent already added")
 )
 
 // Categories
@@ -100,6 +104,22 @@ const (
 	iOS               = "iOS"
 )
 
+// Categories lists all the categories
+var Categories = []string{Anime, Applications, Books, Games, Movies, Music, Other, TV, XXX}
+
+// SubCategories lists the subcategories of each category
+var SubCategories = map[string][]string{
+	Anime:        {AnimeMusicVideo, EnglishTranslated, OtherAnime},
+	Applications: {Android, iOS, Linux, Mac, OtherApplications, UNIX, Windows},
+	Books:        {Academic, AudioBooks, Comics, Ebooks, Fiction, Magazines, Newspapers, NonFiction, OtherBooks, Poetry, Textbooks},
+	Games:        {Handheld, Mac, OtherGames, PC, PS2, PS3, PSP, Wii, XBOX360},
+	Movies:       {Movies3D, Animation, Asian, Bollywood, DubbedMovies, HighresMovies, MovieClips, OtherMovies, Trailer, UltraHD},
+	Music:        {AAC, Concerts, Karaoke, Lossless, Mp3, MusicVideos, OtherMusic, RadioShows, SoundClips, Soundtrack, Transcode},
+	Other:        {Pictures, Subtitles, Tutorials, Unsorted, Wallpapers},
+	TV:           {Documentary, OtherTV},
+	XXX:          {HDVideo, Hentai, Magazines, OtherXXX, Pictures, UltraHD, Video},
+}
+
 // Response represents the information given by the API
 type Response struct {
 	ResultSize   int       `json:"results"`
@@ -108,6 +128,19 @@ type Response struct {
 	Torrents     []Torrent `json:"torrents"`
 }
 
+// APIError represents an error statuscode returned by the API
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("API error %d", e.Status)
+	}
+	return fmt.Sprintf("API error %d : %s", e.Status, e.Message)
+}
+
 // ResponseStatus represents a basic response information given by the API
 type ResponseStatus struct {
 	Status  int    `json:"statuscode"`
@@ -151,9 +184,23 @@ type Torrent struct {
 	FilesInfo        *FilesInfo `json:"file_info"`
 }
 
+// Sink represents a download client torrents can be sent to
+// Sinks return ErrDuplicate when the torrent is already known
+type Sink interface {
+	Send(t *Torrent) error
+}
+
 // Special date struct to unmarshall properly
 type torrentDate struct{ *time.Time }
 
+// UploadDateLayout is the layout of the upload dates given by the API
+const UploadDateLayout = "Jan _2, 2006"
+
+// UploadTime will parse the UploadDate of a Torrent
+func (t *Torrent) UploadTime() (time.Time, error) {
+	return time.Parse(UploadDateLayout, t.UploadDate)
+}
+
 // UnmarshalJSON is a custom unmarshal function to handle FileInfo struct
 func (f *FilesInfo) UnmarshalJSON(data []byte) error {
 	dataBytes := bytes.NewReader(data)
@@ -179,6 +226,23 @@ func (f *FilesInfo) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalJSON will encode FilesInfo the way the API does
+func (f FilesInfo) MarshalJSON() ([]byte, error) {
+	var tempFilesInfo struct {
+		FileNames   []string  `json:"file_names"`
+		FileLenghts []float64 `json:"file_lengths"`
+	}
+	tempFilesInfo.FileNames = []string{}
+	tempFilesInfo.FileLenghts = []float64{}
+
+	for _, fileInfo := range f.FileInfo {
+		tempFilesInfo.FileNames = append(tempFilesInfo.FileNames, fileInfo.FileName)
+		tempFilesInfo.FileLenghts = append(tempFilesInfo.FileLenghts, fileInfo.FileSize)
+	}
+
+	return json.Marshal(tempFilesInfo)
+}
+
 // GetTorrentsInfos will get all the infos from a list of Torrent
 func GetTorrentsInfos(hashes []string) ([]Torrent, error) {
 	// Check arguments
@@ -196,7 +260,7 @@ func GetTorrentsInfos(hashes []string) ([]Torrent, error) {
 	u.RawQuery = urlValues.Encode()
 
 	// Make the request
-	resp, err := http.Get(u.String())
+	resp, err := HTTPClient.Get(u.String())
 	if err != nil {
 		log.Println("Counldn't make the GET ", err)
 		return nil, err
@@ -204,12 +268,13 @@ func GetTorrentsInfos(hashes []string) ([]Torrent, error) {
 	defer resp.Body.Close()
 
 	// Parse the response
-	response, err := newResponse(resp)
+	response, err := decodeEnvelope[Response](resp, SchemaTorrents)
 	if err != nil {
 		return nil, err
 	}
+	storeTorrents(response.Torrents)
 
-	return response.Torrents, nil
+	return safeTorrents(response.Torrents), nil
 }
 
 // GetTorrentInfos will get all the infos of a Torrent from a hash
@@ -232,7 +297,7 @@ func CountTorrents() (int, error) {
 		return 0, err
 	}
 
-	resp, err := http.Get(u.String())
+	resp, err := HTTPClient.Get(u.String())
 	if err != nil {
 		log.Println("Counldn't make the GET ", err)
 		return 0, err
@@ -240,15 +305,11 @@ func CountTorrents() (int, error) {
 	defer resp.Body.Close()
 
 	// Parse the response
-	response, err := newResponseStatusInt(resp)
+	response, err := decodeEnvelope[ResponseStatusInt](resp, SchemaCount)
 	if err != nil {
 		return 0, err
 	}
 
-	if response.Status != 200 {
-		return 0, fmt.Errorf("Error while getting the number of torrents")
-	}
-
 	return response.Message, nil
 }
 
@@ -264,7 +325,7 @@ func GetDescription(hash string) (string, error) {
 
 	u.RawQuery = urlValues.Encode()
 
-	resp, err := http.Get(u.String())
+	resp, err := HTTPClient.Get(u.String())
 	if err != nil {
 		log.Println("Counldn't make the GET ", err)
 		return "", err
@@ -272,19 +333,17 @@ func GetDescription(hash string) (string, error) {
 	defer resp.Body.Close()
 
 	// Parse the response
-	response, err := newResponseStatus(resp)
+	response, err := decodeEnvelope[ResponseStatus](resp, SchemaStatus)
 	if err != nil {
 		return "", err
 	}
-	if response.Status != 200 {
-		return "", fmt.Errorf("Error while getting the number of torrents")
-	}
 
 	description, err := base64.StdEncoding.DecodeString(response.Message)
 	if err != nil {
 		fmt.Println("error:", err)
 		return "", err
 	}
+	storeDescription(hash, string(description))
 
 	return string(description), nil
 }
@@ -294,6 +353,18 @@ func (t *Torrent) GetDescription() (string, error) {
 	return GetDescription(t.Hash)
 }
 
+// SearchQuery represents the parameters of a search
+type SearchQuery struct {
+	Phrase      string
+	Category    string
+	SubCategory string
+}
+
+// Search will run the SearchQuery
+func (q SearchQuery) Search() ([]Torrent, error) {
+	return SearchWithCategoryAndSubCategory(q.Phrase, q.Category, q.SubCategory)
+}
+
 // Search will search for torrents
 func Search(phrase string) ([]Torrent, error) {
 	return SearchWithCategoryAndSubCategory(phrase, "", "")
@@ -306,6 +377,9 @@ func SearchWithCategory(phrase, category string) ([]Torrent, error) {
 
 // SearchWithCategoryAndSubCategory will search with category and subcategory
 func SearchWithCategoryAndSubCategory(phrase, category, subCategory string) ([]Torrent, error) {
+	if err := checkSafeCategory(category, subCategory); err != nil {
+		return nil, err
+	}
 	// Generate URL
 	u, err := url.Parse(fmt.Sprintf("%s/torrents/search/", APIEndpoint))
 	if err != nil {
@@ -321,7 +395,7 @@ func SearchWithCategoryAndSubCategory(phrase, category, subCategory string) ([]T
 	}
 	u.RawQuery = urlValues.Encode()
 
-	resp, err := http.Get(u.String())
+	resp, err := HTTPClient.Get(u.String())
 	if err != nil {
 		log.Println("Counldn't make the GET ", err)
 		return nil, err
@@ -329,12 +403,13 @@ func SearchWithCategoryAndSubCategory(phrase, category, subCategory string) ([]T
 	defer resp.Body.Close()
 
 	// Parse the response
-	response, err := newResponse(resp)
+	response, err := decodeEnvelope[Response](resp, SchemaTorrents)
 	if err != nil {
 		return nil, err
 	}
+	storeTorrents(response.Torrents)
 
-	return response.Torrents, nil
+	return filterTorrents(safeTorrents(response.Torrents)), nil
 }
 
 // GetDownloadLink will get a download link of a Torrent
@@ -353,7 +428,7 @@ func GetDownloadLink(hash string) (string, error) {
 	urlValues.Add("hash", hash)
 
 	u.RawQuery = urlValues.Encode()
-	resp, err := http.Get(u.String())
+	resp, err := HTTPClient.Get(u.String())
 	if err != nil {
 		log.Println("Counldn't make the GET ", err)
 		return "", err
@@ -361,90 +436,69 @@ func GetDownloadLink(hash string) (string, error) {
 	defer resp.Body.Close()
 
 	// Parse the response
-	response, err := newResponseStatus(resp)
+	response, err := decodeEnvelope[ResponseStatus](resp, SchemaStatus)
 	if err != nil {
 		return "", err
 	}
 	return response.Message, nil
 }
 
-// GetTopTorrents will get a list of top torrents
-func GetTopTorrents(category string) ([]Torrent, error) {
-	// Set the category to all by default
-	if category == "" {
-		category = "all"
-	}
-	// Generate URL
-	u, err := url.Parse(fmt.Sprintf("%s/torrents/top/", APIEndpoint))
+// GetTorrentFile will download the .torrent file of a Torrent
+func (t *Torrent) GetTorrentFile() ([]byte, error) {
+	return GetTorrentFile(t.Hash)
+}
+
+// GetTorrentFile will download the .torrent file of a Torrent from a hash
+func GetTorrentFile(hash string) ([]byte, error) {
+	link, err := GetDownloadLink(hash)
 	if err != nil {
 		return nil, err
 	}
-	urlValues := &url.Values{}
-	urlValues.Add("category", category)
 
-	u.RawQuery = urlValues.Encode()
-	resp, err := http.Get(u.String())
+	resp, err := HTTPClient.Get(link)
 	if err != nil {
 		log.Println("Counldn't make the GET ", err)
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// Parse the response
-	response, err := newResponse(resp)
-	if err != nil {
-		return nil, err
-	}
-	return response.Torrents, nil
-}
-
-// newResponse will parse a Response struct
-func newResponse(resp *http.Response) (*Response, error) {
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("Couldn't read response body", err)
-		return nil, err
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error while downloading the torrent file : %s", resp.Status)
 	}
 
-	response := &Response{}
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		log.Println("Couln't unmarshall result : ", err)
-		return nil, err
-	}
-	return response, nil
+	return ioutil.ReadAll(resp.Body)
 }
 
-// newResponse will parse a ResponseStatus struct
-func newResponseStatus(resp *http.Response) (*ResponseStatus, error) {
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("Couldn't read response body", err)
+// GetTopTorrents will get a list of top torrents
+func GetTopTorrents(category string) ([]Torrent, error) {
+	// Set the category to all by default
+	if category == "" {
+		category = "all"
+	}
+	if err := checkSafeCategory(category, ""); err != nil {
 		return nil, err
 	}
-
-	response := &ResponseStatus{}
-	err = json.Unmarshal(body, &response)
+	// Generate URL
+	u, err := url.Parse(fmt.Sprintf("%s/torrents/top/", APIEndpoint))
 	if err != nil {
-		log.Println("Couln't unmarshall result : ", err)
 		return nil, err
 	}
-	return response, nil
-}
+	urlValues := &url.Values{}
+	urlValues.Add("category", category)
 
-// newResponse will parse a ResponseStatusInt struct
-func newResponseStatusInt(resp *http.Response) (*ResponseStatusInt, error) {
-	body, err := ioutil.ReadAll(resp.Body)
+	u.RawQuery = urlValues.Encode()
+	resp, err := HTTPClient.Get(u.String())
 	if err != nil {
-		log.Println("Couldn't read response body", err)
+		log.Println("Counldn't make the GET ", err)
 		return nil, err
 	}
+	defer resp.Body.Close()
 
-	response := &ResponseStatusInt{}
-	err = json.Unmarshal(body, &response)
+	// Parse the response
+	response, err := decodeEnvelope[Response](resp, SchemaTorrents)
 	if err != nil {
-		log.Println("Couln't unmarshall result : ", err)
 		return nil, err
 	}
-	return response, nil
+	storeTorrents(response.Torrents)
+	return filterTorrents(safeTorrents(response.Torrents)), nil
 }
@@ -1,11 +1,14 @@
 package strikeapi
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"testing"
+	"time"
 )
 
 // TestEmptyMessage tests if the message empty
@@ -219,3 +222,112 @@ func TestGetTopTorrents(t *testing.T) {
 		t.Errorf("Torrent result not properly set")
 	}
 }
+
+func TestGetTorrentFile(t *testing.T) {
+	torrentFile := "d8:announce35:udp://tracker.openbittorrent.com:80e"
+
+	// Fake server serving both the API and the .torrent file
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/torrents/download/" {
+			fmt.Fprintf(w, `{"statuscode":200,"message":"%s/file.torrent"}`, ts.URL)
+			return
+		}
+		fmt.Fprint(w, torrentFile)
+	}))
+	defer ts.Close()
+
+	APIEndpoint = ts.URL
+
+	data, err := GetTorrentFile("B425907E5755031BDA4A8D1B6DCCACA97DA14C04")
+	if err != nil {
+		t.Errorf("Error getting the torrent file from hash")
+	}
+	if string(data) != torrentFile {
+		t.Errorf("Bad torrent file")
+	}
+}
+
+func TestUploadTime(t *testing.T) {
+	for date, expected := range map[string]time.Time{
+		"Jan  6, 2015": time.Date(2015, time.January, 6, 0, 0, 0, 0, time.UTC),
+		"Feb 24, 2014": time.Date(2014, time.February, 24, 0, 0, 0, 0, time.UTC),
+	} {
+		torrent := &Torrent{UploadDate: date}
+		uploadTime, err := torrent.UploadTime()
+		if err != nil {
+			t.Errorf("Error parsing %q", date)
+		}
+		if !uploadTime.Equal(expected) {
+			t.Errorf("Bad upload time for %q : %s", date, uploadTime)
+		}
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	var query url.Values
+	// Fake server recording the query
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		fmt.Fprintln(w, `{"results":0,"statuscode":200,"responsetime":0.01,"torrents":[]}`)
+	}))
+	defer ts.Close()
+
+	APIEndpoint = ts.URL
+
+	q := SearchQuery{Phrase: "Marvel", Category: Books, SubCategory: Comics}
+	if _, err := q.Search(); err != nil {
+		t.Errorf("Error searching for torrent")
+	}
+	if query.Get("phrase") != "Marvel" || query.Get("category") != Books || query.Get("subcategory") != Comics {
+		t.Errorf("Bad query sent : %v", query)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	rawHTMLResponse := `{"statuscode":500,"message":"Internal error"}`
+	// Fake server with a fake answer
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, rawHTMLResponse)
+	}))
+	defer ts.Close()
+
+	APIEndpoint = ts.URL
+
+	_, err := GetDownloadLink("B425907E5755031BDA4A8D1B6DCCACA97DA14C04")
+	if e, ok := err.(*APIError); !ok || e.Status != 500 || e.Message != "Internal error" {
+		t.Errorf("Should get an APIError, got %v", err)
+	}
+
+	_, err = Search("Slackware")
+	if e, ok := err.(*APIError); !ok || e.Status != 500 {
+		t.Errorf("Should get an APIError, got %v", err)
+	}
+}
+
+func TestSubCategories(t *testing.T) {
+	if len(SubCategories) != len(Categories) {
+		t.Errorf("Every category should have its subcategories")
+	}
+	for _, category := range Categories {
+		if len(SubCategories[category]) == 0 {
+			t.Errorf("No subcategory for %s", category)
+		}
+	}
+}
+
+func TestFilesInfoJSON(t *testing.T) {
+	files := &FilesInfo{FileInfo: []FileInfo{{FileName: "a.iso", FileSize: 12}, {FileName: "b.txt", FileSize: 3}}}
+	data, err := json.Marshal(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"file_names":["a.iso","b.txt"],"file_lengths":[12,3]}` {
+		t.Errorf("Bad json %s", data)
+	}
+
+	decoded := &FilesInfo{}
+	if err := json.Unmarshal(data, decoded); err != nil || !reflect.DeepEqual(decoded, files) {
+		t.Errorf("Bad round trip : %+v %v", decoded, err)
+	}
+}
@@ -0,0 +1,392 @@
+// Package strikeapitest provides a fake Strike API serving an in-memory
+// catalogue of torrents, to test the code using strikeapi without the
+// network:
+//
+//	s := strikeapitest.NewServer(strikeapi.Torrent{
+//		Title:    "Slackware 14.1 x86_64 DVD ISO",
+//		Hash:     "156B69B8643BD11849A5D8F2122E13FBB61BD041",
+//		Category: strikeapi.Applications,
+//		Seeds:    192,
+//	})
+//	defer s.Close()
+//	defer s.Use()()
+//
+//	// The next search times out, the one after gets a statuscode 500
+//	s.Fail(strikeapitest.PathSearch, strikeapitest.Fault{Latency: time.Minute, Times: 1})
+//	s.Fail(strikeapitest.PathSearch, strikeapitest.Fault{Status: 500, Times: 1})
+//
+// The answers have the envelopes of the real API: the torrents of a search,
+// an info or a top request, or a statuscode and a message.
+package strikeapitest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Paths of the endpoints
+const (
+	PathSearch       = "/torrents/search/"
+	PathInfo         = "/torrents/info/"
+	PathTop          = "/torrents/top/"
+	PathCount        = "/torrents/count/"
+	PathDescriptions = "/torrents/descriptions/"
+	PathDownload     = "/torrents/download/"
+	// PathFiles serves the .torrent files the download links point to
+	PathFiles = "/torrents/files/"
+)
+
+// MaxTopTorrents is the number of torrents of a top request
+const MaxTopTorrents = 100
+
+// Fault represents a failure injected in the answers of an endpoint
+type Fault struct {
+	// Latency delays the answer, the request can time out
+	Latency time.Duration
+	// Status answers with this statuscode in the envelope, like 500, and the
+	// Message when set
+	Status  int
+	Message string
+	// HTTPStatus answers with this HTTP status and an empty body
+	HTTPStatus int
+	// Body answers with this body instead, like malformed JSON
+	Body string
+	// Drop closes the connection in the middle of the answer, the clients
+	// would retry a connection closed before the answer
+	Drop bool
+	// Times is the number of answers failing, they all fail when 0
+	Times int
+}
+
+// Server represents a fake Strike API
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	latency      time.Duration
+	torrents     []strikeapi.Torrent
+	descriptions map[string]string
+	files        map[string][]byte
+	faults       map[string][]Fault
+	requests     map[string]int
+}
+
+// NewServer will start a fake Strike API serving the torrents, call Close
+// when done
+func NewServer(torrents ...strikeapi.Torrent) *Server {
+	s := &Server{
+		descriptions: map[string]string{},
+		files:        map[string][]byte{},
+		faults:       map[string][]Fault{},
+		requests:     map[string]int{},
+	}
+	s.Add(torrents...)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Use will point strikeapi.APIEndpoint to the server, the returned function
+// restores the previous endpoint
+func (s *Server) Use() func() {
+	previous := strikeapi.APIEndpoint
+	strikeapi.APIEndpoint = s.URL
+	return func() { strikeapi.APIEndpoint = previous }
+}
+
+// Add will add torrents to the catalogue, replacing the ones with the same
+// hash
+func (s *Server) Add(torrents ...strikeapi.Torrent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range torrents {
+		if i := s.find(t.Hash); i >= 0 {
+			s.torrents[i] = t
+			continue
+		}
+		s.torrents = append(s.torrents, t)
+	}
+}
+
+// Remove will remove a torrent from the catalogue
+func (s *Server) Remove(hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.find(hash); i >= 0 {
+		s.torrents = append(s.torrents[:i], s.torrents[i+1:]...)
+	}
+}
+
+// SetDescription will set the description of a torrent, the torrents have
+// an empty description by default
+func (s *Server) SetDescription(hash, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.descriptions[strings.ToUpper(hash)] = description
+}
+
+// SetTorrentFile will set the .torrent file of a torrent, a minimal one
+// naming the torrent is served by default
+func (s *Server) SetTorrentFile(hash string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[strings.ToUpper(hash)] = data
+}
+
+// SetLatency will delay every answer
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// Fail will inject a fault in the answers of an endpoint, the faults are
+// applied in the order they were injected
+func (s *Server) Fail(path string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[path] = append(s.faults[path], f)
+}
+
+// Heal will remove the faults of every endpoint
+func (s *Server) Heal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = map[string][]Fault{}
+}
+
+// Requests will return the number of requests received by an endpoint
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// find will return the index of a hash in the catalogue, -1 when missing
+func (s *Server) find(hash string) int {
+	for i := range s.torrents {
+		if strings.EqualFold(s.torrents[i].Hash, hash) {
+			return i
+		}
+	}
+	return -1
+}
+
+// fault will return the next fault of an endpoint, an empty one without
+// fault
+func (s *Server) fault(path string) Fault {
+	faults := s.faults[path]
+	if len(faults) == 0 {
+		return Fault{}
+	}
+	f := faults[0]
+	if f.Times > 0 {
+		faults[0].Times--
+		if faults[0].Times == 0 {
+			s.faults[path] = faults[1:]
+		}
+	}
+	return f
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if strings.HasPrefix(path, PathFiles) {
+		path = PathFiles
+	}
+
+	s.mu.Lock()
+	s.requests[path]++
+	f := s.fault(path)
+	latency := s.latency + f.Latency
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case f.Drop:
+		drop(w)
+		return
+	case f.HTTPStatus != 0:
+		w.WriteHeader(f.HTTPStatus)
+		return
+	case f.Body != "":
+		fmt.Fprint(w, f.Body)
+		return
+	case f.Status != 0:
+		writeJSON(w, status{Status: f.Status, Message: f.Message})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	switch path {
+	case PathSearch:
+		s.search(w, query.Get("phrase"), query.Get("category"), query.Get("subcategory"))
+	case PathInfo:
+		s.info(w, query.Get("hashes"))
+	case PathTop:
+		s.top(w, query.Get("category"))
+	case PathCount:
+		writeJSON(w, count{Status: 200, Message: len(s.torrents)})
+	case PathDescriptions:
+		if s.find(query.Get("hash")) < 0 {
+			writeJSON(w, status{Status: 404, Message: "Torrent not found"})
+			return
+		}
+		description := s.descriptions[strings.ToUpper(query.Get("hash"))]
+		writeJSON(w, status{Status: 200, Message: base64.StdEncoding.EncodeToString([]byte(description))})
+	case PathDownload:
+		i := s.find(query.Get("hash"))
+		if i < 0 {
+			writeJSON(w, status{Status: 404, Message: "Torrent not found"})
+			return
+		}
+		writeJSON(w, status{Status: 200, Message: fmt.Sprintf("%s%s%s.torrent", s.URL, PathFiles, strings.ToUpper(s.torrents[i].Hash))})
+	case PathFiles:
+		s.file(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// search will answer the torrents holding every word of the phrase, in the
+// category and the subcategory when given
+func (s *Server) search(w http.ResponseWriter, phrase, category, subCategory string) {
+	words := strings.Fields(strings.ToLower(phrase))
+	if len(words) == 0 {
+		writeJSON(w, status{Status: 400, Message: "Missing phrase"})
+		return
+	}
+
+	torrents := []strikeapi.Torrent{}
+	for _, t := range s.torrents {
+		title := strings.ToLower(t.Title)
+		matches := true
+		for _, word := range words {
+			matches = matches && strings.Contains(title, word)
+		}
+		if matches && inCategory(&t, category, subCategory) {
+			torrents = append(torrents, t)
+		}
+	}
+	writeTorrents(w, torrents)
+}
+
+// info will answer the torrents of comma separated hashes
+func (s *Server) info(w http.ResponseWriter, hashes string) {
+	torrents := []strikeapi.Torrent{}
+	for _, hash := range strings.Split(hashes, ",") {
+		if i := s.find(strings.TrimSpace(hash)); i >= 0 {
+			torrents = append(torrents, s.torrents[i])
+		}
+	}
+	writeTorrents(w, torrents)
+}
+
+// top will answer the torrents with the most seeds of a category, or of
+// every category for "all"
+func (s *Server) top(w http.ResponseWriter, category string) {
+	if strings.EqualFold(category, "all") {
+		category = ""
+	}
+	torrents := []strikeapi.Torrent{}
+	for _, t := range s.torrents {
+		if inCategory(&t, category, "") {
+			torrents = append(torrents, t)
+		}
+	}
+	sort.SliceStable(torrents, func(i, j int) bool {
+		return torrents[i].Seeds > torrents[j].Seeds
+	})
+	if len(torrents) > MaxTopTorrents {
+		torrents = torrents[:MaxTopTorrents]
+	}
+	writeTorrents(w, torrents)
+}
+
+// file will serve the .torrent file of a hash
+func (s *Server) file(w http.ResponseWriter, r *http.Request) {
+	hash := strings.ToUpper(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, PathFiles), ".torrent"))
+	i := s.find(hash)
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	data, ok := s.files[hash]
+	if !ok {
+		name := s.torrents[i].Title
+		data = []byte(fmt.Sprintf("d4:infod4:name%d:%see", len(name), name))
+	}
+	w.Header().Set("Content-Type", "application/x-bittorrent")
+	w.Write(data)
+}
+
+func inCategory(t *strikeapi.Torrent, category, subCategory string) bool {
+	return (category == "" || strings.EqualFold(t.Category, category)) &&
+		(subCategory == "" || strings.EqualFold(t.SubCategory, subCategory))
+}
+
+// status is the envelope of the answers with a message
+type status struct {
+	Status  int    `json:"statuscode"`
+	Message string `json:"message,omitempty"`
+}
+
+// count is the envelope of the count answer
+type count struct {
+	Status  int `json:"statuscode"`
+	Message int `json:"message"`
+}
+
+// writeTorrents will answer torrents, the API answers a 404 statuscode
+// without torrents
+func writeTorrents(w http.ResponseWriter, torrents []strikeapi.Torrent) {
+	response := strikeapi.Response{ResultSize: len(torrents), Status: 200, ResponseTime: 0.001, Torrents: torrents}
+	if len(torrents) == 0 {
+		response.Status = 404
+	}
+	writeJSON(w, response)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Couldn't encode the answer : ", err)
+	}
+}
+
+// drop will close the connection after the beginning of an answer
+func drop(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic("strikeapitest: the connection can't be dropped")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		log.Println("Couldn't drop the connection : ", err)
+		return
+	}
+	defer conn.Close()
+	buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"results\":")
+	buf.Flush()
+}
@@ -0,0 +1,191 @@
+package strikeapitest
+
+import (
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+var testTorrents = []strikeapi.Torrent{
+	{
+		Title:    "Slackware 14.1 x86_64 DVD ISO",
+		Hash:     "156B69B8643BD11849A5D8F2122E13FBB61BD041",
+		Category: strikeapi.Applications,
+		Seeds:    192,
+		Size:     2437393940,
+		FilesInfo: &strikeapi.FilesInfo{FileInfo: []strikeapi.FileInfo{
+			{FileName: "slackware64-14.1-install-dvd.iso", FileSize: 2437393940},
+		}},
+	},
+	{
+		Title:       "Arch Linux 2015.01.01 (x86/x64)",
+		Hash:        "B425907E5755031BDA4A8D1B6DCCACA97DA14C04",
+		Category:    strikeapi.Applications,
+		SubCategory: strikeapi.Linux,
+		Seeds:       645,
+	},
+	{
+		Title:       "Marvel NOW",
+		Hash:        "6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088",
+		Category:    strikeapi.Books,
+		SubCategory: strikeapi.Comics,
+		Seeds:       790,
+	},
+}
+
+func hashes(torrents []strikeapi.Torrent) []string {
+	h := []string{}
+	for _, t := range torrents {
+		h = append(h, t.Hash[:4])
+	}
+	return h
+}
+
+func TestEndpoints(t *testing.T) {
+	s := NewServer(testTorrents...)
+	defer s.Close()
+	defer s.Use()()
+	// The answers match the schemas of the real API
+	strikeapi.SchemaValidation = strikeapi.ValidateStrict
+	defer func() { strikeapi.SchemaValidation = strikeapi.ValidateOff }()
+
+	tests := []struct {
+		name     string
+		search   func() ([]strikeapi.Torrent, error)
+		expected []string
+	}{
+		{"search", func() ([]strikeapi.Torrent, error) { return strikeapi.Search("linux") }, []string{"B425"}},
+		{"words", func() ([]strikeapi.Torrent, error) { return strikeapi.Search("DVD slackware") }, []string{"156B"}},
+		{"category", func() ([]strikeapi.Torrent, error) { return strikeapi.SearchWithCategory("a", strikeapi.Books) }, []string{"6C32"}},
+		{"subcategory", func() ([]strikeapi.Torrent, error) {
+			return strikeapi.SearchWithCategoryAndSubCategory("a", strikeapi.Applications, strikeapi.Linux)
+		}, []string{"B425"}},
+		{"not found", func() ([]strikeapi.Torrent, error) { return strikeapi.Search("windows") }, []string{}},
+		{"info", func() ([]strikeapi.Torrent, error) {
+			return strikeapi.GetTorrentsInfos([]string{"6c32b66cee44b7a0e3e42e22acf5e77bf3218088", "156B69B8643BD11849A5D8F2122E13FBB61BD041", "0000"})
+		}, []string{"6C32", "156B"}},
+		{"top", func() ([]strikeapi.Torrent, error) { return strikeapi.GetTopTorrents("") }, []string{"6C32", "B425", "156B"}},
+		{"top category", func() ([]strikeapi.Torrent, error) { return strikeapi.GetTopTorrents(strikeapi.Applications) }, []string{"B425", "156B"}},
+	}
+	for _, test := range tests {
+		torrents, err := test.search()
+		if err != nil || !reflect.DeepEqual(hashes(torrents), test.expected) {
+			t.Errorf("%s : expected %v, got %v %v", test.name, test.expected, hashes(torrents), err)
+		}
+	}
+
+	torrent, err := strikeapi.GetTorrentInfos("156B69B8643BD11849A5D8F2122E13FBB61BD041")
+	if err != nil || !reflect.DeepEqual(*torrent, testTorrents[0]) {
+		t.Errorf("Bad torrent %+v %v", torrent, err)
+	}
+
+	if count, err := strikeapi.CountTorrents(); err != nil || count != 3 {
+		t.Errorf("Bad count %d %v", count, err)
+	}
+
+	s.SetDescription("B425907E5755031BDA4A8D1B6DCCACA97DA14C04", "Arch Linux")
+	if description, err := strikeapi.GetDescription("b425907e5755031bda4a8d1b6dccaca97da14c04"); err != nil || description != "Arch Linux" {
+		t.Errorf("Bad description %q %v", description, err)
+	}
+	if _, err := strikeapi.GetDescription("0000"); !isStatus(err, 404) {
+		t.Errorf("Should get a 404 APIError, got %v", err)
+	}
+
+	data, err := strikeapi.GetTorrentFile("6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088")
+	if err != nil || string(data) != "d4:infod4:name10:Marvel NOWee" {
+		t.Errorf("Bad torrent file %q %v", data, err)
+	}
+	s.SetTorrentFile("6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088", []byte("d8:announce0:e"))
+	if data, err = strikeapi.GetTorrentFile("6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088"); err != nil || string(data) != "d8:announce0:e" {
+		t.Errorf("Bad torrent file %q %v", data, err)
+	}
+	if _, err := strikeapi.GetDownloadLink("0000"); !isStatus(err, 404) {
+		t.Errorf("Should get a 404 APIError, got %v", err)
+	}
+
+	// The catalogue can change
+	s.Remove("156B69B8643BD11849A5D8F2122E13FBB61BD041")
+	updated := testTorrents[1]
+	updated.Seeds = 1
+	s.Add(updated)
+	if torrents, _ := strikeapi.GetTopTorrents("all"); !reflect.DeepEqual(hashes(torrents), []string{"6C32", "B425"}) || torrents[1].Seeds != 1 {
+		t.Errorf("Bad catalogue %+v", torrents)
+	}
+	if s.Requests(PathTop) != 3 || s.Requests(PathFiles) != 2 {
+		t.Errorf("Bad request counts %d %d", s.Requests(PathTop), s.Requests(PathFiles))
+	}
+}
+
+func isStatus(err error, status int) bool {
+	e, ok := err.(*strikeapi.APIError)
+	return ok && e.Status == status
+}
+
+func TestFaults(t *testing.T) {
+	s := NewServer(testTorrents...)
+	defer s.Close()
+	defer s.Use()()
+
+	s.Fail(PathSearch, Fault{Status: 500, Message: "Internal error", Times: 1})
+	s.Fail(PathSearch, Fault{Body: `{"results":1,"torrents":[`, Times: 1})
+	s.Fail(PathSearch, Fault{HTTPStatus: http.StatusBadGateway, Times: 1})
+	s.Fail(PathSearch, Fault{Drop: true, Times: 1})
+
+	if _, err := strikeapi.Search("linux"); !isStatus(err, 500) {
+		t.Errorf("Should get a 500 APIError, got %v", err)
+	}
+	if _, err := strikeapi.Search("linux"); err == nil {
+		t.Error("Should fail to decode a malformed body")
+	}
+	if _, err := strikeapi.Search("linux"); err == nil {
+		t.Error("Should fail on an HTTP error")
+	}
+	if _, err := strikeapi.Search("linux"); err == nil {
+		t.Error("Should fail on a dropped connection")
+	}
+	// The faults are used up
+	if torrents, err := strikeapi.Search("linux"); err != nil || len(torrents) != 1 {
+		t.Errorf("Should search after the faults, got %v %v", torrents, err)
+	}
+
+	// The faults without Times last until healed, other endpoints still work
+	s.Fail(PathCount, Fault{Status: 503})
+	for i := 0; i < 2; i++ {
+		if _, err := strikeapi.CountTorrents(); !isStatus(err, 503) {
+			t.Errorf("Should get a 503 APIError, got %v", err)
+		}
+	}
+	if _, err := strikeapi.Search("linux"); err != nil {
+		t.Errorf("Should search, got %v", err)
+	}
+	s.Heal()
+	if _, err := strikeapi.CountTorrents(); err != nil {
+		t.Errorf("Should count after healing, got %v", err)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	s := NewServer(testTorrents...)
+	defer s.Close()
+	defer s.Use()()
+
+	client := strikeapi.HTTPClient
+	strikeapi.HTTPClient = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() { strikeapi.HTTPClient = client }()
+
+	s.Fail(PathTop, Fault{Latency: time.Second, Times: 1})
+	_, err := strikeapi.GetTopTorrents("")
+	if e, ok := err.(net.Error); !ok || !e.Timeout() {
+		t.Errorf("Should time out, got %v", err)
+	}
+
+	s.SetLatency(10 * time.Millisecond)
+	start := time.Now()
+	if _, err := strikeapi.GetTopTorrents(""); err != nil || time.Since(start) < 10*time.Millisecond {
+		t.Errorf("Should answer after the latency, got %v in %s", err, time.Since(start))
+	}
+}
@@ -0,0 +1,99 @@
+package torznab
+
+import (
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Category represents a Newznab category and the Strike category and
+// subcategory it maps to
+type Category struct {
+	ID          int
+	Name        string
+	Category    string
+	SubCategory string
+}
+
+// Categories maps the Strike categories and subcategories to Newznab
+// category IDs, parents are listed before their subcategories. The IDs
+// without a Strike subcategory, like TV/HD, stand for their whole category.
+var Categories = []Category{
+	{1000, "Console", strikeapi.Games, ""},
+	{1010, "Console/NDS", strikeapi.Games, strikeapi.Handheld},
+	{1020, "Console/PSP", strikeapi.Games, strikeapi.PSP},
+	{1030, "Console/Wii", strikeapi.Games, strikeapi.Wii},
+	{1050, "Console/XBox 360", strikeapi.Games, strikeapi.XBOX360},
+	{1080, "Console/PS3", strikeapi.Games, strikeapi.PS3},
+	{1090, "Console/Other", strikeapi.Games, strikeapi.PS2},
+	{2000, "Movies", strikeapi.Movies, ""},
+	{2010, "Movies/Foreign", strikeapi.Movies, strikeapi.DubbedMovies},
+	{2020, "Movies/Other", strikeapi.Movies, strikeapi.OtherMovies},
+	{2040, "Movies/HD", strikeapi.Movies, strikeapi.HighresMovies},
+	{2045, "Movies/UHD", strikeapi.Movies, strikeapi.UltraHD},
+	{2060, "Movies/3D", strikeapi.Movies, strikeapi.Movies3D},
+	{3000, "Audio", strikeapi.Music, ""},
+	{3010, "Audio/MP3", strikeapi.Music, strikeapi.Mp3},
+	{3020, "Audio/Video", strikeapi.Music, strikeapi.MusicVideos},
+	{3030, "Audio/Audiobook", strikeapi.Books, strikeapi.AudioBooks},
+	{3040, "Audio/Lossless", strikeapi.Music, strikeapi.Lossless},
+	{3050, "Audio/Other", strikeapi.Music, strikeapi.OtherMusic},
+	{4000, "PC", strikeapi.Applications, ""},
+	{4010, "PC/0day", strikeapi.Applications, strikeapi.Windows},
+	{4030, "PC/Mac", strikeapi.Applications, strikeapi.Mac},
+	{4040, "PC/Mobile-Other", strikeapi.Applications, strikeapi.OtherApplications},
+	{4050, "PC/Games", strikeapi.Games, strikeapi.PC},
+	{4060, "PC/Mobile-iOS", strikeapi.Applications, "iOS"},
+	{4070, "PC/Mobile-Android", strikeapi.Applications, strikeapi.Android},
+	{5000, "TV", strikeapi.TV, ""},
+	{5030, "TV/SD", strikeapi.TV, ""},
+	{5040, "TV/HD", strikeapi.TV, ""},
+	{5050, "TV/Other", strikeapi.TV, strikeapi.OtherTV},
+	{5070, "TV/Anime", strikeapi.Anime, ""},
+	{5080, "TV/Documentary", strikeapi.TV, strikeapi.Documentary},
+	{6000, "XXX", strikeapi.XXX, ""},
+	{6070, "XXX/Other", strikeapi.XXX, strikeapi.OtherXXX},
+	{7000, "Books", strikeapi.Books, ""},
+	{7010, "Books/Mags", strikeapi.Books, strikeapi.Magazines},
+	{7020, "Books/EBook", strikeapi.Books, strikeapi.Ebooks},
+	{7030, "Books/Comics", strikeapi.Books, strikeapi.Comics},
+	{7040, "Books/Technical", strikeapi.Books, strikeapi.Textbooks},
+	{7050, "Books/Other", strikeapi.Books, strikeapi.OtherBooks},
+	{8000, "Other", strikeapi.Other, ""},
+	{8010, "Other/Misc", strikeapi.Other, strikeapi.Unsorted},
+}
+
+// CategoryByID will return the Category of a Newznab ID
+func CategoryByID(id int) (Category, bool) {
+	for _, c := range Categories {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Category{}, false
+}
+
+// CategoryIDs will return the Newznab IDs of a Torrent, its category first
+// and its subcategory if it has a mapping
+func CategoryIDs(t *strikeapi.Torrent) []int {
+	parent, sub := 0, 0
+	for _, c := range Categories {
+		if c.Category != t.Category {
+			continue
+		}
+		if c.SubCategory == "" && parent == 0 {
+			parent = c.ID
+		}
+		if c.SubCategory != "" && c.SubCategory == t.SubCategory && sub == 0 {
+			sub = c.ID
+		}
+	}
+
+	// Unknown categories go to Other
+	if parent == 0 {
+		parent = 8000
+	}
+	ids := []int{parent}
+	if sub != 0 {
+		ids = append(ids, sub)
+	}
+	return ids
+}
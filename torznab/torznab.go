@@ -0,0 +1,352 @@
+// Package torznab exposes the Strike API as a Torznab indexer, the protocol
+// spoken by Sonarr, Radarr and similar tools.
+package torznab
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Newznab error codes
+const (
+	ErrCodeCredentials    = 100
+	ErrCodeMissingParam   = 200
+	ErrCodeNoSuchFunction = 202
+	ErrCodeUnknown        = 900
+)
+
+// Namespace is the XML namespace of the torznab attributes
+const Namespace = "http://torznab.com/schemas/2015/feed"
+
+// Handler serves the Torznab API, it must be mounted on /api
+type Handler struct {
+	// APIKey is required in the apikey parameter when not empty
+	APIKey string
+	// Title is the name of the indexer given in the caps and feeds
+	Title string
+}
+
+// Error represents a Newznab error response
+type Error struct {
+	XMLName     xml.Name `xml:"error"`
+	Code        int      `xml:"code,attr"`
+	Description string   `xml:"description,attr"`
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if h.APIKey != "" && query.Get("apikey") != h.APIKey {
+		writeXML(w, &Error{Code: ErrCodeCredentials, Description: "Incorrect user credentials"})
+		return
+	}
+
+	switch query.Get("t") {
+	case "caps":
+		writeXML(w, h.caps())
+	case "search":
+		h.search(w, query.Get("q"), query)
+	case "tvsearch":
+		phrase := query.Get("q")
+		season, episode := query.Get("season"), query.Get("ep")
+		if season != "" {
+			phrase = strings.TrimSpace(fmt.Sprintf("%s %s", phrase, episodeTag(season, episode)))
+		}
+		h.search(w, phrase, query)
+	case "movie":
+		h.search(w, query.Get("q"), query)
+	case "":
+		writeXML(w, &Error{Code: ErrCodeMissingParam, Description: "Missing parameter (t)"})
+	default:
+		writeXML(w, &Error{Code: ErrCodeNoSuchFunction, Description: "No such function"})
+	}
+}
+
+// episodeTag will format a season and an optional episode as S01E02
+func episodeTag(season, episode string) string {
+	tag := season
+	if n, err := strconv.Atoi(season); err == nil {
+		tag = fmt.Sprintf("S%02d", n)
+	}
+	if n, err := strconv.Atoi(episode); err == nil {
+		tag += fmt.Sprintf("E%02d", n)
+	}
+	return tag
+}
+
+// search will run a search, or get the top torrents without phrase, and
+// write the results as a Torznab feed
+func (h *Handler) search(w http.ResponseWriter, phrase string, query map[string][]string) {
+	cats := parseCategories(first(query["cat"]))
+	category, subCategory := strikeCategory(cats)
+
+	var torrents []strikeapi.Torrent
+	var err error
+	if phrase == "" {
+		torrents, err = strikeapi.GetTopTorrents(category)
+	} else {
+		torrents, err = strikeapi.SearchWithCategoryAndSubCategory(phrase, category, subCategory)
+	}
+	if err != nil {
+		log.Println("Couldn't search : ", err)
+		writeXML(w, &Error{Code: ErrCodeUnknown, Description: err.Error()})
+		return
+	}
+
+	torrents = filterCategories(torrents, cats)
+	torrents = paginate(torrents, first(query["offset"]), first(query["limit"]))
+
+	writeXML(w, h.feed(torrents))
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// parseCategories will parse a comma separated list of Newznab IDs
+func parseCategories(cat string) []int {
+	ids := []int{}
+	for _, s := range strings.Split(cat, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// strikeCategory will return the Strike category and subcategory to search
+// in, they are only set when every requested ID shares them. An ID without a
+// Strike subcategory, like TV/HD, searches its whole category.
+func strikeCategory(ids []int) (string, string) {
+	category, subCategory := "", ""
+	for i, id := range ids {
+		c, ok := CategoryByID(id)
+		if !ok {
+			return "", ""
+		}
+		if i == 0 {
+			category, subCategory = c.Category, c.SubCategory
+			continue
+		}
+		if c.Category != category {
+			return "", ""
+		}
+		if c.SubCategory != subCategory {
+			subCategory = ""
+		}
+	}
+	return category, subCategory
+}
+
+// filterCategories will keep the torrents matching one of the IDs, an ID
+// without a Strike subcategory matches its whole category
+func filterCategories(torrents []strikeapi.Torrent, ids []int) []strikeapi.Torrent {
+	if len(ids) == 0 {
+		return torrents
+	}
+	wanted := map[int]bool{}
+	whole := map[string]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+		if c, ok := CategoryByID(id); ok && c.SubCategory == "" {
+			whole[c.Category] = true
+		}
+	}
+
+	filtered := []strikeapi.Torrent{}
+	for _, t := range torrents {
+		if whole[t.Category] {
+			filtered = append(filtered, t)
+			continue
+		}
+		for _, id := range CategoryIDs(&t) {
+			if wanted[id] {
+				filtered = append(filtered, t)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func paginate(torrents []strikeapi.Torrent, offset, limit string) []strikeapi.Torrent {
+	if o, err := strconv.Atoi(offset); err == nil && o > 0 {
+		if o >= len(torrents) {
+			return []strikeapi.Torrent{}
+		}
+		torrents = torrents[o:]
+	}
+	if l, err := strconv.Atoi(limit); err == nil && l >= 0 && l < len(torrents) {
+		torrents = torrents[:l]
+	}
+	return torrents
+}
+
+type caps struct {
+	XMLName    xml.Name       `xml:"caps"`
+	Server     capsServer     `xml:"server"`
+	Limits     capsLimits     `xml:"limits"`
+	Searching  capsSearching  `xml:"searching"`
+	Categories []capsCategory `xml:"categories>category"`
+}
+
+type capsServer struct {
+	Title string `xml:"title,attr"`
+}
+
+type capsLimits struct {
+	Max     int `xml:"max,attr"`
+	Default int `xml:"default,attr"`
+}
+
+type capsSearch struct {
+	Available       string `xml:"available,attr"`
+	SupportedParams string `xml:"supportedParams,attr"`
+}
+
+type capsSearching struct {
+	Search      capsSearch `xml:"search"`
+	TVSearch    capsSearch `xml:"tv-search"`
+	MovieSearch capsSearch `xml:"movie-search"`
+}
+
+type capsCategory struct {
+	ID      int            `xml:"id,attr"`
+	Name    string         `xml:"name,attr"`
+	Subcats []capsCategory `xml:"subcat,omitempty"`
+}
+
+func (h *Handler) caps() *caps {
+	c := &caps{
+		Server: capsServer{Title: h.title()},
+		Limits: capsLimits{Max: 100, Default: 100},
+		Searching: capsSearching{
+			Search:      capsSearch{"yes", "q"},
+			TVSearch:    capsSearch{"yes", "q,season,ep"},
+			MovieSearch: capsSearch{"yes", "q"},
+		},
+	}
+
+	// Subcategories are grouped under their Newznab parent
+	for _, category := range Categories {
+		if category.ID%1000 == 0 {
+			c.Categories = append(c.Categories, capsCategory{ID: category.ID, Name: category.Name})
+			continue
+		}
+		for i := range c.Categories {
+			if c.Categories[i].ID == category.ID/1000*1000 {
+				c.Categories[i].Subcats = append(c.Categories[i].Subcats, capsCategory{ID: category.ID, Name: category.Name})
+			}
+		}
+	}
+	return c
+}
+
+type rss struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Torznab string   `xml:"xmlns:torznab,attr"`
+	Channel channel  `xml:"channel"`
+}
+
+type channel struct {
+	Title       string `xml:"title"`
+	Description string `xml:"description"`
+	Items       []item `xml:"item"`
+}
+
+type item struct {
+	Title      string    `xml:"title"`
+	GUID       string    `xml:"guid"`
+	Link       string    `xml:"link"`
+	Comments   string    `xml:"comments,omitempty"`
+	PubDate    string    `xml:"pubDate,omitempty"`
+	Size       int64     `xml:"size"`
+	Categories []int     `xml:"category"`
+	Enclosure  enclosure `xml:"enclosure"`
+	Attrs      []attr    `xml:"torznab:attr"`
+}
+
+type enclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type attr struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+func (h *Handler) feed(torrents []strikeapi.Torrent) *rss {
+	feed := &rss{
+		Version: "2.0",
+		Torznab: Namespace,
+		Channel: channel{Title: h.title(), Description: "Strike API Torznab feed"},
+	}
+	for _, t := range torrents {
+		feed.Channel.Items = append(feed.Channel.Items, newItem(&t))
+	}
+	return feed
+}
+
+// newItem will translate a Torrent into a Torznab item
+func newItem(t *strikeapi.Torrent) item {
+	size := int64(t.Size)
+	i := item{
+		Title:      t.Title,
+		GUID:       t.Hash,
+		Link:       t.MagnetURI,
+		Comments:   t.Page,
+		Size:       size,
+		Categories: CategoryIDs(t),
+		Enclosure:  enclosure{URL: t.MagnetURI, Length: size, Type: "application/x-bittorrent"},
+	}
+	if uploadTime, err := t.UploadTime(); err == nil {
+		i.PubDate = uploadTime.Format(time.RFC1123Z)
+	}
+
+	for _, id := range i.Categories {
+		i.Attrs = append(i.Attrs, attr{"category", strconv.Itoa(id)})
+	}
+	i.Attrs = append(i.Attrs,
+		attr{"size", strconv.FormatInt(size, 10)},
+		attr{"seeders", strconv.Itoa(t.Seeds)},
+		attr{"peers", strconv.Itoa(t.Seeds + t.Leeches)},
+		attr{"infohash", t.Hash},
+		attr{"magneturl", t.MagnetURI},
+	)
+	if t.FileCount > 0 {
+		i.Attrs = append(i.Attrs, attr{"files", strconv.Itoa(t.FileCount)})
+	}
+	if t.DownloadCount > 0 {
+		i.Attrs = append(i.Attrs, attr{"grabs", strconv.Itoa(t.DownloadCount)})
+	}
+	return i
+}
+
+func (h *Handler) title() string {
+	if h.Title == "" {
+		return "Strike"
+	}
+	return h.Title
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	fmt.Fprint(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Couldn't encode the response : ", err)
+	}
+}
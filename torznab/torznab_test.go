@@ -0,0 +1,186 @@
+package torznab
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+const searchResponse = `{"results":2,"statuscode":200,"responsetime":0.4725,"torrents":[{"torrent_hash":"156B69B8643BD11849A5D8F2122E13FBB61BD041","torrent_title":"Slackware 14.1 x86_64 DVD ISO","torrent_category":"Applications","sub_category":"","seeds":192,"leeches":9,"file_count":4,"size":2437393940.48,"download_count":40,"upload_date":"Feb 24, 2014","uploader_username":"Nusantara","page":"https://getstrike.net/torrents/156B69B8643BD11849A5D8F2122E13FBB61BD041","magnet_uri":"magnet:?xt=urn:btih:156B69B8643BD11849A5D8F2122E13FBB61BD041"},{"torrent_hash":"6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088","torrent_title":"Marvel NOW","torrent_category":"Books","sub_category":"Comics","seeds":790,"leeches":458,"file_count":22,"size":905141288.96,"download_count":5,"upload_date":"Mar 25, 2015","uploader_username":"Nemesis44","magnet_uri":"magnet:?xt=urn:btih:6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088"}]}`
+
+// fakeStrike starts a fake Strike upstream recording the last query
+func fakeStrike(got *url.URL) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*got = *r.URL
+		fmt.Fprintln(w, searchResponse)
+	}))
+	strikeapi.APIEndpoint = ts.URL
+	return ts
+}
+
+type testFeed struct {
+	Items []struct {
+		Title      string `xml:"title"`
+		GUID       string `xml:"guid"`
+		PubDate    string `xml:"pubDate"`
+		Size       int64  `xml:"size"`
+		Categories []int  `xml:"category"`
+		Attrs      []attr `xml:"http://torznab.com/schemas/2015/feed attr"`
+	} `xml:"channel>item"`
+}
+
+func get(t *testing.T, h http.Handler, query string, v interface{}) {
+	req := httptest.NewRequest("GET", "/api?"+query, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if err := xml.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("Couldn't decode the answer : %s\n%s", err, w.Body.String())
+	}
+}
+
+func TestSearch(t *testing.T) {
+	var got url.URL
+	ts := fakeStrike(&got)
+	defer ts.Close()
+
+	feed := &testFeed{}
+	get(t, &Handler{}, "t=search&q=slackware", feed)
+
+	if got.Path != "/torrents/search/" || got.Query().Get("phrase") != "slackware" {
+		t.Errorf("Bad upstream query : %s", got.String())
+	}
+	if len(feed.Items) != 2 {
+		t.Fatalf("Should get 2 items, got %d", len(feed.Items))
+	}
+
+	item := feed.Items[0]
+	if item.Title != "Slackware 14.1 x86_64 DVD ISO" || item.GUID != "156B69B8643BD11849A5D8F2122E13FBB61BD041" {
+		t.Errorf("Bad item : %+v", item)
+	}
+	if item.PubDate != "Mon, 24 Feb 2014 00:00:00 +0000" || item.Size != 2437393940 {
+		t.Errorf("Bad item date or size : %+v", item)
+	}
+	expectedAttrs := []attr{
+		{"category", "4000"},
+		{"size", "2437393940"},
+		{"seeders", "192"},
+		{"peers", "201"},
+		{"infohash", "156B69B8643BD11849A5D8F2122E13FBB61BD041"},
+		{"magneturl", "magnet:?xt=urn:btih:156B69B8643BD11849A5D8F2122E13FBB61BD041"},
+		{"files", "4"},
+		{"grabs", "40"},
+	}
+	if !reflect.DeepEqual(item.Attrs, expectedAttrs) {
+		t.Errorf("Bad attributes : %+v", item.Attrs)
+	}
+	if !reflect.DeepEqual(feed.Items[1].Categories, []int{7000, 7030}) {
+		t.Errorf("Bad categories : %v", feed.Items[1].Categories)
+	}
+}
+
+func TestSearchCategory(t *testing.T) {
+	var got url.URL
+	ts := fakeStrike(&got)
+	defer ts.Close()
+
+	feed := &testFeed{}
+	get(t, &Handler{}, "t=search&q=marvel&cat=7030", feed)
+
+	if got.Query().Get("category") != strikeapi.Books || got.Query().Get("subcategory") != strikeapi.Comics {
+		t.Errorf("Bad upstream query : %s", got.String())
+	}
+	if len(feed.Items) != 1 || feed.Items[0].Title != "Marvel NOW" {
+		t.Errorf("Should only get the comics : %+v", feed.Items)
+	}
+}
+
+func TestTVSearch(t *testing.T) {
+	var got url.URL
+	ts := fakeStrike(&got)
+	defer ts.Close()
+
+	get(t, &Handler{}, "t=tvsearch&q=Doctor+Who&season=8&ep=1&limit=1", &testFeed{})
+	if got.Query().Get("phrase") != "Doctor Who S08E01" {
+		t.Errorf("Bad phrase : %q", got.Query().Get("phrase"))
+	}
+}
+
+func TestTVCategories(t *testing.T) {
+	var got url.URL
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = *r.URL
+		fmt.Fprintln(w, `{"results":3,"statuscode":200,"responsetime":0.01,"torrents":[`+
+			`{"torrent_hash":"A1","torrent_title":"Doctor Who S08E01 720p HDTV","torrent_category":"TV","sub_category":"","seeds":10,"leeches":1,"size":1},`+
+			`{"torrent_hash":"B2","torrent_title":"Doctor Who Documentary","torrent_category":"TV","sub_category":"Documentary","seeds":5,"leeches":1,"size":1},`+
+			`{"torrent_hash":"C3","torrent_title":"Doctor Who Anime","torrent_category":"Anime","sub_category":"","seeds":1,"leeches":1,"size":1}]}`)
+	}))
+	defer ts.Close()
+	strikeapi.APIEndpoint = ts.URL
+
+	// The categories requested by Sonarr by default
+	feed := &testFeed{}
+	get(t, &Handler{}, "t=tvsearch&q=Doctor+Who&cat=5030,5040", feed)
+
+	if got.Query().Get("category") != strikeapi.TV || got.Query().Get("subcategory") != "" {
+		t.Errorf("Bad upstream query : %s", got.String())
+	}
+	if len(feed.Items) != 2 || feed.Items[0].Title != "Doctor Who S08E01 720p HDTV" || feed.Items[1].Title != "Doctor Who Documentary" {
+		t.Errorf("Should get the TV torrents : %+v", feed.Items)
+	}
+}
+
+func TestRSSWithoutQuery(t *testing.T) {
+	var got url.URL
+	ts := fakeStrike(&got)
+	defer ts.Close()
+
+	feed := &testFeed{}
+	get(t, &Handler{}, "t=movie&limit=1", feed)
+	if got.Path != "/torrents/top/" || got.Query().Get("category") != "all" {
+		t.Errorf("Should get the top torrents : %s", got.String())
+	}
+	if len(feed.Items) != 1 {
+		t.Errorf("Should be limited to 1 item, got %d", len(feed.Items))
+	}
+}
+
+func TestCaps(t *testing.T) {
+	c := &caps{}
+	get(t, &Handler{Title: "strike"}, "t=caps", c)
+
+	if c.Server.Title != "strike" || c.Searching.TVSearch.SupportedParams != "q,season,ep" {
+		t.Errorf("Bad caps : %+v", c)
+	}
+	for _, category := range c.Categories {
+		if category.ID == 2000 {
+			if len(category.Subcats) != 5 || !strings.HasPrefix(category.Subcats[0].Name, "Movies/") {
+				t.Errorf("Bad movies subcategories : %+v", category.Subcats)
+			}
+			return
+		}
+	}
+	t.Errorf("Movies category missing")
+}
+
+func TestAPIKey(t *testing.T) {
+	h := &Handler{APIKey: "secret"}
+
+	e := &Error{}
+	get(t, h, "t=caps&apikey=wrong", e)
+	if e.Code != ErrCodeCredentials {
+		t.Errorf("Should get a credentials error : %+v", e)
+	}
+
+	e = &Error{}
+	get(t, h, "t=unknown&apikey=secret", e)
+	if e.Code != ErrCodeNoSuchFunction {
+		t.Errorf("Should get a no such function error : %+v", e)
+	}
+}
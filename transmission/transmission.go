@@ -0,0 +1,232 @@
+// Package transmission sends torrents found with the Strike API to a
+// Transmission client through its JSON-RPC interface.
+package transmission
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// SessionIDHeader is the header used by Transmission for CSRF protection
+const SessionIDHeader = "X-Transmission-Session-Id"
+
+// Custom errors
+var (
+	ErrNoSource = errors.New("torrent has neither a magnet nor a hash")
+)
+
+// Client represents a Transmission RPC endpoint
+type Client struct {
+	// URL of the RPC endpoint, e.g. http://localhost:9091/transmission/rpc
+	URL      string
+	Username string
+	Password string
+
+	// DownloadDir is the directory where the torrents will be downloaded,
+	// Transmission's default is used when empty
+	DownloadDir string
+	// Labels are attached to every added torrent
+	Labels []string
+	// Paused adds the torrents without starting them
+	Paused bool
+
+	HTTPClient *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+// Added represents a torrent added to Transmission
+type Added struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Hash      string `json:"hashString"`
+	Duplicate bool   `json:"-"`
+}
+
+// Error represents a failed RPC call
+type Error struct {
+	Result string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("transmission: %s", e.Result)
+}
+
+type request struct {
+	Method    string      `json:"method"`
+	Arguments interface{} `json:"arguments,omitempty"`
+}
+
+type response struct {
+	Result    string          `json:"result"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type addArguments struct {
+	Filename    string   `json:"filename,omitempty"`
+	Metainfo    string   `json:"metainfo,omitempty"`
+	DownloadDir string   `json:"download-dir,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Paused      bool     `json:"paused,omitempty"`
+}
+
+type addResult struct {
+	Added     *Added `json:"torrent-added"`
+	Duplicate *Added `json:"torrent-duplicate"`
+}
+
+// New returns a Client for the given RPC URL
+func New(url string) *Client {
+	return &Client{URL: url}
+}
+
+// Add will add a Torrent to Transmission, using its magnet when available
+// and its .torrent file otherwise
+func (c *Client) Add(t *strikeapi.Torrent) (*Added, error) {
+	if t.MagnetURI != "" {
+		return c.AddMagnet(t.MagnetURI)
+	}
+	if t.Hash == "" {
+		return nil, ErrNoSource
+	}
+
+	metainfo, err := t.GetTorrentFile()
+	if err != nil {
+		return nil, err
+	}
+	return c.AddMetainfo(metainfo)
+}
+
+// Send will add a Torrent to Transmission, it implements strikeapi.Sink
+func (c *Client) Send(t *strikeapi.Torrent) error {
+	added, err := c.Add(t)
+	if err != nil {
+		return err
+	}
+	if added.Duplicate {
+		return strikeapi.ErrDuplicate
+	}
+	return nil
+}
+
+// AddMagnet will add a torrent from a magnet URI
+func (c *Client) AddMagnet(magnet string) (*Added, error) {
+	return c.add(addArguments{Filename: magnet})
+}
+
+// AddMetainfo will add a torrent from the content of a .torrent file
+func (c *Client) AddMetainfo(metainfo []byte) (*Added, error) {
+	return c.add(addArguments{Metainfo: base64.StdEncoding.EncodeToString(metainfo)})
+}
+
+func (c *Client) add(args addArguments) (*Added, error) {
+	args.DownloadDir = c.DownloadDir
+	args.Labels = c.Labels
+	args.Paused = c.Paused
+
+	result := &addResult{}
+	if err := c.call("torrent-add", args, result); err != nil {
+		return nil, err
+	}
+
+	if result.Duplicate != nil {
+		result.Duplicate.Duplicate = true
+		return result.Duplicate, nil
+	}
+	if result.Added == nil {
+		return nil, &Error{Result: "no torrent in the response"}
+	}
+	return result.Added, nil
+}
+
+// call will make an RPC call and decode its arguments into result
+func (c *Client) call(method string, args interface{}, result interface{}) error {
+	body, err := json.Marshal(request{Method: method, Arguments: args})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.post(body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Transmission answers 409 with a new session id which must be sent back
+	if resp.StatusCode == http.StatusConflict {
+		resp.Body.Close()
+		c.setSessionID(resp.Header.Get(SessionIDHeader))
+
+		resp, err = c.post(body)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("transmission: unexpected status %s", resp.Status)
+	}
+
+	response := &response{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		log.Println("Couln't unmarshall result : ", err)
+		return err
+	}
+	if response.Result != "success" {
+		return &Error{Result: response.Result}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Arguments, result)
+}
+
+func (c *Client) post(body []byte) (*http.Response, error) {
+	req, err := http.NewRequest("POST", c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if id := c.getSessionID(); id != "" {
+		req.Header.Set(SessionIDHeader, id)
+	}
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		log.Println("Counldn't make the POST ", err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) getSessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID
+}
+
+func (c *Client) setSessionID(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessionID = id
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
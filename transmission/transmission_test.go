@@ -0,0 +1,116 @@
+package transmission
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+const testMagnet = "magnet:?xt=urn:btih:156B69B8643BD11849A5D8F2122E13FBB61BD041&dn=Slackware+14.1+x86_64+DVD+ISO"
+
+// fakeRPC returns a fake Transmission endpoint requiring a session id and
+// recording the arguments of the last call
+func fakeRPC(t *testing.T, answer string, got *map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SessionIDHeader) != "session-42" {
+			w.Header().Set(SessionIDHeader, "session-42")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		var req struct {
+			Method    string                 `json:"method"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Couldn't decode the request")
+		}
+		if req.Method != "torrent-add" {
+			t.Errorf("Bad method %q", req.Method)
+		}
+		*got = req.Arguments
+		fmt.Fprintln(w, answer)
+	}))
+}
+
+func TestAddMagnet(t *testing.T) {
+	var got map[string]interface{}
+	ts := fakeRPC(t, `{"result":"success","arguments":{"torrent-added":{"id":3,"name":"Slackware 14.1 x86_64 DVD ISO","hashString":"156b69b8643bd11849a5d8f2122e13fbb61bd041"}}}`, &got)
+	defer ts.Close()
+
+	c := New(ts.URL)
+	c.DownloadDir = "/data/iso"
+	c.Labels = []string{"strike", "linux"}
+
+	added, err := c.Add(&strikeapi.Torrent{MagnetURI: testMagnet})
+	if err != nil {
+		t.Fatalf("Error adding the torrent : %s", err)
+	}
+
+	expected := &Added{ID: 3, Name: "Slackware 14.1 x86_64 DVD ISO", Hash: "156b69b8643bd11849a5d8f2122e13fbb61bd041"}
+	if !reflect.DeepEqual(added, expected) {
+		t.Errorf("Added torrent not properly set : %+v", added)
+	}
+
+	expectedArgs := map[string]interface{}{
+		"filename":     testMagnet,
+		"download-dir": "/data/iso",
+		"labels":       []interface{}{"strike", "linux"},
+	}
+	if !reflect.DeepEqual(got, expectedArgs) {
+		t.Errorf("Bad arguments sent : %+v", got)
+	}
+}
+
+func TestAddDuplicate(t *testing.T) {
+	var got map[string]interface{}
+	ts := fakeRPC(t, `{"result":"success","arguments":{"torrent-duplicate":{"id":1,"name":"Slackware","hashString":"156b69b8643bd11849a5d8f2122e13fbb61bd041"}}}`, &got)
+	defer ts.Close()
+
+	added, err := New(ts.URL).AddMagnet(testMagnet)
+	if err != nil {
+		t.Fatalf("Error adding the torrent : %s", err)
+	}
+	if !added.Duplicate || added.ID != 1 {
+		t.Errorf("Duplicate not reported : %+v", added)
+	}
+
+	var sink strikeapi.Sink = New(ts.URL)
+	if err := sink.Send(&strikeapi.Torrent{MagnetURI: testMagnet}); err != strikeapi.ErrDuplicate {
+		t.Errorf("Should get an ErrDuplicate")
+	}
+}
+
+func TestAddMetainfo(t *testing.T) {
+	var got map[string]interface{}
+	ts := fakeRPC(t, `{"result":"success","arguments":{"torrent-added":{"id":4,"name":"file","hashString":"abc"}}}`, &got)
+	defer ts.Close()
+
+	if _, err := New(ts.URL).AddMetainfo([]byte("d4:infod4:name4:fileee")); err != nil {
+		t.Fatalf("Error adding the torrent : %s", err)
+	}
+	if got["metainfo"] != "ZDQ6aW5mb2Q0Om5hbWU0OmZpbGVlZQ==" {
+		t.Errorf("Bad metainfo sent : %v", got["metainfo"])
+	}
+}
+
+func TestRPCError(t *testing.T) {
+	var got map[string]interface{}
+	ts := fakeRPC(t, `{"result":"invalid or corrupt torrent file","arguments":{}}`, &got)
+	defer ts.Close()
+
+	_, err := New(ts.URL).AddMagnet("magnet:?xt=broken")
+	if e, ok := err.(*Error); !ok || e.Result != "invalid or corrupt torrent file" {
+		t.Errorf("Should get an RPC error, got %v", err)
+	}
+}
+
+func TestAddWithoutSource(t *testing.T) {
+	if _, err := New("http://localhost").Add(&strikeapi.Torrent{}); err != ErrNoSource {
+		t.Errorf("Should get an ErrNoSource")
+	}
+}
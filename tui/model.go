@@ -0,0 +1,366 @@
+// Package tui is an interactive terminal browser for Strike API searches.
+//
+// The Model holds the whole state and is only changed by Update, which
+// returns a Cmd for the work to do outside of it (searches, descriptions,
+// sending to a sink). View renders the Model as a string, so the package
+// can be driven without a real terminal.
+package tui
+
+import (
+	"fmt"
+	"sort"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Focus represents the part of the screen receiving the keys
+type Focus int
+
+// Focus values
+const (
+	FocusSearch Focus = iota
+	FocusList
+	FocusDetail
+)
+
+// SortKey represents the order of the result list
+type SortKey int
+
+// Sort keys, in the order they are cycled through
+const (
+	SortNone SortKey = iota
+	SortSeeds
+	SortSize
+	SortDate
+)
+
+func (s SortKey) String() string {
+	switch s {
+	case SortSeeds:
+		return "seeds"
+	case SortSize:
+		return "size"
+	case SortDate:
+		return "date"
+	}
+	return "relevance"
+}
+
+// Msg is a message handled by Update
+type Msg interface{}
+
+// Cmd is a function run outside of Update, its Msg is given back to Update
+type Cmd func() Msg
+
+// KeyMsg represents a key press, printable keys are given as is and the
+// others by name: enter, tab, esc, backspace, up, down, left, right, ctrl+c
+type KeyMsg string
+
+// ResizeMsg represents a new size of the terminal
+type ResizeMsg struct {
+	Width  int
+	Height int
+}
+
+// ResultsMsg holds the results of a search
+type ResultsMsg struct {
+	Torrents []strikeapi.Torrent
+	Err      error
+}
+
+// DescriptionMsg holds the description of a torrent
+type DescriptionMsg struct {
+	Hash        string
+	Description string
+	Err         error
+}
+
+// StatusMsg is displayed in the status line
+type StatusMsg string
+
+// Config holds the dependencies of the Model
+type Config struct {
+	// Search runs a search, strikeapi.Search by default
+	Search func(phrase string) ([]strikeapi.Torrent, error)
+	// Describe gets a description, strikeapi.GetDescription by default
+	Describe func(hash string) (string, error)
+	// Copy copies a text to the clipboard
+	Copy func(text string) error
+	// Sink receives the torrents sent with the send key, optional
+	Sink strikeapi.Sink
+}
+
+// Model represents the state of the interface
+type Model struct {
+	Focus        Focus
+	Query        string
+	Torrents     []strikeapi.Torrent
+	Cursor       int
+	Sort         SortKey
+	Descending   bool
+	Descriptions map[string]string
+	Status       string
+	Loading      bool
+	Width        int
+	Height       int
+	Quitting     bool
+
+	config       Config
+	listOffset   int
+	detailOffset int
+	// results keeps the order of the API, restored without sort key
+	results []strikeapi.Torrent
+}
+
+// New returns a Model with the search box focused
+func New(config Config) *Model {
+	if config.Search == nil {
+		config.Search = strikeapi.Search
+	}
+	if config.Describe == nil {
+		config.Describe = strikeapi.GetDescription
+	}
+	return &Model{
+		Descriptions: map[string]string{},
+		Descending:   true,
+		Width:        80,
+		Height:       24,
+		config:       config,
+	}
+}
+
+// Selected will return the torrent under the cursor
+func (m *Model) Selected() *strikeapi.Torrent {
+	if m.Cursor < 0 || m.Cursor >= len(m.Torrents) {
+		return nil
+	}
+	return &m.Torrents[m.Cursor]
+}
+
+// Update will apply a Msg to the Model
+func (m *Model) Update(msg Msg) Cmd {
+	switch msg := msg.(type) {
+	case ResizeMsg:
+		m.Width, m.Height = msg.Width, msg.Height
+	case ResultsMsg:
+		m.Loading = false
+		if msg.Err != nil {
+			m.Status = fmt.Sprintf("Search failed : %s", msg.Err)
+			return nil
+		}
+		m.results = msg.Torrents
+		m.Torrents = append([]strikeapi.Torrent(nil), msg.Torrents...)
+		m.Cursor, m.listOffset, m.detailOffset = 0, 0, 0
+		m.sortTorrents()
+		m.Status = fmt.Sprintf("%d results", len(m.Torrents))
+		if len(m.Torrents) > 0 {
+			m.Focus = FocusList
+		}
+	case DescriptionMsg:
+		if msg.Err != nil {
+			m.Status = fmt.Sprintf("Couldn't get the description : %s", msg.Err)
+			return nil
+		}
+		m.Descriptions[msg.Hash] = msg.Description
+	case StatusMsg:
+		m.Status = string(msg)
+	case KeyMsg:
+		return m.updateKey(msg)
+	}
+	return nil
+}
+
+func (m *Model) updateKey(key KeyMsg) Cmd {
+	if key == "ctrl+c" {
+		m.Quitting = true
+		return nil
+	}
+
+	switch m.Focus {
+	case FocusSearch:
+		return m.updateSearch(key)
+	case FocusDetail:
+		switch key {
+		case "up", "k":
+			if m.detailOffset > 0 {
+				m.detailOffset--
+			}
+			return nil
+		case "down", "j":
+			m.detailOffset++
+			return nil
+		case "esc", "tab", "left", "h":
+			m.Focus = FocusList
+			return nil
+		}
+	}
+	return m.updateList(key)
+}
+
+func (m *Model) updateSearch(key KeyMsg) Cmd {
+	switch key {
+	case "enter":
+		if m.Query == "" {
+			return nil
+		}
+		m.Loading = true
+		m.Status = fmt.Sprintf("Searching %q...", m.Query)
+		phrase, search := m.Query, m.config.Search
+		return func() Msg {
+			torrents, err := search(phrase)
+			return ResultsMsg{Torrents: torrents, Err: err}
+		}
+	case "backspace":
+		if r := []rune(m.Query); len(r) > 0 {
+			m.Query = string(r[:len(r)-1])
+		}
+	case "tab", "esc":
+		if len(m.Torrents) > 0 {
+			m.Focus = FocusList
+		}
+	default:
+		if r := []rune(string(key)); len(r) == 1 {
+			m.Query += string(key)
+		}
+	}
+	return nil
+}
+
+func (m *Model) updateList(key KeyMsg) Cmd {
+	switch key {
+	case "q":
+		m.Quitting = true
+	case "/":
+		m.Focus = FocusSearch
+	case "tab":
+		m.Focus = FocusSearch
+	case "up", "k":
+		m.moveCursor(-1)
+	case "down", "j":
+		m.moveCursor(1)
+	case "s":
+		m.Sort = (m.Sort + 1) % (SortDate + 1)
+		m.sortTorrents()
+		m.Status = fmt.Sprintf("Sorted by %s", m.Sort)
+	case "r":
+		m.Descending = !m.Descending
+		m.sortTorrents()
+	case "enter", "right", "l":
+		if m.Selected() == nil {
+			return nil
+		}
+		m.Focus = FocusDetail
+		m.detailOffset = 0
+		return m.describe()
+	case "c":
+		return m.copyMagnet()
+	case "d":
+		return m.send()
+	}
+	return nil
+}
+
+func (m *Model) moveCursor(delta int) {
+	m.Cursor += delta
+	if m.Cursor >= len(m.Torrents) {
+		m.Cursor = len(m.Torrents) - 1
+	}
+	if m.Cursor < 0 {
+		m.Cursor = 0
+	}
+	m.detailOffset = 0
+}
+
+// describe will fetch the description of the selected torrent once
+func (m *Model) describe() Cmd {
+	t := m.Selected()
+	if _, ok := m.Descriptions[t.Hash]; ok {
+		return nil
+	}
+	hash, describe := t.Hash, m.config.Describe
+	return func() Msg {
+		desc, err := describe(hash)
+		return DescriptionMsg{Hash: hash, Description: desc, Err: err}
+	}
+}
+
+func (m *Model) copyMagnet() Cmd {
+	t := m.Selected()
+	if t == nil || m.config.Copy == nil {
+		return nil
+	}
+	magnet, copyText := t.MagnetURI, m.config.Copy
+	return func() Msg {
+		if err := copyText(magnet); err != nil {
+			return StatusMsg(fmt.Sprintf("Couldn't copy the magnet : %s", err))
+		}
+		return StatusMsg("Magnet copied")
+	}
+}
+
+func (m *Model) send() Cmd {
+	t := m.Selected()
+	if t == nil {
+		return nil
+	}
+	if m.config.Sink == nil {
+		m.Status = "No sink configured"
+		return nil
+	}
+	torrent, sink := *t, m.config.Sink
+	m.Status = fmt.Sprintf("Sending %s...", torrent.Title)
+	return func() Msg {
+		err := sink.Send(&torrent)
+		switch {
+		case err == strikeapi.ErrDuplicate:
+			return StatusMsg(fmt.Sprintf("Already sent : %s", torrent.Title))
+		case err != nil:
+			return StatusMsg(fmt.Sprintf("Couldn't send : %s", err))
+		}
+		return StatusMsg(fmt.Sprintf("Sent : %s", torrent.Title))
+	}
+}
+
+// sortTorrents will sort the results by the sort key, keeping the cursor
+// on the same torrent
+func (m *Model) sortTorrents() {
+	var selected string
+	if t := m.Selected(); t != nil {
+		selected = t.Hash
+	}
+	defer func() {
+		for i := range m.Torrents {
+			if m.Torrents[i].Hash == selected {
+				m.Cursor = i
+			}
+		}
+	}()
+
+	if m.Sort == SortNone {
+		if m.results != nil {
+			m.Torrents = append(m.Torrents[:0], m.results...)
+		}
+		return
+	}
+
+	less := func(i, j int) bool {
+		a, b := &m.Torrents[i], &m.Torrents[j]
+		switch m.Sort {
+		case SortSeeds:
+			return a.Seeds < b.Seeds
+		case SortSize:
+			return a.Size < b.Size
+		case SortDate:
+			ta, _ := a.UploadTime()
+			tb, _ := b.UploadTime()
+			return ta.Before(tb)
+		}
+		return false
+	}
+	sort.SliceStable(m.Torrents, func(i, j int) bool {
+		if m.Descending {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
@@ -0,0 +1,158 @@
+package tui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+)
+
+// Escape sequences used by Run
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	clearScreen = "\x1b[H\x1b[2J"
+)
+
+// OSC52Copy returns a Copy function writing the text to the clipboard of
+// the terminal with the OSC 52 escape sequence
+func OSC52Copy(out io.Writer) func(text string) error {
+	return func(text string) error {
+		_, err := fmt.Fprintf(out, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+		return err
+	}
+}
+
+// Run will run the Model in the terminal until it quits, the terminal is
+// set in raw mode with stty
+func Run(m *Model, in *os.File, out io.Writer) error {
+	restore, err := makeRaw(in)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	if width, height, err := terminalSize(in); err == nil {
+		m.Update(ResizeMsg{Width: width, Height: height})
+	}
+
+	fmt.Fprint(out, enterScreen)
+	defer fmt.Fprint(out, leaveScreen)
+
+	msgs := make(chan Msg)
+	go readKeys(in, msgs)
+
+	for !m.Quitting {
+		// Raw mode doesn't translate newlines
+		fmt.Fprint(out, clearScreen+strings.Replace(m.View(), "\n", "\r\n", -1))
+
+		cmd := m.Update(<-msgs)
+		if cmd != nil {
+			go func() { msgs <- cmd() }()
+		}
+	}
+	return nil
+}
+
+// makeRaw will set the terminal in raw mode and return a function restoring
+// its previous state
+func makeRaw(in *os.File) (func(), error) {
+	state, err := stty(in, "-g")
+	if err != nil {
+		return nil, fmt.Errorf("not a terminal : %s", err)
+	}
+	if _, err := stty(in, "raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(in, strings.TrimSpace(state)) }, nil
+}
+
+func terminalSize(in *os.File) (int, int, error) {
+	size, err := stty(in, "size")
+	if err != nil {
+		return 0, 0, err
+	}
+	var height, width int
+	if _, err := fmt.Sscanf(size, "%d %d", &height, &width); err != nil {
+		return 0, 0, err
+	}
+	return width, height, nil
+}
+
+func stty(in *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = in
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// readKeys will send the keys read from the terminal as KeyMsg
+func readKeys(in io.Reader, msgs chan<- Msg) {
+	buf := make([]byte, 64)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			msgs <- KeyMsg("ctrl+c")
+			return
+		}
+		for _, key := range ParseKeys(buf[:n]) {
+			msgs <- key
+		}
+	}
+}
+
+// escapeKeys are the escape sequences of the named keys
+var escapeKeys = map[string]KeyMsg{
+	"\x1b[A": "up",
+	"\x1b[B": "down",
+	"\x1b[C": "right",
+	"\x1b[D": "left",
+	"\x1bOA": "up",
+	"\x1bOB": "down",
+	"\x1bOC": "right",
+	"\x1bOD": "left",
+}
+
+// ParseKeys will decode the bytes read from a terminal in raw mode
+func ParseKeys(data []byte) []KeyMsg {
+	keys := []KeyMsg{}
+	s := string(data)
+	for len(s) > 0 {
+		if s[0] == 0x1b {
+			if len(s) >= 3 {
+				if key, ok := escapeKeys[s[:3]]; ok {
+					keys = append(keys, key)
+					s = s[3:]
+					continue
+				}
+			}
+			keys = append(keys, "esc")
+			s = s[1:]
+			continue
+		}
+
+		switch s[0] {
+		case 3:
+			keys = append(keys, "ctrl+c")
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case '\t':
+			keys = append(keys, "tab")
+		case 8, 127:
+			keys = append(keys, "backspace")
+		default:
+			if s[0] < 0x20 {
+				break
+			}
+			r, size := utf8.DecodeRuneInString(s)
+			keys = append(keys, KeyMsg(string(r)))
+			s = s[size:]
+			continue
+		}
+		s = s[1:]
+	}
+	return keys
+}
@@ -0,0 +1,218 @@
+package tui
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+var testTorrents = []strikeapi.Torrent{
+	{
+		Title:      "Slackware 14.1 x86_64 DVD ISO",
+		Hash:       "156B69B8643BD11849A5D8F2122E13FBB61BD041",
+		Category:   "Applications",
+		Seeds:      192,
+		Leeches:    9,
+		Size:       2437393940.48,
+		UploadDate: "Feb 24, 2014",
+		MagnetURI:  "magnet:?xt=urn:btih:156B69B8643BD11849A5D8F2122E13FBB61BD041",
+		FilesInfo: &strikeapi.FilesInfo{FileInfo: []strikeapi.FileInfo{
+			{FileName: "slackware64-14.1-iso/slackware64-14.1-install-dvd.iso", FileSize: 2437393940},
+			{FileName: "slackware64-14.1-iso/CHECKSUMS.md5", FileSize: 1024},
+			{FileName: "README.txt", FileSize: 512},
+		}},
+	},
+	{
+		Title:      "Arch Linux 2015.01.01 (x86/x64)",
+		Hash:       "B425907E5755031BDA4A8D1B6DCCACA97DA14C04",
+		Category:   "Applications",
+		Seeds:      645,
+		Leeches:    13,
+		Size:       615514112,
+		UploadDate: "Jan  6, 2015",
+	},
+	{
+		Title:      "Marvel NOW",
+		Hash:       "6C32B66CEE44B7A0E3E42E22ACF5E77BF3218088",
+		Category:   "Books",
+		Seeds:      90,
+		Leeches:    458,
+		Size:       905141288.96,
+		UploadDate: "Mar 25, 2015",
+	},
+}
+
+// fakeSink records the torrents sent
+type fakeSink struct {
+	sent []string
+}
+
+func (s *fakeSink) Send(t *strikeapi.Torrent) error {
+	for _, hash := range s.sent {
+		if hash == t.Hash {
+			return strikeapi.ErrDuplicate
+		}
+	}
+	s.sent = append(s.sent, t.Hash)
+	return nil
+}
+
+// press will send keys to the Model, running the commands synchronously
+func press(m *Model, keys ...KeyMsg) {
+	for _, key := range keys {
+		cmd := m.Update(key)
+		for cmd != nil {
+			cmd = m.Update(cmd())
+		}
+	}
+}
+
+func newTestModel(sink strikeapi.Sink, copied *string) (*Model, *[]string) {
+	searches := &[]string{}
+	m := New(Config{
+		Search: func(phrase string) ([]strikeapi.Torrent, error) {
+			*searches = append(*searches, phrase)
+			torrents := make([]strikeapi.Torrent, len(testTorrents))
+			copy(torrents, testTorrents)
+			return torrents, nil
+		},
+		Describe: func(hash string) (string, error) {
+			return "Description of " + hash, nil
+		},
+		Copy: func(text string) error {
+			*copied = text
+			return nil
+		},
+		Sink: sink,
+	})
+	return m, searches
+}
+
+func titles(m *Model) []string {
+	t := []string{}
+	for _, torrent := range m.Torrents {
+		t = append(t, torrent.Title[:5])
+	}
+	return t
+}
+
+func TestSearchAndSort(t *testing.T) {
+	var copied string
+	m, searches := newTestModel(nil, &copied)
+
+	press(m, "l", "i", "n", "u", "x", "x", "backspace", "enter")
+	if !reflect.DeepEqual(*searches, []string{"linux"}) {
+		t.Errorf("Bad searches : %v", *searches)
+	}
+	if m.Focus != FocusList || len(m.Torrents) != 3 {
+		t.Fatalf("Results not shown : %+v", m)
+	}
+
+	press(m, "s")
+	if m.Sort != SortSeeds || !reflect.DeepEqual(titles(m), []string{"Arch ", "Slack", "Marve"}) {
+		t.Errorf("Bad seeds order : %v", titles(m))
+	}
+	press(m, "s")
+	if !reflect.DeepEqual(titles(m), []string{"Slack", "Marve", "Arch "}) {
+		t.Errorf("Bad size order : %v", titles(m))
+	}
+	press(m, "s", "r")
+	if !reflect.DeepEqual(titles(m), []string{"Slack", "Arch ", "Marve"}) {
+		t.Errorf("Bad date order : %v", titles(m))
+	}
+
+	// The cursor follows the selected torrent
+	press(m, "down", "down", "down", "s")
+	if m.Sort != SortNone || m.Selected().Title[:5] != "Marve" {
+		t.Errorf("Bad selection : %+v", m.Selected())
+	}
+}
+
+func TestSortCycle(t *testing.T) {
+	var copied string
+	m, _ := newTestModel(nil, &copied)
+	press(m, "x", "enter")
+
+	press(m, "s", "s", "s")
+	if !reflect.DeepEqual(titles(m), []string{"Marve", "Arch ", "Slack"}) {
+		t.Errorf("Bad date order : %v", titles(m))
+	}
+	// Back to the order of the API
+	press(m, "s")
+	if m.Sort != SortNone || !reflect.DeepEqual(titles(m), []string{"Slack", "Arch ", "Marve"}) {
+		t.Errorf("Should get the results order back : %v", titles(m))
+	}
+}
+
+func TestDetailAndActions(t *testing.T) {
+	var copied string
+	sink := &fakeSink{}
+	m, _ := newTestModel(sink, &copied)
+	m.Update(ResizeMsg{Width: 100, Height: 30})
+
+	press(m, "x", "enter", "enter")
+	if m.Focus != FocusDetail {
+		t.Fatalf("Detail not focused")
+	}
+	view := m.View()
+	for _, expected := range []string{
+		"Description of 156B69B8643BD11849A5D8F2122E13FBB61BD041",
+		"├── README.txt (512 B)",
+		"└── slackware64-14.1-iso",
+		"    ├── CHECKSUMS.md5 (1.00 KiB)",
+		"    └── slackware64-14.1-install-dvd.iso (2.27 GiB)",
+	} {
+		if !strings.Contains(view, expected) {
+			t.Errorf("%q missing from the view :\n%s", expected, view)
+		}
+	}
+	if lines := strings.Split(view, "\n"); len(lines) != 30 {
+		t.Errorf("The view should fill the terminal, got %d lines", len(lines))
+	}
+
+	press(m, "c")
+	if copied != testTorrents[0].MagnetURI || m.Status != "Magnet copied" {
+		t.Errorf("Magnet not copied : %q", copied)
+	}
+
+	press(m, "d")
+	press(m, "d")
+	if !reflect.DeepEqual(sink.sent, []string{testTorrents[0].Hash}) || !strings.HasPrefix(m.Status, "Already sent") {
+		t.Errorf("Bad sink calls : %v %s", sink.sent, m.Status)
+	}
+
+	press(m, "esc", "q")
+	if !m.Quitting {
+		t.Errorf("Should quit")
+	}
+}
+
+func TestSearchError(t *testing.T) {
+	m := New(Config{Search: func(string) ([]strikeapi.Torrent, error) {
+		return nil, errors.New("timeout")
+	}})
+	press(m, "a", "enter", "d")
+	if m.Focus != FocusSearch || m.Status != "Search failed : timeout" {
+		t.Errorf("Bad state after an error : %+v", m)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := ParseKeys([]byte("a\x1b[A\x1b[B\r\x7f\x1bé\t\x03"))
+	expected := []KeyMsg{"a", "up", "down", "enter", "backspace", "esc", "é", "tab", "ctrl+c"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Bad keys : %q", keys)
+	}
+}
+
+func TestOSC52Copy(t *testing.T) {
+	buf := &bytes.Buffer{}
+	OSC52Copy(buf)("magnet:?xt")
+	if buf.String() != "\x1b]52;c;bWFnbmV0Oj94dA==\a" {
+		t.Errorf("Bad escape sequence %q", buf.String())
+	}
+}
@@ -0,0 +1,193 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+	"github.com/PouuleT/go-strikeapi/format"
+)
+
+// helpLine lists the key bindings
+const helpLine = "/ search  ↑↓ move  enter details  s sort  r reverse  c copy magnet  d send  q quit"
+
+// View will render the Model, one line per terminal row
+func (m *Model) View() string {
+	lines := []string{}
+
+	cursor := ""
+	if m.Focus == FocusSearch {
+		cursor = "_"
+	}
+	order := "↓"
+	if !m.Descending {
+		order = "↑"
+	}
+	lines = append(lines, fmt.Sprintf("Search: %s%s", m.Query, cursor), fmt.Sprintf("Sort: %s %s", m.Sort, order))
+	lines = append(lines, strings.Repeat("─", m.Width))
+
+	// The list gets a third of the remaining rows, the detail pane the rest
+	available := m.Height - len(lines) - 3
+	if available < 2 {
+		available = 2
+	}
+	listHeight := available / 3
+	if listHeight < 1 {
+		listHeight = 1
+	}
+	lines = append(lines, m.listLines(listHeight)...)
+	lines = append(lines, strings.Repeat("─", m.Width))
+	lines = append(lines, m.detailLines(available-listHeight)...)
+	lines = append(lines, strings.Repeat("─", m.Width))
+
+	status := m.Status
+	if status == "" {
+		status = helpLine
+	}
+	lines = append(lines, status)
+
+	for i := range lines {
+		lines[i] = truncate(lines[i], m.Width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// listLines will render the results around the cursor
+func (m *Model) listLines(height int) []string {
+	if m.Cursor < m.listOffset {
+		m.listOffset = m.Cursor
+	}
+	if m.Cursor >= m.listOffset+height {
+		m.listOffset = m.Cursor - height + 1
+	}
+
+	lines := []string{}
+	titleWidth := m.Width - 36
+	if titleWidth < 10 {
+		titleWidth = 10
+	}
+	for i := m.listOffset; i < len(m.Torrents) && i < m.listOffset+height; i++ {
+		t := &m.Torrents[i]
+		marker := "  "
+		if i == m.Cursor {
+			marker = "> "
+		}
+		lines = append(lines, fmt.Sprintf("%s%-*s %6d %11s %12s", marker, titleWidth, truncate(t.Title, titleWidth), t.Seeds, format.HumanSize(t.Size), t.UploadDate))
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// detailLines will render the selected torrent, its files and description
+func (m *Model) detailLines(height int) []string {
+	t := m.Selected()
+	if t == nil {
+		lines := []string{"No torrent selected"}
+		for len(lines) < height {
+			lines = append(lines, "")
+		}
+		return lines
+	}
+
+	all := []string{
+		t.Title,
+		fmt.Sprintf("Hash: %s", t.Hash),
+		fmt.Sprintf("Category: %s %s", t.Category, t.SubCategory),
+		fmt.Sprintf("Seeds: %d  Leeches: %d  Size: %s", t.Seeds, t.Leeches, format.HumanSize(t.Size)),
+		fmt.Sprintf("Uploaded: %s by %s", t.UploadDate, t.UploaderUsername),
+		"",
+		"Files:",
+	}
+	all = append(all, FileTree(t.FilesInfo)...)
+	all = append(all, "", "Description:")
+	if desc, ok := m.Descriptions[t.Hash]; ok {
+		all = append(all, strings.Split(strings.TrimSpace(desc), "\n")...)
+	} else if m.Focus == FocusDetail {
+		all = append(all, "Loading...")
+	} else {
+		all = append(all, "Press enter to load")
+	}
+
+	if m.detailOffset > len(all)-1 {
+		m.detailOffset = len(all) - 1
+	}
+	lines := all[m.detailOffset:]
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// FileTree will render the files of a torrent as a tree
+func FileTree(files *strikeapi.FilesInfo) []string {
+	if files == nil || len(files.FileInfo) == 0 {
+		return []string{"(no file information)"}
+	}
+
+	root := &node{children: map[string]*node{}}
+	for _, f := range files.FileInfo {
+		n := root
+		for _, part := range strings.Split(f.FileName, "/") {
+			child, ok := n.children[part]
+			if !ok {
+				child = &node{name: part, children: map[string]*node{}}
+				n.children[part] = child
+			}
+			n = child
+		}
+		n.size = f.FileSize
+		n.file = true
+	}
+
+	lines := []string{}
+	root.render("", &lines)
+	return lines
+}
+
+type node struct {
+	name     string
+	size     float64
+	file     bool
+	children map[string]*node
+}
+
+func (n *node) render(prefix string, lines *[]string) {
+	names := []string{}
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		child := n.children[name]
+		branch, indent := "├── ", "│   "
+		if i == len(names)-1 {
+			branch, indent = "└── ", "    "
+		}
+		line := prefix + branch + child.name
+		if child.file {
+			line += fmt.Sprintf(" (%s)", format.HumanSize(child.size))
+		}
+		*lines = append(*lines, line)
+		child.render(prefix+indent, lines)
+	}
+}
+
+// truncate will cut a string to width runes
+func truncate(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	if width == 1 {
+		return string(r[:1])
+	}
+	return string(r[:width-1]) + "…"
+}
@@ -0,0 +1,146 @@
+package watch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State keeps the hashes seen by each Watcher, it can be shared by several
+// watchers and is saved to a file after each change
+type State struct {
+	mu      sync.Mutex
+	path    string
+	watches map[string]*watchState
+}
+
+// watchState is the state of a Watcher in the file
+type watchState struct {
+	// Seen maps the hashes to the time they were first seen
+	Seen    map[string]time.Time `json:"seen"`
+	LastRun time.Time            `json:"last_run"`
+}
+
+// stateFile is the content of the file
+type stateFile struct {
+	Watches map[string]*watchState `json:"watches"`
+}
+
+// NewState will create a State kept in memory
+func NewState() *State {
+	return &State{watches: map[string]*watchState{}}
+}
+
+// OpenState will load the State saved at path, a missing file gives an empty
+// State
+func OpenState(path string) (*State, error) {
+	s := NewState()
+	s.path = path
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file := stateFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	for name, w := range file.Watches {
+		if w.Seen == nil {
+			w.Seen = map[string]time.Time{}
+		}
+		s.watches[name] = w
+	}
+	return s, nil
+}
+
+// Known will tell whether a Watcher already ran
+func (s *State) Known(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.watches[name]
+	return ok
+}
+
+// Seen will tell whether a Watcher already saw a hash
+func (s *State) Seen(name, hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.watches[name]
+	if !ok {
+		return false
+	}
+	_, ok = w.Seen[strings.ToUpper(hash)]
+	return ok
+}
+
+// Mark will record the hashes seen by a Watcher and save the State
+func (s *State) Mark(name string, hashes []string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.watches[name]
+	if !ok {
+		w = &watchState{Seen: map[string]time.Time{}}
+		s.watches[name] = w
+	}
+	for _, hash := range hashes {
+		hash = strings.ToUpper(hash)
+		if _, ok := w.Seen[hash]; !ok {
+			w.Seen[hash] = now
+		}
+	}
+	w.LastRun = now
+	return s.save()
+}
+
+// Forget will drop the hashes seen before a time, the watchers will emit
+// them again if they are still found
+func (s *State) Forget(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range s.watches {
+		for hash, seen := range w.Seen {
+			if seen.Before(before) {
+				delete(w.Seen, hash)
+			}
+		}
+	}
+	return s.save()
+}
+
+// save will write the State to its file, through a temporary file so a
+// crash doesn't lose it
+func (s *State) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(stateFile{Watches: s.watches}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
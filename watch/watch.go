@@ -0,0 +1,207 @@
+// Package watch polls searches or top torrents and emits the torrents which
+// weren't seen before.
+//
+//	w := watch.New("slackware", watch.Search(strikeapi.SearchQuery{Phrase: "Slackware"}), time.Hour)
+//	w.State, _ = watch.OpenState("watch.json")
+//	err := w.Run(ctx, func(m watch.Match) {
+//		fmt.Println("New torrent :", m.Torrent.Title)
+//	})
+package watch
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// Default settings of a Watcher
+const (
+	DefaultJitter     = 0.1
+	DefaultMaxBackoff = time.Hour
+)
+
+// ErrNoSource is returned when a Watcher has no Source
+var ErrNoSource = errors.New("no source to watch")
+
+// Clock gives the time to a Watcher, replaced in tests
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Source fetches the torrents to watch
+type Source func() ([]strikeapi.Torrent, error)
+
+// Search returns a Source running a search
+func Search(q strikeapi.SearchQuery) Source {
+	return q.Search
+}
+
+// Top returns a Source getting the top torrents of a category
+func Top(category string) Source {
+	return func() ([]strikeapi.Torrent, error) {
+		return strikeapi.GetTopTorrents(category)
+	}
+}
+
+// Match represents a new torrent found by a Watcher
+type Match struct {
+	// Watch is the name of the Watcher
+	Watch   string
+	Torrent strikeapi.Torrent
+	Found   time.Time
+}
+
+// Watcher polls a Source
+type Watcher struct {
+	// Name identifies the watcher in the State
+	Name     string
+	Source   Source
+	Interval time.Duration
+	// Jitter randomizes the intervals by this fraction, 0.1 is ±10%
+	Jitter float64
+	// The interval doubles after each error, up to MaxBackoff, 0 disables
+	// the backoff
+	MaxBackoff time.Duration
+	// SkipExisting marks the torrents found by the first poll as seen
+	// without emitting them
+	SkipExisting bool
+	// State keeps the seen hashes, in memory when nil
+	State *State
+	// OnError is called with the errors of the Source
+	OnError func(err error)
+
+	Clock Clock
+	// Rand returns a number in [0, 1), rand.Float64 by default
+	Rand func() float64
+}
+
+// New will create a Watcher with the default settings
+func New(name string, source Source, interval time.Duration) *Watcher {
+	return &Watcher{
+		Name:       name,
+		Source:     source,
+		Interval:   interval,
+		Jitter:     DefaultJitter,
+		MaxBackoff: DefaultMaxBackoff,
+		Clock:      realClock{},
+		Rand:       rand.Float64,
+	}
+}
+
+// Run will poll the Source until the context is done, calling emit with
+// the new torrents, it returns the error of the context
+func (w *Watcher) Run(ctx context.Context, emit func(Match)) error {
+	if w.Source == nil {
+		return ErrNoSource
+	}
+	if w.Clock == nil {
+		w.Clock = realClock{}
+	}
+	if w.Rand == nil {
+		w.Rand = rand.Float64
+	}
+	if w.State == nil {
+		w.State = NewState()
+	}
+
+	failures := 0
+	for {
+		if err := w.poll(ctx, emit); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failures++
+			if w.OnError != nil {
+				w.OnError(err)
+			}
+		} else {
+			failures = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.Clock.After(w.delay(failures)):
+		}
+	}
+}
+
+// Chan will run the Watcher in a goroutine and send the new torrents on the
+// returned channel, closed when the context is done
+func (w *Watcher) Chan(ctx context.Context) <-chan Match {
+	matches := make(chan Match)
+	go func() {
+		defer close(matches)
+		w.Run(ctx, func(m Match) {
+			select {
+			case matches <- m:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return matches
+}
+
+// poll will fetch the Source once and emit the torrents not seen yet
+func (w *Watcher) poll(ctx context.Context, emit func(Match)) error {
+	torrents, err := w.Source()
+	if err != nil {
+		return err
+	}
+
+	now := w.Clock.Now()
+	first := !w.State.Known(w.Name)
+	fresh := []strikeapi.Torrent{}
+	hashes := []string{}
+	added := map[string]bool{}
+	for _, t := range torrents {
+		if !w.State.Seen(w.Name, t.Hash) && !added[t.Hash] {
+			added[t.Hash] = true
+			fresh = append(fresh, t)
+			hashes = append(hashes, t.Hash)
+		}
+	}
+
+	if first && w.SkipExisting || len(fresh) == 0 {
+		return w.State.Mark(w.Name, hashes, now)
+	}
+	// Each hash is saved once emitted, the matches interrupted by the
+	// context are emitted again by the next poll
+	for _, t := range fresh {
+		if ctx.Err() != nil {
+			return nil
+		}
+		emit(Match{Watch: w.Name, Torrent: t, Found: now})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := w.State.Mark(w.Name, []string{t.Hash}, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delay will return the time to wait before the next poll
+func (w *Watcher) delay(failures int) time.Duration {
+	d := w.Interval
+	for i := 0; i < failures && d < w.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > w.MaxBackoff && w.MaxBackoff > w.Interval {
+		d = w.MaxBackoff
+	}
+	if w.Jitter > 0 {
+		d += time.Duration(float64(d) * w.Jitter * (2*w.Rand() - 1))
+	}
+	return d
+}
@@ -0,0 +1,260 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	strikeapi "github.com/PouuleT/go-strikeapi"
+)
+
+// fakeClock only moves forward when Advance is called, the waits are sent
+// on the waits channel
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	timer chan time.Time
+	waits chan time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Date(2015, time.March, 1, 0, 0, 0, 0, time.UTC),
+		waits: make(chan time.Duration, 1),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.timer = make(chan time.Time, 1)
+	timer := c.timer
+	c.mu.Unlock()
+	c.waits <- d
+	return timer
+}
+
+// Advance will wait for the Watcher to sleep, check the duration and wake it up
+func (c *fakeClock) Advance(t *testing.T, expected time.Duration) {
+	select {
+	case d := <-c.waits:
+		if d != expected {
+			t.Errorf("Expected a wait of %s, got %s", expected, d)
+		}
+		c.mu.Lock()
+		c.now = c.now.Add(d)
+		c.timer <- c.now
+		c.mu.Unlock()
+	case <-time.After(time.Second):
+		t.Fatalf("The watcher didn't wait")
+	}
+}
+
+// scriptedSource returns the answers in order, then the last one
+type scriptedSource struct {
+	mu      sync.Mutex
+	answers []answer
+}
+
+type answer struct {
+	hashes []string
+	err    error
+}
+
+func (s *scriptedSource) fetch() ([]strikeapi.Torrent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.answers[0]
+	if len(s.answers) > 1 {
+		s.answers = s.answers[1:]
+	}
+	torrents := []strikeapi.Torrent{}
+	for _, hash := range a.hashes {
+		torrents = append(torrents, strikeapi.Torrent{Hash: hash, Title: "Torrent " + hash})
+	}
+	return torrents, a.err
+}
+
+func TestWatcher(t *testing.T) {
+	errDown := errors.New("API down")
+	source := &scriptedSource{answers: []answer{
+		{hashes: []string{"A", "B"}},
+		{hashes: []string{"A", "B", "C", "C"}},
+		{err: errDown},
+		{err: errDown},
+		{err: errDown},
+		{hashes: []string{"D", "A"}},
+	}}
+
+	clock := newFakeClock()
+	w := New("linux", source.fetch, time.Minute)
+	w.Clock = clock
+	w.MaxBackoff = 3 * time.Minute
+	w.Rand = func() float64 { return 0.5 }
+	errs := []error{}
+	w.OnError = func(err error) { errs = append(errs, err) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	matches := w.Chan(ctx)
+
+	expectMatches := func(hashes ...string) {
+		for _, hash := range hashes {
+			select {
+			case m := <-matches:
+				if m.Torrent.Hash != hash || m.Watch != "linux" || !m.Found.Equal(clock.Now()) {
+					t.Errorf("Expected %s, got %+v", hash, m)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("No match for %s", hash)
+			}
+		}
+	}
+
+	expectMatches("A", "B")
+	clock.Advance(t, time.Minute)
+	expectMatches("C")
+	// Backoff after the errors, capped
+	clock.Advance(t, time.Minute)
+	clock.Advance(t, 2*time.Minute)
+	clock.Advance(t, 3*time.Minute)
+	clock.Advance(t, 3*time.Minute)
+	expectMatches("D")
+	clock.Advance(t, time.Minute)
+
+	cancel()
+	select {
+	case m, ok := <-matches:
+		if ok {
+			t.Errorf("Unexpected match %+v", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("The channel should be closed")
+	}
+	if !reflect.DeepEqual(errs, []error{errDown, errDown, errDown}) {
+		t.Errorf("Bad errors %v", errs)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	w := New("jitter", nil, 100*time.Second)
+	for r, expected := range map[float64]time.Duration{0: 90 * time.Second, 0.5: 100 * time.Second, 0.75: 105 * time.Second} {
+		w.Rand = func() float64 { return r }
+		if d := w.delay(0); d != expected {
+			t.Errorf("%f : expected %s, got %s", r, expected, d)
+		}
+	}
+	w.Rand = func() float64 { return 0 }
+	if d := w.delay(2); d != 360*time.Second {
+		t.Errorf("The jitter applies to the backoff, got %s", d)
+	}
+	w.MaxBackoff = 0
+	if d := w.delay(5); d != 90*time.Second {
+		t.Errorf("The backoff should be disabled, got %s", d)
+	}
+
+	if err := w.Run(context.Background(), func(Match) {}); err != ErrNoSource {
+		t.Errorf("Should get an ErrNoSource, got %v", err)
+	}
+}
+
+func TestPersistentState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	run := func(skip bool, hashes ...string) []string {
+		state, err := OpenState(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source := &scriptedSource{answers: []answer{{hashes: hashes}}}
+		clock := newFakeClock()
+		w := New("top", source.fetch, time.Minute)
+		w.Clock, w.State, w.SkipExisting = clock, state, skip
+
+		ctx, cancel := context.WithCancel(context.Background())
+		found := []string{}
+		done := make(chan error)
+		go func() {
+			done <- w.Run(ctx, func(m Match) { found = append(found, m.Torrent.Hash) })
+		}()
+		<-clock.waits
+		cancel()
+		if err := <-done; err != context.Canceled {
+			t.Errorf("Should stop with the context, got %v", err)
+		}
+		return found
+	}
+
+	// The first run only records the existing torrents
+	if found := run(true, "A", "B"); len(found) != 0 {
+		t.Errorf("Nothing should be emitted on the first run, got %v", found)
+	}
+	if found := run(true, "A", "B", "C"); !reflect.DeepEqual(found, []string{"C"}) {
+		t.Errorf("Only C is new, got %v", found)
+	}
+
+	state, _ := OpenState(path)
+	if !state.Seen("top", "c") || state.Seen("other", "C") {
+		t.Errorf("Bad state")
+	}
+	state.Forget(time.Date(2015, time.March, 2, 0, 0, 0, 0, time.UTC))
+	if found := run(true, "A"); !reflect.DeepEqual(found, []string{"A"}) {
+		t.Errorf("Forgotten torrents are new again, got %v", found)
+	}
+
+	ioutil.WriteFile(path, []byte("{"), 0644)
+	if _, err := OpenState(path); err == nil {
+		t.Errorf("A corrupted state should fail")
+	}
+}
+
+func TestCancelDuringBatch(t *testing.T) {
+	state := NewState()
+	source := &scriptedSource{answers: []answer{{hashes: []string{"A", "B", "C"}}}}
+	w := New("top", source.fetch, time.Minute)
+	w.Clock, w.State = newFakeClock(), state
+
+	// Stop after the first match, B and C aren't read
+	ctx, cancel := context.WithCancel(context.Background())
+	matches := w.Chan(ctx)
+	if m := <-matches; m.Torrent.Hash != "A" {
+		t.Errorf("Expected A, got %+v", m)
+	}
+	cancel()
+	for range matches {
+	}
+	if !state.Seen("top", "A") || state.Seen("top", "C") {
+		t.Errorf("Only the emitted matches should be seen")
+	}
+
+	// The next poll emits the rest
+	clock := newFakeClock()
+	w.Clock = clock
+	ctx, cancel = context.WithCancel(context.Background())
+	found := []string{}
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx, func(m Match) { found = append(found, m.Torrent.Hash) })
+	}()
+	<-clock.waits
+	cancel()
+	<-done
+	if !reflect.DeepEqual(found, []string{"B", "C"}) {
+		t.Errorf("The next poll should emit the rest, got %v", found)
+	}
+}